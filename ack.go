@@ -0,0 +1,224 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/clock"
+)
+
+// errAckPayload is returned by NotifyWithAck when the correlation field
+// can't be added to the payload
+var errAckPayload = errors.New("notify ack: payload is not a JSON object")
+
+type (
+	// NotifyAck is the acknowledgment expected for a notify confirmed by
+	// a server push rather than a response
+	NotifyAck struct {
+		// Route is the push route carrying the acks
+		Route string
+		// Field is the JSON field correlating the notify and its ack: a
+		// random id is written to it in the notify and the ack must echo
+		// it. Empty, the acks of Route complete the notifies in order.
+		Field string
+		// Timeout is the wait for the ack of every attempt, zero uses the
+		// request timeout of the notify route and a negative value waits
+		// until the connection closes
+		Timeout time.Duration
+		// Retries is the number of times the notify is sent again when
+		// its ack times out, with the same id
+		Retries int
+	}
+
+	// ackWaits holds the notifies waiting for their ack, by ack route
+	ackWaits struct {
+		mu     sync.Mutex
+		routes map[string][]*pendingAck
+	}
+
+	pendingAck struct {
+		id       string
+		ack      NotifyAck
+		route    string
+		data     []byte
+		attempts int
+		timer    clock.Timer
+		done     func(ack []byte, err error)
+	}
+)
+
+// NotifyWithAck sends a notify and waits for its ack push, done is called
+// with the ack payload, ErrAckTimeout once the retries are exhausted, or
+// ErrConnectorClosed when the connection closes first. The notify is
+// sent again after every timed out attempt, giving at least once
+// delivery: the server must tolerate duplicates. An ack push completing
+// a notify is not dispatched to the route handler.
+func (c *Connector) NotifyWithAck(route string, data []byte, ack NotifyAck, done func(ack []byte, err error)) error {
+	if ack.Timeout == 0 {
+		c.muResponses.RLock()
+		ack.Timeout = c.timeoutFor(route, 0)
+		c.muResponses.RUnlock()
+	}
+	pa := &pendingAck{ack: ack, route: route, data: data, done: done}
+	if ack.Field != "" {
+		obj, ok := jsonObject(data)
+		if !ok {
+			return errAckPayload
+		}
+		pa.id = newTraceID()
+		raw, err := json.Marshal(pa.id)
+		if err != nil {
+			return err
+		}
+		obj[ack.Field] = raw
+		if pa.data, err = json.Marshal(obj); err != nil {
+			return err
+		}
+	}
+
+	c.acks.mu.Lock()
+	if c.acks.routes == nil {
+		c.acks.routes = map[string][]*pendingAck{}
+	}
+	c.acks.routes[ack.Route] = append(c.acks.routes[ack.Route], pa)
+	c.acks.mu.Unlock()
+
+	if err := c.Notify(route, pa.data); err != nil {
+		c.dropAck(pa)
+		return err
+	}
+	c.acks.mu.Lock()
+	if c.acks.pending(pa) {
+		c.armAck(pa)
+	}
+	c.acks.mu.Unlock()
+	return nil
+}
+
+// armAck starts the timeout of the current attempt, it must be called
+// with acks.mu held
+func (c *Connector) armAck(pa *pendingAck) {
+	if pa.ack.Timeout <= 0 {
+		return
+	}
+	pa.timer = c.clock.AfterFunc(pa.ack.Timeout, func() {
+		c.ackTimeout(pa)
+	})
+}
+
+func (c *Connector) ackTimeout(pa *pendingAck) {
+	c.acks.mu.Lock()
+	if !c.acks.pending(pa) {
+		// acknowledged or failed meanwhile
+		c.acks.mu.Unlock()
+		return
+	}
+	if pa.attempts < pa.ack.Retries {
+		pa.attempts++
+		c.logWarn("notify ack timeout, resending", Field{"route", pa.route}, Field{"attempt", pa.attempts})
+		c.armAck(pa)
+		// a resend is not a duplicate, it bypasses the notify dedup
+		if err := c.sendNotify(pa.route, pa.data); err != nil {
+			c.logWarn("notify resend failed", Field{"route", pa.route}, Field{"error", err})
+		}
+		c.acks.mu.Unlock()
+		return
+	}
+	c.acks.remove(pa)
+	c.acks.mu.Unlock()
+
+	c.logWarn("notify ack timeout", Field{"route", pa.route}, Field{"ack", pa.ack.Route})
+	c.dispatch(func() { pa.done(nil, ErrAckTimeout) })
+}
+
+// dropAck removes pa, it reports false if it was already completed
+func (c *Connector) dropAck(pa *pendingAck) bool {
+	c.acks.mu.Lock()
+	defer c.acks.mu.Unlock()
+
+	return c.acks.remove(pa)
+}
+
+// failAcks completes every notify waiting for its ack with err
+func (c *Connector) failAcks(err error) {
+	c.acks.mu.Lock()
+	var failed []*pendingAck
+	for _, waits := range c.acks.routes {
+		for _, pa := range waits {
+			if pa.timer != nil {
+				pa.timer.Stop()
+			}
+			failed = append(failed, pa)
+		}
+	}
+	c.acks.routes = nil
+	c.acks.mu.Unlock()
+
+	for _, pa := range failed {
+		pa := pa
+		c.dispatch(func() { pa.done(nil, err) })
+	}
+}
+
+// pending reports whether pa waits for its ack, it must be called with
+// mu held
+func (w *ackWaits) pending(pa *pendingAck) bool {
+	for _, cur := range w.routes[pa.ack.Route] {
+		if cur == pa {
+			return true
+		}
+	}
+	return false
+}
+
+// remove removes pa and stops its timer, it must be called with mu held
+// and reports false if pa was already completed
+func (w *ackWaits) remove(pa *pendingAck) bool {
+	waits := w.routes[pa.ack.Route]
+	for i, cur := range waits {
+		if cur != pa {
+			continue
+		}
+		if pa.timer != nil {
+			pa.timer.Stop()
+		}
+		waits = append(waits[:i:i], waits[i+1:]...)
+		if len(waits) == 0 {
+			delete(w.routes, pa.ack.Route)
+		} else {
+			w.routes[pa.ack.Route] = waits
+		}
+		return true
+	}
+	return false
+}
+
+// ackPush completes the notify acknowledged by a push of route, it
+// reports whether the push was an ack
+func (c *Connector) ackPush(route string, data []byte) bool {
+	c.acks.mu.Lock()
+	var matched *pendingAck
+	for _, pa := range c.acks.routes[route] {
+		if pa.ack.Field == "" {
+			matched = pa
+			break
+		}
+		if id, ok := NewLazyPush(data).String(pa.ack.Field); ok && id == pa.id {
+			matched = pa
+			break
+		}
+	}
+	if matched != nil {
+		c.acks.remove(matched)
+	}
+	c.acks.mu.Unlock()
+	if matched == nil {
+		return false
+	}
+
+	buf := cloneBytes(data)
+	c.dispatch(func() { matched.done(buf, nil) })
+	return true
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/clock"
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+type ackResult struct {
+	ack []byte
+	err error
+}
+
+// ackServer returns a server handing the notifies of route "act" to
+// the channel
+func ackServer(t *testing.T) (*testServer, <-chan *message.Message) {
+	notifies := make(chan *message.Message, 16)
+	s := newTestServer(t, func(sc *serverConn, msg *message.Message) {
+		if msg.Type == message.Notify && msg.Route == "act" {
+			notifies <- msg
+		}
+	})
+	return s, notifies
+}
+
+func nextNotify(t *testing.T, notifies <-chan *message.Message) *message.Message {
+	t.Helper()
+
+	select {
+	case msg := <-notifies:
+		return msg
+	case <-time.After(testTimeout):
+		t.Fatal("notify not received")
+		return nil
+	}
+}
+
+func waitAck(t *testing.T, results <-chan ackResult) ackResult {
+	t.Helper()
+
+	select {
+	case r := <-results:
+		return r
+	case <-time.After(testTimeout):
+		t.Fatal("ack not completed")
+		return ackResult{}
+	}
+}
+
+func TestNotifyAck(t *testing.T) {
+	s, notifies := ackServer(t)
+	c := newTestConnector(t)
+	c.On("acked", func([]byte) { t.Error("ack dispatched to the route handler") })
+	runConnector(t, c, s.addr())
+	sc := s.next()
+
+	results := make(chan ackResult, 2)
+	ack := NotifyAck{Route: "acked", Field: "ackId", Timeout: testTimeout}
+	for i := 0; i < 2; i++ {
+		if err := c.NotifyWithAck("act", []byte(`{"n":1}`), ack, func(data []byte, err error) {
+			results <- ackResult{data, err}
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ids []string
+	for i := 0; i < 2; i++ {
+		var body map[string]interface{}
+		if err := json.Unmarshal(nextNotify(t, notifies).Data, &body); err != nil {
+			t.Fatal(err)
+		}
+		id, _ := body["ackId"].(string)
+		ids = append(ids, id)
+	}
+	if ids[0] == "" || ids[0] == ids[1] {
+		t.Fatalf("ack ids %q", ids)
+	}
+
+	// acked out of order
+	sc.push("acked", []byte(`{"ackId":"`+ids[1]+`","ok":2}`))
+	sc.push("acked", []byte(`{"ackId":"`+ids[0]+`","ok":1}`))
+	for _, want := range []string{ids[1], ids[0]} {
+		r := waitAck(t, results)
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if id, _ := NewLazyPush(r.ack).String("ackId"); id != want {
+			t.Fatalf("ack %s, want id %s", r.ack, want)
+		}
+	}
+}
+
+func TestNotifyAckRetries(t *testing.T) {
+	s, notifies := ackServer(t)
+	clk := clock.NewFake(time.Unix(0, 0))
+	c := newTestConnector(t)
+	c.SetClock(clk)
+	runConnector(t, c, s.addr())
+	s.next()
+
+	results := make(chan ackResult, 1)
+	ack := NotifyAck{Route: "acked", Timeout: time.Second, Retries: 2}
+	if err := c.NotifyWithAck("act", []byte(`{}`), ack, func(data []byte, err error) {
+		results <- ackResult{data, err}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	nextNotify(t, notifies)
+	for i := 0; i < 2; i++ {
+		clk.Advance(time.Second)
+		nextNotify(t, notifies)
+	}
+	clk.Advance(time.Second)
+	if r := waitAck(t, results); r.err != ErrAckTimeout {
+		t.Fatalf("error %v", r.err)
+	}
+	select {
+	case msg := <-notifies:
+		t.Fatalf("resent after the last attempt: %s", msg.Data)
+	default:
+	}
+}
+
+func TestNotifyAckFailsOnClose(t *testing.T) {
+	s, notifies := ackServer(t)
+	c := newTestConnector(t)
+	c.SetRequestTimeout(-1)
+	runConnector(t, c, s.addr())
+	s.next()
+
+	results := make(chan ackResult, 1)
+	// no timeout: only the close completes it
+	if err := c.NotifyWithAck("act", []byte(`{}`), NotifyAck{Route: "acked"}, func(data []byte, err error) {
+		results <- ackResult{data, err}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	nextNotify(t, notifies)
+	c.Close()
+	if r := waitAck(t, results); r.err != ErrConnectorClosed {
+		t.Fatalf("error %v", r.err)
+	}
+}
+
+func TestNotifyAckDefaultTimeout(t *testing.T) {
+	s, _ := ackServer(t)
+	clk := clock.NewFake(time.Unix(0, 0))
+	c := newTestConnector(t)
+	c.SetClock(clk)
+	c.SetRequestTimeout(time.Second)
+	runConnector(t, c, s.addr())
+	s.next()
+
+	results := make(chan ackResult, 1)
+	if err := c.NotifyWithAck("act", []byte(`{}`), NotifyAck{Route: "acked"}, func(data []byte, err error) {
+		results <- ackResult{data, err}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Second)
+	if r := waitAck(t, results); r.err != ErrAckTimeout {
+		t.Fatalf("error %v", r.err)
+	}
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// WithContext makes the request wait for admission while the connector
+// is saturated, i.e. its in-flight limit is reached or its send queue is
+// full, and fail with the context error once ctx is done. Without it a
+// request over the in-flight limit fails with ErrBackpressure and a full
+// send queue blocks until the connector is closed.
+func WithContext(ctx context.Context) RequestOption {
+	return func(o *requestOptions) {
+		o.ctx = ctx
+	}
+}
+
+// SetMaxInflight limits the requests waiting for a response on the
+// connector, whatever their route. n <= 0 removes the limit. It must be
+// called before Run.
+func (c *Connector) SetMaxInflight(n int) {
+	if n <= 0 {
+		c.admission = nil
+		return
+	}
+	c.admission = make(chan struct{}, n)
+}
+
+// admit takes an in-flight slot, blocking only when ctx is set
+func (c *Connector) admit(ctx context.Context) error {
+	if c.admission == nil {
+		return nil
+	}
+	select {
+	case c.admission <- struct{}{}:
+		return nil
+	default:
+	}
+	if ctx == nil {
+		return ErrBackpressure
+	}
+
+	select {
+	case c.admission <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done():
+		return ErrConnectorClosed
+	}
+}
+
+// leave frees the in-flight slot taken by admit
+func (c *Connector) leave() {
+	if c.admission == nil {
+		return
+	}
+	<-c.admission
+}
+
+// enqueue queues out, waiting on a full queue until ctx is done when ctx
+// is set and until the connector is closed otherwise.
+func (c *Connector) enqueue(ctx context.Context, out outbound) error {
+	if ctx == nil {
+		c.send(out)
+		return nil
+	}
+
+	atomic.AddInt64(&c.unflushed, 1)
+	select {
+	case c.sendQueue() <- out:
+		return nil
+	case <-ctx.Done():
+		atomic.AddInt64(&c.unflushed, -1)
+		return ctx.Err()
+	case <-c.done():
+		atomic.AddInt64(&c.unflushed, -1)
+		return ErrConnectorClosed
+	}
+}
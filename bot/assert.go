@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"time"
+
+	client "github.com/revzim/go-pomelo-client"
+)
+
+// ErrAssertion is returned by Run when an assertion failed, the report
+// tells which steps
+var ErrAssertion = errors.New("bot: assertion failed")
+
+// AssertionError is a failed assertion on a response or push, it is
+// counted apart from the transport errors in StepMetrics.Assertions
+type AssertionError struct {
+	Msg string
+}
+
+func (e *AssertionError) Error() string {
+	return "bot: assertion: " + e.Msg
+}
+
+func assertf(format string, args ...interface{}) error {
+	return &AssertionError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// ExpectJSON asserts the JSON value at path equals want, compared after a
+// JSON round trip so ExpectJSON("code", 200) matches 200.0. Nested fields
+// are separated by dots and array elements are addressed by index, e.g.
+// "players.0.name".
+func ExpectJSON(path string, want interface{}) func(resp []byte) error {
+	return func(resp []byte) error {
+		raw, ok := client.NewLazyPush(resp).Get(path)
+		if !ok {
+			return assertf("%s: not found", path)
+		}
+		var got interface{}
+		if err := json.Unmarshal(raw, &got); err != nil {
+			return assertf("%s: %v", path, err)
+		}
+		data, err := json.Marshal(want)
+		if err != nil {
+			return err
+		}
+		var exp interface{}
+		if err := json.Unmarshal(data, &exp); err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(got, exp) {
+			return assertf("%s: got %s, want %s", path, raw, data)
+		}
+		return nil
+	}
+}
+
+// ExpectMatch asserts the body matches the regular expression pattern, it
+// panics if pattern does not compile
+func ExpectMatch(pattern string) func(resp []byte) error {
+	re := regexp.MustCompile(pattern)
+	return func(resp []byte) error {
+		if !re.Match(resp) {
+			return assertf("body does not match %q", pattern)
+		}
+		return nil
+	}
+}
+
+// ExpectAll asserts every expectation in order
+func ExpectAll(expects ...func(resp []byte) error) func(resp []byte) error {
+	return func(resp []byte) error {
+		for _, expect := range expects {
+			if err := expect(resp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ExpectPush asserts a push on route arrives within deadline and passes
+// expect, which may be nil. Like WaitPush, pushes are recorded from the
+// start of the user.
+func ExpectPush(route string, deadline time.Duration, expect func(data []byte) error) Step {
+	return Step{
+		Name: "push:" + route,
+		Run: func(ctx context.Context, u *User) error {
+			select {
+			case data := <-u.pushChan(route):
+				if expect != nil {
+					return expect(data)
+				}
+				return nil
+			case <-time.After(deadline):
+				return assertf("no push on %s within %s", route, deadline)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
@@ -0,0 +1,342 @@
+// Package bot runs scripted scenarios (connect, request-expect,
+// wait-for-push, think-time, loop) across virtual users and reports per
+// step metrics, for reproducible load and regression runs.
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	client "github.com/revzim/go-pomelo-client"
+)
+
+// pushBuffer is the number of pushes kept per route for WaitPush
+const pushBuffer = 64
+
+var (
+	// ErrPushTimeout is returned by WaitPush when no push arrived in time
+	ErrPushTimeout = errors.New("bot: push timeout")
+	// ErrConnectTimeout is returned by Connect when the handshake did not complete in time
+	ErrConnectTimeout = errors.New("bot: connect timeout")
+)
+
+type (
+	// Step is one action of a scenario
+	Step struct {
+		Name  string
+		Run   func(ctx context.Context, u *User) error
+		steps []Step // children of a loop
+		loops int
+	}
+
+	// Scenario is an ordered list of steps run by every virtual user
+	Scenario struct {
+		Name  string
+		Steps []Step
+	}
+
+	// Options configures a scenario run
+	Options struct {
+		Users  int                           // number of virtual users
+		RampUp time.Duration                 // delay between two user starts is RampUp/Users
+		New    func(i int) *client.Connector // returns a configured, unconnected connector
+	}
+
+	// User is a virtual user running the scenario
+	User struct {
+		ID   int
+		Conn *client.Connector
+
+		mu     sync.Mutex
+		pushes map[string]chan []byte
+	}
+
+	// StepMetrics aggregates the executions of one step
+	StepMetrics struct {
+		Name       string
+		Count      int64
+		Errors     int64
+		Latency    client.Histogram // microseconds
+		Assertions int64            // errors which are failed assertions
+		LastErr    error
+	}
+
+	// Report is the result of a scenario run
+	Report struct {
+		Scenario string
+		Users    int
+		Duration time.Duration
+		Steps    []StepMetrics // in first execution order
+	}
+
+	metrics struct {
+		mu    sync.Mutex
+		order []string
+		steps map[string]*StepMetrics
+	}
+)
+
+// Connect runs the connector to addr and waits up to timeout for it to be ready
+func Connect(addr string, timeout time.Duration) Step {
+	return Step{
+		Name: "connect",
+		Run: func(ctx context.Context, u *User) error {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- u.Conn.Run(addr)
+			}()
+			select {
+			case <-u.Conn.Ready():
+				return nil
+			case err := <-errCh:
+				return err
+			case <-time.After(timeout):
+				return ErrConnectTimeout
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// Request sends data to route and validates the response with expect,
+// expect may be nil.
+func Request(route string, data []byte, expect func(resp []byte) error) Step {
+	return Step{
+		Name: "request:" + route,
+		Run: func(ctx context.Context, u *User) error {
+			var resp []byte
+			if err := u.Conn.Call(route, data, &resp); err != nil {
+				return err
+			}
+			if expect != nil {
+				return expect(resp)
+			}
+			return nil
+		},
+	}
+}
+
+// WaitPush waits up to timeout for a push on route. Pushes are recorded
+// from the start of the user so a push arriving before the step is kept.
+func WaitPush(route string, timeout time.Duration) Step {
+	return Step{
+		Name: "push:" + route,
+		Run: func(ctx context.Context, u *User) error {
+			select {
+			case <-u.pushChan(route):
+				return nil
+			case <-time.After(timeout):
+				return ErrPushTimeout
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// Think pauses the user for d
+func Think(d time.Duration) Step {
+	return Step{
+		Name: "think",
+		Run: func(ctx context.Context, u *User) error {
+			select {
+			case <-time.After(d):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// Loop runs steps n times, n <= 0 loops until the run is cancelled
+func Loop(n int, steps ...Step) Step {
+	return Step{Name: "loop", steps: steps, loops: n}
+}
+
+// Run executes the scenario across opts.Users virtual users and returns
+// once every user finished or ctx is done. The report comes with
+// ErrAssertion when an assertion failed.
+func Run(ctx context.Context, s Scenario, opts Options) (*Report, error) {
+	if opts.New == nil {
+		return nil, errors.New("bot: Options.New is required")
+	}
+	if opts.Users <= 0 {
+		opts.Users = 1
+	}
+
+	m := &metrics{steps: map[string]*StepMetrics{}}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Users; i++ {
+		if i > 0 && opts.RampUp > 0 {
+			select {
+			case <-time.After(opts.RampUp / time.Duration(opts.Users)):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		u := newUser(i, opts.New(i), s.Steps)
+		if u.Conn.Name() == "" {
+			u.Conn.SetName(fmt.Sprintf("%s/%d", s.Name, i))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer u.Conn.Close()
+			runSteps(ctx, u, s.Steps, m)
+		}()
+	}
+	wg.Wait()
+
+	r := m.report(s.Name, opts.Users, time.Since(start))
+	if r.AssertionFailed() {
+		return r, ErrAssertion
+	}
+	return r, nil
+}
+
+func newUser(id int, c *client.Connector, steps []Step) *User {
+	u := &User{ID: id, Conn: c, pushes: map[string]chan []byte{}}
+	for _, route := range pushRoutes(steps) {
+		ch := u.pushChan(route)
+		c.On(route, func(data []byte) {
+			buf := make([]byte, len(data))
+			copy(buf, data)
+			for {
+				select {
+				case ch <- buf:
+					return
+				default:
+				}
+				// nobody waits for that many pushes, drop the oldest
+				select {
+				case <-ch:
+				default:
+				}
+			}
+		})
+	}
+	return u
+}
+
+func (u *User) pushChan(route string) chan []byte {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ch, ok := u.pushes[route]
+	if !ok {
+		ch = make(chan []byte, pushBuffer)
+		u.pushes[route] = ch
+	}
+	return ch
+}
+
+func pushRoutes(steps []Step) []string {
+	var routes []string
+	for _, st := range steps {
+		if strings.HasPrefix(st.Name, "push:") {
+			routes = append(routes, strings.TrimPrefix(st.Name, "push:"))
+		}
+		routes = append(routes, pushRoutes(st.steps)...)
+	}
+	return routes
+}
+
+// runSteps returns false once a step failed or the run is cancelled
+func runSteps(ctx context.Context, u *User, steps []Step, m *metrics) bool {
+	for _, st := range steps {
+		if ctx.Err() != nil {
+			return false
+		}
+		if st.Run == nil {
+			for i := 0; st.loops <= 0 || i < st.loops; i++ {
+				if !runSteps(ctx, u, st.steps, m) {
+					return false
+				}
+			}
+			continue
+		}
+
+		t := time.Now()
+		err := st.Run(ctx, u)
+		m.record(st.Name, time.Since(t), err)
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *metrics) record(name string, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sm, ok := m.steps[name]
+	if !ok {
+		sm = &StepMetrics{Name: name}
+		m.steps[name] = sm
+		m.order = append(m.order, name)
+	}
+	sm.Count++
+	sm.Latency.Observe(elapsed.Microseconds())
+	if err != nil {
+		sm.Errors++
+		sm.LastErr = err
+		var ae *AssertionError
+		if errors.As(err, &ae) {
+			sm.Assertions++
+		}
+	}
+}
+
+func (m *metrics) report(name string, users int, d time.Duration) *Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := &Report{Scenario: name, Users: users, Duration: d}
+	for _, step := range m.order {
+		r.Steps = append(r.Steps, *m.steps[step])
+	}
+	return r
+}
+
+// Failed reports whether any step execution failed
+func (r *Report) Failed() bool {
+	for _, sm := range r.Steps {
+		if sm.Errors > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertionFailed reports whether any assertion failed
+func (r *Report) AssertionFailed() bool {
+	for _, sm := range r.Steps {
+		if sm.Assertions > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a table of the step metrics
+func (r *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "scenario %q: %d users in %s\n", r.Scenario, r.Users, r.Duration.Round(time.Millisecond))
+	for _, sm := range r.Steps {
+		fmt.Fprintf(&sb, "  %-32s count=%-8d errors=%-6d asserts=%-6d mean=%.0fus p50<=%dus p95<=%dus p99<=%dus max=%dus\n",
+			sm.Name, sm.Count, sm.Errors, sm.Assertions, sm.Latency.Mean(),
+			sm.Latency.Quantile(0.50), sm.Latency.Quantile(0.95), sm.Latency.Quantile(0.99), sm.Latency.Max)
+	}
+	return sb.String()
+}
@@ -0,0 +1,199 @@
+package bot
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	client "github.com/revzim/go-pomelo-client"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Sealed credential file layout: magic, salt, nonce, AES-256-GCM sealed
+// JSON array of credentials. The key is derived from the passphrase with
+// PBKDF2-HMAC-SHA256.
+const (
+	credMagic      = "PCRED1"
+	credSaltSize   = 16
+	credKeySize    = 32
+	credIterations = 200000
+)
+
+var (
+	// ErrBadCredentials is returned when a credential file can't be opened
+	// with the passphrase, or is not a credential file
+	ErrBadCredentials = errors.New("bot: bad credential file or passphrase")
+	// ErrNoPassphrase is returned when the passphrase source is empty
+	ErrNoPassphrase = errors.New("bot: no passphrase")
+)
+
+// Credentials are the secrets of the virtual users, e.g. account and
+// token, one entry per user. An entry is a handshake user data or the
+// body of an auth request.
+type Credentials struct {
+	entries []map[string]interface{}
+}
+
+// SealCredentials encrypts entries with passphrase, the result is written
+// to the file read by LoadCredentials.
+func SealCredentials(entries []map[string]interface{}, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrNoPassphrase
+	}
+	plain, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, credSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := credAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(credMagic), salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plain, []byte(credMagic)), nil
+}
+
+// OpenCredentials decrypts credentials sealed by SealCredentials
+func OpenCredentials(data, passphrase []byte) (*Credentials, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrNoPassphrase
+	}
+	if !bytes.HasPrefix(data, []byte(credMagic)) || len(data) < len(credMagic)+credSaltSize {
+		return nil, ErrBadCredentials
+	}
+	data = data[len(credMagic):]
+	salt, data := data[:credSaltSize], data[credSaltSize:]
+
+	aead, err := credAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, ErrBadCredentials
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, []byte(credMagic))
+	if err != nil {
+		return nil, ErrBadCredentials
+	}
+
+	c := &Credentials{}
+	if err := json.Unmarshal(plain, &c.entries); err != nil {
+		return nil, ErrBadCredentials
+	}
+	return c, nil
+}
+
+// LoadCredentials reads and decrypts the credential file at path
+func LoadCredentials(path string, passphrase []byte) (*Credentials, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return OpenCredentials(data, passphrase)
+}
+
+// Len returns the number of credential entries, i.e. of distinct users
+func (c *Credentials) Len() int {
+	return len(c.entries)
+}
+
+// For returns a copy of the credentials of user i, users beyond Len reuse
+// the entries in order. It returns nil when there is no entry.
+func (c *Credentials) For(i int) map[string]interface{} {
+	if len(c.entries) == 0 {
+		return nil
+	}
+	entry := c.entries[i%len(c.entries)]
+	cp := make(map[string]interface{}, len(entry))
+	for k, v := range entry {
+		cp[k] = v
+	}
+	return cp
+}
+
+// Identities returns the entries as identities for an identity provider,
+// e.g. client.NewIdentityRotation, each entry is the handshake user data
+// of its identity. An identity is named by the string field nameKey of
+// its entry, or by its index without it.
+func (c *Credentials) Identities(nameKey string) []client.Identity {
+	ids := make([]client.Identity, len(c.entries))
+	for i := range c.entries {
+		user := c.For(i)
+		name, ok := user[nameKey].(string)
+		if !ok {
+			name = strconv.Itoa(i)
+		}
+		ids[i] = client.Identity{Name: name, User: user}
+	}
+	return ids
+}
+
+// PassphraseFromEnv reads the passphrase from the environment variable
+// name, e.g. injected by the CI secret store
+func PassphraseFromEnv(name string) ([]byte, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, fmt.Errorf("%w: %s is not set", ErrNoPassphrase, name)
+	}
+	return []byte(v), nil
+}
+
+// PassphraseFromKeyring reads the passphrase stored under service and
+// account in the OS keyring: the login keychain on macOS (security) and
+// the Secret Service on Linux (secret-tool, attributes service and
+// account).
+func PassphraseFromKeyring(service, account string) ([]byte, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	default:
+		return nil, fmt.Errorf("bot: no keyring support on %s", runtime.GOOS)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("bot: keyring lookup %s/%s: %w", service, account, err)
+	}
+	out = bytes.TrimRight(out, "\r\n")
+	if len(out) == 0 {
+		return nil, ErrNoPassphrase
+	}
+	return out, nil
+}
+
+func credAEAD(passphrase, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(passphrase, salt, credIterations))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey derives the file key with PBKDF2-HMAC-SHA256 (RFC 8018)
+func deriveKey(passphrase, salt []byte, iter int) []byte {
+	return pbkdf2.Key(passphrase, salt, iter, credKeySize, sha256.New)
+}
@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestDeriveKeyVectors(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 vectors, RFC 6070 inputs
+	for _, tc := range []struct {
+		password, salt string
+		iter           int
+		key            string
+	}{
+		{"password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	} {
+		if got := hex.EncodeToString(deriveKey([]byte(tc.password), []byte(tc.salt), tc.iter)); got != tc.key {
+			t.Errorf("%s/%s/%d: key %s, want %s", tc.password, tc.salt, tc.iter, got, tc.key)
+		}
+	}
+}
+
+func TestSealOpenCredentials(t *testing.T) {
+	entries := []map[string]interface{}{
+		{"account": "a", "token": "ta"},
+		{"account": "b", "token": "tb"},
+	}
+	sealed, err := SealCredentials(entries, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := OpenCredentials(sealed, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 2 {
+		t.Fatalf("%d entries", c.Len())
+	}
+	// users beyond Len reuse the entries
+	if c.For(3)["account"] != "b" {
+		t.Fatalf("user 3 %v", c.For(3))
+	}
+	c.For(0)["account"] = "changed"
+	if c.For(0)["account"] != "a" {
+		t.Fatal("For returned the entry, not a copy")
+	}
+	ids := c.Identities("account")
+	if len(ids) != 2 || ids[0].Name != "a" || ids[1].Name != "b" {
+		t.Fatalf("identities %+v", ids)
+	}
+}
+
+func TestOpenCredentialsErrors(t *testing.T) {
+	sealed, err := SealCredentials([]map[string]interface{}{{"account": "a"}}, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	for _, tc := range []struct {
+		name       string
+		data       []byte
+		passphrase string
+	}{
+		{"wrong passphrase", sealed, "other"},
+		{"truncated header", sealed[:len(credMagic)+4], "secret"},
+		{"truncated nonce", sealed[:len(credMagic)+credSaltSize+4], "secret"},
+		{"truncated payload", sealed[:len(sealed)-1], "secret"},
+		{"tampered", tampered, "secret"},
+		{"foreign file", []byte(`[{"account":"a"}]`), "secret"},
+		{"empty", nil, "secret"},
+	} {
+		if _, err := OpenCredentials(tc.data, []byte(tc.passphrase)); !errors.Is(err, ErrBadCredentials) {
+			t.Errorf("%s: error %v", tc.name, err)
+		}
+	}
+
+	if _, err := OpenCredentials(sealed, nil); !errors.Is(err, ErrNoPassphrase) {
+		t.Errorf("no passphrase: error %v", err)
+	}
+	if _, err := SealCredentials(nil, nil); !errors.Is(err, ErrNoPassphrase) {
+		t.Errorf("seal without passphrase: error %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/clock"
+)
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker fails requests fast on routes with consecutive failures
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	clock     clock.Clock
+	routes    map[string]*routeCircuit
+}
+
+type routeCircuit struct {
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+// SetCircuitBreaker opens the circuit of a route after threshold
+// consecutive failures or timeouts, requests fail with ErrCircuitOpen
+// until cooldown elapsed, then a single probe request is let through.
+// threshold <= 0 disables the breaker.
+func (c *Connector) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	if threshold <= 0 {
+		c.breaker = nil
+		return
+	}
+	c.breaker = &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		clock:     c.clock,
+		routes:    map[string]*routeCircuit{},
+	}
+}
+
+// allow reports whether a request to route may be sent, probe is true
+// for the single request let through a half-open circuit: it must end
+// with success, failure or release.
+func (b *circuitBreaker) allow(route string) (probe bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rc, ok := b.routes[route]
+	if !ok {
+		return false, nil
+	}
+	switch rc.state {
+	case circuitOpen:
+		if b.clock.Since(rc.openedAt) < b.cooldown {
+			return false, ErrCircuitOpen
+		}
+		rc.state = circuitHalfOpen
+		return true, nil
+	case circuitHalfOpen:
+		// the probe is still in flight
+		return false, ErrCircuitOpen
+	}
+	return false, nil
+}
+
+// release gives back the probe of route when it ended without telling
+// anything about the route health, e.g. canceled or failed with
+// ErrConnectorClosed: the circuit opens again with its cooldown already
+// elapsed so the next request probes.
+func (b *circuitBreaker) release(route string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if rc, ok := b.routes[route]; ok && rc.state == circuitHalfOpen {
+		rc.state = circuitOpen
+	}
+}
+
+// releaseProbe releases the circuit of pr if it was the probe, for the
+// requests ending with neither success nor failure
+func (c *Connector) releaseProbe(pr *pendingRequest) {
+	if pr.probe {
+		c.breaker.release(pr.route)
+	}
+}
+
+func (b *circuitBreaker) success(route string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.routes, route)
+}
+
+func (b *circuitBreaker) failure(route string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rc, ok := b.routes[route]
+	if !ok {
+		rc = &routeCircuit{}
+		b.routes[route] = rc
+	}
+	rc.failures++
+	if rc.state == circuitHalfOpen || rc.failures >= b.threshold {
+		rc.state = circuitOpen
+		rc.openedAt = b.clock.Now()
+	}
+}
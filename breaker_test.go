@@ -0,0 +1,101 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/clock"
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+const breakerCooldown = 10 * time.Second
+
+// openBreaker runs a connector to a server answering nothing and opens
+// the circuit of "slow" with a timeout, the cooldown has elapsed on
+// return
+func openBreaker(t *testing.T) (*Connector, *clock.Fake) {
+	t.Helper()
+
+	s := newTestServer(t, func(*serverConn, *message.Message) {})
+	clk := clock.NewFake(time.Unix(0, 0))
+	c := newTestConnector(t)
+	c.SetClock(clk)
+	c.SetCircuitBreaker(1, breakerCooldown)
+	runConnector(t, c, s.addr())
+	s.next()
+
+	if err := c.Request("slow", nil, func([]byte) {}, WithTimeout(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(2 * time.Second)
+	waitFor(t, "open circuit", func() bool {
+		return circuitOf(c, "slow") == circuitOpen
+	})
+	clk.Advance(breakerCooldown)
+	return c, clk
+}
+
+func circuitOf(c *Connector, route string) circuitState {
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+
+	if rc, ok := c.breaker.routes[route]; ok {
+		return rc.state
+	}
+	return circuitClosed
+}
+
+func TestBreakerReleasesProbe(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		end  func(t *testing.T, c *Connector, clk *clock.Fake)
+	}{
+		{"canceled", func(t *testing.T, c *Connector, _ *clock.Fake) {
+			if n := c.CancelTag("probe"); n != 1 {
+				t.Fatalf("canceled %d requests", n)
+			}
+		}},
+		{"pruned", func(t *testing.T, c *Connector, clk *clock.Fake) {
+			clk.Advance(time.Millisecond)
+			if n := c.PruneOlderThan(0); n != 1 {
+				t.Fatalf("pruned %d requests", n)
+			}
+		}},
+		{"closed", func(t *testing.T, c *Connector, _ *clock.Fake) {
+			c.Close()
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, clk := openBreaker(t)
+
+			if err := c.Request("slow", nil, func([]byte) {}, WithTag("probe")); err != nil {
+				t.Fatalf("probe: %v", err)
+			}
+			if err := c.Request("slow", nil, func([]byte) {}); err != ErrCircuitOpen {
+				t.Fatalf("second request while probing: %v", err)
+			}
+
+			tc.end(t, c, clk)
+			waitFor(t, "released probe", func() bool {
+				probe, err := c.breaker.allow("slow")
+				return probe && err == nil
+			})
+		})
+	}
+}
+
+func TestBreakerProbeTimeoutReopens(t *testing.T) {
+	c, clk := openBreaker(t)
+
+	if err := c.Request("slow", nil, func([]byte) {}, WithTimeout(time.Second)); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	clk.Advance(2 * time.Second)
+	waitFor(t, "reopened circuit", func() bool {
+		return circuitOf(c, "slow") == circuitOpen
+	})
+	clk.Advance(breakerCooldown)
+	if probe, err := c.breaker.allow("slow"); !probe || err != nil {
+		t.Fatalf("after cooldown: probe %v, %v", probe, err)
+	}
+}
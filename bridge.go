@@ -0,0 +1,149 @@
+package client
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/revzim/go-pomelo-client/message"
+	"github.com/revzim/go-pomelo-client/serialize/protobuf"
+	"github.com/revzim/go-pomelo-client/serialize/raw"
+)
+
+// HandshakeProtos is the sys.protos of a pomelo handshake response, the
+// routes listed in Client are sent in protobuf and the routes listed in
+// Server are received in protobuf.
+type HandshakeProtos struct {
+	Client  map[string]json.RawMessage `json:"client,omitempty"`
+	Server  map[string]json.RawMessage `json:"server,omitempty"`
+	Version interface{}                `json:"version,omitempty"`
+}
+
+// RegisterProtoType declares the protobuf message of route, it enables
+// the automatic bridging of the route between the wire encoding
+// announced in the handshake (sys.protos) and the encoding used by the
+// application: a JSON handler or request on a protobuf route is
+// converted with protojson, and so is a protobuf handler or request
+// (OnProto, RequestProto or a protobuf route serializer) on a JSON route.
+func (c *Connector) RegisterProtoType(route string, newMsg func() proto.Message) {
+	c.Lock()
+	defer c.Unlock()
+
+	if newMsg == nil {
+		delete(c.protoTypes, route)
+		return
+	}
+	c.protoTypes[route] = newMsg
+}
+
+// setWireProtos stores the protobuf routes of the handshake response
+func (c *Connector) setWireProtos(protos *HandshakeProtos) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.wireProtoClient = map[string]bool{}
+	c.wireProtoServer = map[string]bool{}
+	if protos == nil {
+		return
+	}
+	for route := range protos.Client {
+		c.wireProtoClient[route] = true
+	}
+	for route := range protos.Server {
+		c.wireProtoServer[route] = true
+	}
+}
+
+func (c *Connector) markProtoApp(route string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.protoApp[route] = true
+}
+
+// bridgeFor returns the message of route and whether the wire and the
+// application encodings differ, wire is the wire protobuf route set.
+func (c *Connector) bridgeFor(route string, wire map[string]bool) (proto.Message, bool, bool) {
+	c.RLock()
+	newMsg, ok := c.protoTypes[route]
+	wireProto := wire[route]
+	appProto := c.protoApp[route]
+	s, hasSerializer := c.routeSerializers[route]
+	c.RUnlock()
+	if !ok {
+		return nil, false, false
+	}
+
+	if hasSerializer {
+		switch s.(type) {
+		case *raw.Serializer:
+			// raw routes are never converted
+			return nil, false, false
+		case *protobuf.Serializer:
+			appProto = true
+		}
+	}
+	if wireProto == appProto {
+		return nil, false, false
+	}
+	return newMsg(), wireProto, true
+}
+
+// bridgeOutgoing converts the request or notify to the wire encoding
+func (c *Connector) bridgeOutgoing(msg *message.Message) {
+	c.RLock()
+	wire := c.wireProtoClient
+	c.RUnlock()
+
+	pb, toProto, ok := c.bridgeFor(msg.Route, wire)
+	if !ok {
+		return
+	}
+	if err := convert(pb, msg, toProto); err != nil {
+		c.logWarn("proto bridge failed", Field{"route", msg.Route}, Field{"bytes", len(msg.Data)}, Field{"error", err})
+	}
+}
+
+// bridgeIncoming converts the response or push to the application encoding
+func (c *Connector) bridgeIncoming(msg *message.Message) {
+	route := msg.Route
+	if msg.Type == message.Response {
+		route = c.pendingRoute(msg.ID)
+	}
+	c.RLock()
+	wire := c.wireProtoServer
+	c.RUnlock()
+
+	pb, fromProto, ok := c.bridgeFor(route, wire)
+	if !ok {
+		return
+	}
+	if err := convert(pb, msg, !fromProto); err != nil {
+		c.logWarn("proto bridge failed", Field{"route", route}, Field{"bytes", len(msg.Data)}, Field{"error", err})
+	}
+}
+
+// convert rewrites the payload of msg from JSON to protobuf or back
+func convert(pb proto.Message, msg *message.Message, toProto bool) error {
+	var (
+		data []byte
+		err  error
+	)
+	if toProto {
+		if err = protojson.Unmarshal(msg.Data, pb); err != nil {
+			return err
+		}
+		data, err = proto.Marshal(pb)
+	} else {
+		if err = proto.Unmarshal(msg.Data, pb); err != nil {
+			return err
+		}
+		data, err = protojson.Marshal(pb)
+	}
+	if err != nil {
+		return err
+	}
+	msg.Data = data
+	return nil
+}
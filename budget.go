@@ -0,0 +1,72 @@
+package client
+
+import (
+	"runtime"
+	"time"
+)
+
+// readBudget bounds the packets processed by the read loop per slice
+type readBudget struct {
+	packets int           // per slice, zero is unlimited
+	yield   time.Duration // pause between slices, zero only yields
+	hook    func(packets int, busy time.Duration)
+
+	count int           // packets of the current slice
+	busy  time.Duration // processing time of the current slice
+}
+
+// SetReadBudget limits the read loop to packets packets per scheduling
+// slice, between two slices it yields the processor, and sleeps for yield
+// when positive, so a flood of pushes can't starve the other goroutines
+// on small devices. packets <= 0 (the default) is unlimited. It must be
+// set before Run.
+func (c *Connector) SetReadBudget(packets int, yield time.Duration) {
+	c.budget.packets = packets
+	c.budget.yield = yield
+}
+
+// OnReadSlice sets the hook called by the read loop at the end of every
+// slice with its packets and the time spent processing them, handlers
+// included, for profiling the read loop. Without budget a slice is the
+// packets of one read. It must be set before Run.
+func (c *Connector) OnReadSlice(hook func(packets int, busy time.Duration)) {
+	c.budget.hook = hook
+}
+
+// budgeted reports whether the read loop counts its packets
+func (b *readBudget) budgeted() bool {
+	return b.packets > 0 || b.hook != nil
+}
+
+// processed accounts a packet processed in busy, it ends the slice once
+// the budget is spent
+func (b *readBudget) processed(busy time.Duration) {
+	b.count++
+	b.busy += busy
+	if b.packets > 0 && b.count >= b.packets {
+		b.endSlice()
+	}
+}
+
+// endRead ends the slice of a read when there is no budget
+func (b *readBudget) endRead() {
+	if b.packets <= 0 && b.count > 0 {
+		b.endSlice()
+	}
+}
+
+func (b *readBudget) endSlice() {
+	if b.hook != nil {
+		b.hook(b.count, b.busy)
+	}
+	b.count, b.busy = 0, 0
+	if b.packets <= 0 {
+		return
+	}
+	if b.yield > 0 {
+		// throttling, not timing logic, it stays on the real clock
+		time.Sleep(b.yield)
+		return
+	}
+	runtime.Gosched()
+}
@@ -0,0 +1,13 @@
+package client
+
+import (
+	"github.com/revzim/go-pomelo-client/codec"
+)
+
+// SetPacketCipher sets the cipher applied to the body of every packet sent
+// and received, nil disables it. It must be set before Run.
+func (c *Connector) SetPacketCipher(cipher codec.PacketCipher) {
+	c.cipher = cipher
+	c.encoder = codec.NewEncoder(cipher)
+	c.codec.SetCipher(c.decoderCipher())
+}
@@ -1,21 +1,77 @@
 package client
 
 import (
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/revzim/go-pomelo-client/clock"
 	"github.com/revzim/go-pomelo-client/codec"
+	"github.com/revzim/go-pomelo-client/message"
+	"github.com/revzim/go-pomelo-client/serialize"
+	"github.com/revzim/go-pomelo-client/serialize/json"
 )
 
+// tlsSessionCacheSize is the capacity of the default TLS session cache
+const tlsSessionCacheSize = 8
+
 // Callback represents the callback type which will be called
 // when the correspond events is occurred.
 type Callback func(data []byte)
 
+//go:generate mockgen -source=client.go -destination=mock/client.go -package=mock
+
+// Client is the interface implemented by *Connector, applications can
+// depend on it and use mock.MockClient in their unit tests.
+type Client interface {
+	Request(route string, data []byte, callback Callback, opts ...RequestOption) error
+	Notify(route string, data []byte) error
+	On(event string, callback Callback)
+	Off(event string)
+	Run(addr string) error
+	Close() error
+}
+
+var _ Client = (*Connector)(nil)
+
 // NewConnector create a new Connector
 func NewConnector() *Connector {
 	return &Connector{
-		die:       make(chan byte),
-		codec:     codec.NewDecoder(),
-		chSend:    make(chan []byte, 64),
-		mid:       1,
-		events:    map[string]Callback{},
-		responses: map[uint]Callback{},
+		die:              make(chan byte),
+		closeOnce:        new(sync.Once),
+		ready:            make(chan struct{}),
+		codec:            codec.NewDecoder(),
+		encoder:          codec.NewEncoder(nil),
+		chSend:           make(chan outbound, 64),
+		mid:              1,
+		events:           map[string]Callback{},
+		owners:           map[string]*Scope{},
+		transports:       map[string]Transport{},
+		sessionRoutes:    map[string][]string{},
+		streamRoutes:     map[string]string{},
+		protoTypes:       map[string]func() proto.Message{},
+		protoApp:         map[string]bool{},
+		session:          newSession(),
+		responses:        newPendingMap(),
+		routeTimeouts:    map[string]time.Duration{},
+		routeLimits:      map[string]int{},
+		routeInflight:    map[string]int{},
+		idempotentRoutes: map[string]bool{},
+		orderedRoutes:    map[string]bool{},
+		ordered:          newOrderedResponses(),
+		metrics:          nopSink{},
+		logger:           StdLogger(LogInfo),
+		stats:            newStats(),
+		clock:            clock.Real,
+		dialTimeout:      DefaultDialTimeout,
+		routeTable:       newRouteTable(),
+		serializer:       json.NewSerializer(),
+		msgCompat:        message.CompatPomelo,
+		dict:             message.NewDictionary(nil),
+		tlsSessions:      tls.NewLRUClientSessionCache(tlsSessionCacheSize),
+		routeSerializers: map[string]serialize.Serializer{},
+		errCodes:         map[int]error{},
 	}
 }
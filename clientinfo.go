@@ -0,0 +1,126 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NetworkType is the network advertised in ClientInfo
+type NetworkType string
+
+// Network types
+const (
+	NetworkWiFi     NetworkType = "wifi"
+	NetworkCellular NetworkType = "cellular"
+	NetworkEthernet NetworkType = "ethernet"
+	NetworkUnknown  NetworkType = "unknown"
+)
+
+// ClientInfo keys of the handshake user section
+const (
+	InfoDeviceID   = "deviceId"
+	InfoOS         = "os"
+	InfoOSVersion  = "osVersion"
+	InfoAppVersion = "appVersion"
+	InfoLocale     = "locale"
+	InfoNetwork    = "network"
+)
+
+var (
+	appVersionRe = regexp.MustCompile(`^\d+(\.\d+){0,3}([-+][0-9A-Za-z.-]+)?$`)
+	localeRe     = regexp.MustCompile(`^[a-z]{2,3}([-_][A-Za-z0-9]{2,8})*$`)
+)
+
+// ClientInfo builds the user section of the handshake advertising the
+// client: device, OS, app version, locale and network, e.g.
+//
+//	user, err := client.NewClientInfo().
+//		DeviceID(id).
+//		OS("android", "14").
+//		AppVersion("2.3.1").
+//		Locale("en-US").
+//		Network(client.NetworkWiFi).
+//		Build()
+//	c.InitReqHandshake(version, "android", nil, user)
+//
+// Invalid values are reported together by Build.
+type ClientInfo struct {
+	fields   map[string]interface{}
+	problems []string
+}
+
+// NewClientInfo returns an empty ClientInfo
+func NewClientInfo() *ClientInfo {
+	return &ClientInfo{fields: map[string]interface{}{}}
+}
+
+// DeviceID sets the device identifier, it must not be empty
+func (b *ClientInfo) DeviceID(id string) *ClientInfo {
+	if strings.TrimSpace(id) == "" {
+		return b.invalid("empty device id")
+	}
+	return b.Set(InfoDeviceID, id)
+}
+
+// OS sets the operating system name, lowercased, and version
+func (b *ClientInfo) OS(name, version string) *ClientInfo {
+	if name == "" {
+		return b.invalid("empty OS name")
+	}
+	b.Set(InfoOS, strings.ToLower(name))
+	if version != "" {
+		b.Set(InfoOSVersion, version)
+	}
+	return b
+}
+
+// AppVersion sets the application version, dotted numbers with an
+// optional pre-release or build suffix (e.g. "2.3.1", "2.3.1-beta.2")
+func (b *ClientInfo) AppVersion(v string) *ClientInfo {
+	if !appVersionRe.MatchString(v) {
+		return b.invalid("bad app version %q", v)
+	}
+	return b.Set(InfoAppVersion, v)
+}
+
+// Locale sets the language tag (e.g. "en", "pt-BR", "zh_Hans_CN"),
+// underscores are normalized to dashes
+func (b *ClientInfo) Locale(tag string) *ClientInfo {
+	if !localeRe.MatchString(tag) {
+		return b.invalid("bad locale %q", tag)
+	}
+	return b.Set(InfoLocale, strings.Replace(tag, "_", "-", -1))
+}
+
+// Network sets the network type
+func (b *ClientInfo) Network(t NetworkType) *ClientInfo {
+	switch t {
+	case NetworkWiFi, NetworkCellular, NetworkEthernet, NetworkUnknown:
+		return b.Set(InfoNetwork, string(t))
+	}
+	return b.invalid("bad network type %q", t)
+}
+
+// Set sets a custom field, it overrides the common ones
+func (b *ClientInfo) Set(key string, v interface{}) *ClientInfo {
+	b.fields[key] = v
+	return b
+}
+
+// Build returns the user section, or an error listing every invalid value
+func (b *ClientInfo) Build() (map[string]interface{}, error) {
+	if len(b.problems) > 0 {
+		return nil, fmt.Errorf("invalid client info: %s", strings.Join(b.problems, "; "))
+	}
+	user := make(map[string]interface{}, len(b.fields))
+	for k, v := range b.fields {
+		user[k] = v
+	}
+	return user, nil
+}
+
+func (b *ClientInfo) invalid(format string, args ...interface{}) *ClientInfo {
+	b.problems = append(b.problems, fmt.Sprintf(format, args...))
+	return b
+}
@@ -0,0 +1,19 @@
+package client
+
+import (
+	"github.com/revzim/go-pomelo-client/clock"
+)
+
+// SetClock sets the time source of the heartbeats, timeouts, circuit
+// breaker and statistics, e.g. a clock.Fake in tests. nil restores the
+// real clock. An identity provider is shared and keeps its own clock, see
+// IdentityRotation.SetClock. It must be called before Run.
+func (c *Connector) SetClock(clk clock.Clock) {
+	if clk == nil {
+		clk = clock.Real
+	}
+	c.clock = clk
+	if c.breaker != nil {
+		c.breaker.clock = clk
+	}
+}
@@ -0,0 +1,49 @@
+// Package clock abstracts the time functions used by the connector, so
+// heartbeats, timeouts and backoffs can be driven by a fake clock in
+// tests instead of sleeping.
+package clock
+
+import "time"
+
+type (
+	// Clock tells the time and schedules timers
+	Clock interface {
+		Now() time.Time
+		Since(t time.Time) time.Duration
+		Sleep(d time.Duration)
+		After(d time.Duration) <-chan time.Time
+		AfterFunc(d time.Duration, f func()) Timer
+		NewTicker(d time.Duration) Ticker
+	}
+
+	// Timer is a timer created by AfterFunc
+	Timer interface {
+		Stop() bool
+	}
+
+	// Ticker delivers ticks on C
+	Ticker interface {
+		C() <-chan time.Time
+		Stop()
+	}
+)
+
+// Real is the clock of the time package
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                            { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration           { return time.Since(t) }
+func (realClock) Sleep(d time.Duration)                     { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time    { return time.After(d) }
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+func (realClock) NewTicker(d time.Duration) Ticker          { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
@@ -0,0 +1,156 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a manually advanced clock, timers and tickers fire only when
+// Advance moves the time past their deadline.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock    *Fake
+	deadline time.Time
+	period   time.Duration // tickers only
+	fn       func()        // AfterFunc timers
+	ch       chan time.Time
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+// NewFake returns a fake clock set to now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now --
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// Since --
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Sleep blocks until the clock is advanced by d
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+// After --
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	f.add(&fakeWaiter{clock: f, deadline: f.Now().Add(d), ch: ch})
+	return ch
+}
+
+// AfterFunc calls fn in its own goroutine once the clock is advanced by d
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	w := &fakeWaiter{clock: f, deadline: f.Now().Add(d), fn: fn}
+	f.add(w)
+	return w
+}
+
+// NewTicker --
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	w := &fakeWaiter{clock: f, deadline: f.Now().Add(d), period: d, ch: make(chan time.Time, 1)}
+	f.add(w)
+	return fakeTicker{w}
+}
+
+// Advance moves the clock forward by d, firing every timer and ticker
+// due in order of deadline.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	end := f.now.Add(d)
+	for {
+		sort.Slice(f.waiters, func(i, j int) bool {
+			return f.waiters[i].deadline.Before(f.waiters[j].deadline)
+		})
+		if len(f.waiters) == 0 || f.waiters[0].deadline.After(end) {
+			break
+		}
+
+		w := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		at := w.deadline
+		f.now = at
+		if w.period > 0 {
+			w.deadline = at.Add(w.period)
+			f.waiters = append(f.waiters, w)
+		}
+		f.mu.Unlock()
+		w.fire(at)
+		f.mu.Lock()
+	}
+	f.now = end
+	f.mu.Unlock()
+}
+
+// Waiters returns the number of pending timers and tickers, handy to
+// wait for a goroutine to have armed its timer before advancing.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.waiters)
+}
+
+func (f *Fake) add(w *fakeWaiter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.waiters = append(f.waiters, w)
+}
+
+func (w *fakeWaiter) fire(now time.Time) {
+	if w.fn != nil {
+		go w.fn()
+		return
+	}
+	// like time.Ticker, a slow receiver drops ticks
+	select {
+	case w.ch <- now:
+	default:
+	}
+}
+
+// Stop --
+func (w *fakeWaiter) Stop() bool {
+	f := w.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, cur := range f.waiters {
+		if cur == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Stop --
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
+
+// C --
+func (t fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
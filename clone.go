@@ -0,0 +1,179 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/revzim/go-pomelo-client/compress"
+)
+
+// CloneConfig returns a new, unconnected connector with the same
+// handshake, serializers, handlers and options. Handlers are shared as
+// is, callbacks capturing the original connector keep referring to it.
+// The plugins are shared too, and OnInit is called again with the clone
+// so they can subscribe to its events. Other lifecycle subscribers are
+// per instance and not copied: an unsubscribe function could not reach
+// the copies, call Subscribe on the clone instead.
+func (c *Connector) CloneConfig() *Connector {
+	n := NewConnector()
+
+	n.handshakeData = c.handshakeData
+	n.handshakeVersion = c.handshakeVersion
+	n.handshakeAckData = c.handshakeAckData
+	n.handshakeAckBuilder = c.handshakeAckBuilder
+	n.handshakeGzip = c.handshakeGzip
+	n.heartbeatData = c.heartbeatData
+	n.heartbeatMode = c.heartbeatMode
+	n.heartbeatOverride = c.heartbeatOverride
+	n.heartbeatMin, n.heartbeatMax = c.heartbeatMin, c.heartbeatMax
+	n.heartbeatGrace = c.heartbeatGrace
+	n.heartbeatEcho = c.heartbeatEcho
+	n.SetPipelineOrder(c.sendOrder, c.recvOrder)
+	n.SetPacketCipher(c.cipher)
+	n.Connected(c.connectedCallback)
+	n.connectScript = append([]ConnectStep(nil), c.connectScript...)
+	n.SetClock(c.clock)
+	n.metrics = c.metrics
+	n.traffic = c.traffic
+	n.logger = c.logger
+	n.msgCompat = c.msgCompat
+	n.wsOrigin = c.wsOrigin
+	n.dialTimeout = c.dialTimeout
+	n.wsProtocols = append([]string(nil), c.wsProtocols...)
+	n.tlsConfig = c.tlsConfig
+	n.tlsSessions = c.tlsSessions
+	n.tickrate = c.tickrate
+	n.pollDispatch = c.pollDispatch
+	n.SetReadBudget(c.budget.packets, c.budget.yield)
+	n.OnReadSlice(c.budget.hook)
+	n.name = c.name
+	c.pushGate.mu.Lock()
+	n.pushGate.size = c.pushGate.size
+	c.pushGate.mu.Unlock()
+	n.SetMaxPacketSize(c.maxPacketSize)
+	n.compressors = append([]compress.Compressor(nil), c.compressors...)
+	// middlewares are shared, a SessionAware one keeps the original session
+	n.middlewares = append([]Middleware(nil), c.middlewares...)
+	// plugins are initialized again on the clone, e.g. to subscribe to
+	// its events, a plugin rejecting it is left out
+	for _, p := range c.plugins {
+		if err := p.OnInit(n); err != nil {
+			c.logWarn("plugin rejected the clone", Field{"error", err})
+			continue
+		}
+		n.subscribePlugin(p)
+		n.plugins = append(n.plugins, p)
+	}
+	n.transforms = append([]Transform(nil), c.transforms...)
+	n.connMiddlewares = append([]ConnMiddleware(nil), c.connMiddlewares...)
+	n.exchanges = c.exchanges
+	n.pushFilter = c.pushFilter
+	n.outboundFilter = c.outboundFilter
+	n.identities = c.identities
+	if c.window != nil {
+		n.SetReceiveWindow(c.window.route, c.window.size)
+	}
+
+	c.RLock()
+	n.serializer = c.serializer
+	for route, cb := range c.events {
+		n.events[route] = cb
+	}
+	for scheme, t := range c.transports {
+		n.transports[scheme] = t
+	}
+	for route, s := range c.routeSerializers {
+		n.routeSerializers[route] = s
+	}
+	for route, paths := range c.sessionRoutes {
+		n.sessionRoutes[route] = paths
+	}
+	for route, path := range c.streamRoutes {
+		n.streamRoutes[route] = path
+	}
+	for route, newMsg := range c.protoTypes {
+		n.protoTypes[route] = newMsg
+	}
+	for route := range c.protoApp {
+		n.protoApp[route] = true
+	}
+	for key, v := range c.values {
+		n.SetValue(key, v)
+	}
+	for code, err := range c.errCodes {
+		n.errCodes[code] = err
+	}
+	if d := c.duplicate; d != nil {
+		dup := *d
+		n.duplicate = &dup
+	}
+	if c.replay != nil {
+		n.SetPushReplay(c.replay.size)
+	}
+	// the unhandled push channel belongs to the original connector
+	n.unhandled = c.unhandled
+	var tasks []*scheduledTask
+	for t := range c.schedules {
+		tasks = append(tasks, t)
+	}
+	seq := c.sequencer
+	w := c.watchdog
+	c.RUnlock()
+
+	if w != nil {
+		// routes are isolated again only once they time out
+		w.mu.Lock()
+		for route, d := range w.timeouts {
+			n.SetHandlerTimeout(route, d)
+		}
+		n.OnHandlerTimeout(w.hook, w.isolate)
+		w.mu.Unlock()
+	}
+
+	// the cancel funcs stop the original tasks only
+	for _, t := range tasks {
+		n.Schedule(t.interval, t.fn)
+	}
+
+	if seq != nil {
+		// fresh sequence state, only the configuration is cloned
+		seq.mu.Lock()
+		for route, path := range seq.fields {
+			n.SetPushSequence(route, strings.Join(path, "."))
+		}
+		n.OnSequenceGap(seq.onGap)
+		seq.mu.Unlock()
+	}
+
+	c.muResponses.RLock()
+	n.requestTimeout = c.requestTimeout
+	n.replayPolicy = c.replayPolicy
+	for route := range c.idempotentRoutes {
+		n.idempotentRoutes[route] = true
+	}
+	for route := range c.orderedRoutes {
+		n.orderedRoutes[route] = true
+	}
+	for route, d := range c.routeTimeouts {
+		n.routeTimeouts[route] = d
+	}
+	for route, limit := range c.routeLimits {
+		n.routeLimits[route] = limit
+	}
+	c.muResponses.RUnlock()
+
+	if c.breaker != nil {
+		n.SetCircuitBreaker(c.breaker.threshold, c.breaker.cooldown)
+	}
+	if c.admission != nil {
+		n.SetMaxInflight(cap(c.admission))
+	}
+	if c.dedup != nil {
+		n.SetNotifyDedup(c.dedup.window)
+	}
+	n.slowThreshold = c.slowThreshold
+	n.slowHook = c.slowHook
+	n.orphanHook = c.orphanHook
+	n.messageHook = c.messageHook
+
+	return n
+}
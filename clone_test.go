@@ -0,0 +1,82 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// subscribingPlugin subscribes to the events of every connector it is
+// initialized with
+type subscribingPlugin struct {
+	PluginBase
+	mu      sync.Mutex
+	inits   []*Connector
+	connect []*Connector // OnConnect
+	events  []*Connector // EventHandshakeComplete
+	reject  bool
+}
+
+func (p *subscribingPlugin) OnInit(c *Connector) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.reject && len(p.inits) > 0 {
+		return errors.New("single connector plugin")
+	}
+	p.inits = append(p.inits, c)
+	c.Subscribe(func(LifecycleEvent) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.events = append(p.events, c)
+	}, EventHandshakeComplete)
+	return nil
+}
+
+func (p *subscribingPlugin) OnConnect(c *Connector) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.connect = append(p.connect, c)
+}
+
+func TestCloneConfigInitsPlugins(t *testing.T) {
+	p := &subscribingPlugin{}
+	c := newTestConnector(t)
+	if err := c.Use(p); err != nil {
+		t.Fatal(err)
+	}
+	n := c.CloneConfig()
+	defer n.Close()
+	if len(n.plugins) != 1 {
+		t.Fatalf("%d plugins on the clone", len(n.plugins))
+	}
+
+	s := newTestServer(t, nil)
+	runConnector(t, n, s.addr())
+	s.next()
+	waitFor(t, "clone events", func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return len(p.events) == 1 && len(p.connect) == 1
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.inits) != 2 || p.inits[1] != n {
+		t.Fatalf("inits %v, want the original then the clone", p.inits)
+	}
+	if p.events[0] != n || p.connect[0] != n {
+		t.Fatal("events not from the clone")
+	}
+}
+
+func TestCloneConfigPluginRejectsClone(t *testing.T) {
+	p := &subscribingPlugin{reject: true}
+	c := newTestConnector(t)
+	if err := c.Use(p); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.CloneConfig(); len(n.plugins) != 0 {
+		t.Fatal("rejected plugin kept on the clone")
+	}
+}
@@ -0,0 +1,133 @@
+// Command pomelo-cli inspects pomelo servers.
+//
+//	pomelo-cli routes --addr ws://127.0.0.1:3010
+//
+// connects, dumps the handshake response (heartbeat, version, user data),
+// the route dictionary and the protobuf schemas announced by the server.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	client "github.com/revzim/go-pomelo-client"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "pomelo-cli"
+	app.Usage = "inspect pomelo servers"
+	app.Commands = []cli.Command{
+		{
+			Name:  "routes",
+			Usage: "connect and print the handshake, route dictionary and protobuf schemas",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "addr", Value: "tcp://127.0.0.1:3010", Usage: "server address, tcp, tls, ws or wss"},
+				cli.StringFlag{Name: "version", Usage: "handshake sys.version, empty skips the version check"},
+				cli.StringFlag{Name: "type", Value: "pomelo-cli", Usage: "handshake sys.type"},
+				cli.DurationFlag{Name: "timeout", Value: 10 * time.Second, Usage: "handshake timeout"},
+			},
+			Action: routes,
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func routes(ctx *cli.Context) error {
+	c := client.NewConnector()
+	if err := c.InitReqHandshake(ctx.String("version"), ctx.String("type"), nil, nil); err != nil {
+		return err
+	}
+	if err := c.InitHandshakeACK(1); err != nil {
+		return err
+	}
+	defer c.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Run(ctx.String("addr"))
+	}()
+	select {
+	case <-c.Ready():
+	case err := <-errCh:
+		return err
+	case <-time.After(ctx.Duration("timeout")):
+		return errors.New("handshake timeout")
+	}
+
+	resp, ok := c.HandshakeResponse()
+	if !ok {
+		return errors.New("no handshake response")
+	}
+	return printHandshake(os.Stdout, resp)
+}
+
+func printHandshake(w io.Writer, resp client.DefaultHandshakePacket) error {
+	fmt.Fprintf(w, "code:      %d\n", resp.Code)
+	fmt.Fprintf(w, "version:   %s\n", resp.Sys.Version)
+	fmt.Fprintf(w, "heartbeat: %ds\n", resp.Sys.Heartbeat)
+	if len(resp.User) > 0 {
+		if err := printJSON(w, "user:", resp.User); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "\nroute dictionary (%d):\n", len(resp.Sys.Dict))
+	names := make([]string, 0, len(resp.Sys.Dict))
+	for route := range resp.Sys.Dict {
+		names = append(names, route)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return resp.Sys.Dict[names[i]] < resp.Sys.Dict[names[j]]
+	})
+	for _, route := range names {
+		fmt.Fprintf(w, "  %5d  %s\n", resp.Sys.Dict[route], route)
+	}
+
+	protos := resp.Sys.Protos
+	if protos == nil {
+		fmt.Fprintln(w, "\nno protobuf schemas")
+		return nil
+	}
+	if protos.Version != nil {
+		fmt.Fprintf(w, "\nprotos version: %v\n", protos.Version)
+	}
+	if err := printSchemas(w, "client", protos.Client); err != nil {
+		return err
+	}
+	return printSchemas(w, "server", protos.Server)
+}
+
+func printSchemas(w io.Writer, side string, schemas map[string]json.RawMessage) error {
+	fmt.Fprintf(w, "\n%s protos (%d):\n", side, len(schemas))
+	routes := make([]string, 0, len(schemas))
+	for route := range schemas {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		if err := printJSON(w, "  "+route+":", schemas[route]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printJSON(w io.Writer, title string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "    ", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n    %s\n", title, data)
+	return err
+}
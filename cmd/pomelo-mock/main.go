@@ -0,0 +1,56 @@
+// Command pomelo-mock serves a recorded session, the requests are answered
+// with the responses of a session written by mockserver.Recorder or of an
+// exchange log written by Connector.SetExchangeLog.
+//
+//	pomelo-mock --log exchanges.ndjson --addr 127.0.0.1:3010
+//
+// clients then connect to tcp://127.0.0.1:3010.
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/revzim/go-pomelo-client/mockserver"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "pomelo-mock"
+	app.Usage = "answer pomelo requests with the responses of a recorded session"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "log", Usage: "NDJSON file of the recorded session"},
+		cli.StringFlag{Name: "addr", Value: "127.0.0.1:3010", Usage: "tcp listen address"},
+		cli.IntFlag{Name: "heartbeat", Usage: "handshake heartbeat interval in seconds, zero disables it"},
+		cli.StringFlag{Name: "miss", Usage: "response to the requests without recorded response"},
+	}
+	app.Action = serve
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func serve(ctx *cli.Context) error {
+	if ctx.String("log") == "" {
+		return errors.New("--log is required")
+	}
+	f, err := os.Open(ctx.String("log"))
+	if err != nil {
+		return err
+	}
+	s, err := mockserver.New(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	s.SetHeartbeat(ctx.Int("heartbeat"))
+	if miss := ctx.String("miss"); miss != "" {
+		s.SetMiss([]byte(miss))
+	}
+	log.Printf("serving %d recorded routes on %s", s.Routes(), ctx.String("addr"))
+	return s.ListenAndServe(ctx.String("addr"))
+}
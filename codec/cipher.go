@@ -0,0 +1,35 @@
+package codec
+
+// PacketCipher encrypts and decrypts packet bodies, it is applied to every
+// packet by Encoder.Encode and Decoder.Decode. The packet type is passed so
+// an implementation can leave some packets (e.g. handshake) in clear.
+type PacketCipher interface {
+	Encrypt(typ byte, body []byte) ([]byte, error)
+	Decrypt(typ byte, body []byte) ([]byte, error)
+}
+
+// Encoder encodes packets, encrypting their bodies with an optional cipher
+type Encoder struct {
+	cipher PacketCipher
+}
+
+// NewEncoder returns a new encoder, cipher may be nil
+func NewEncoder(cipher PacketCipher) *Encoder {
+	return &Encoder{cipher: cipher}
+}
+
+// Encode encrypts data and encodes it to network bytes slice
+func (e *Encoder) Encode(typ byte, data []byte) ([]byte, error) {
+	if e.cipher != nil {
+		var err error
+		if data, err = e.cipher.Encrypt(typ, data); err != nil {
+			return nil, err
+		}
+	}
+	return Encode(typ, data)
+}
+
+// SetCipher sets the cipher decrypting the decoded packet bodies
+func (c *Decoder) SetCipher(cipher PacketCipher) {
+	c.cipher = cipher
+}
@@ -9,16 +9,46 @@ import (
 // NewDecoder -- returns a new decoder that used for decode network bytes slice.
 func NewDecoder() *Decoder {
 	return &Decoder{
-		buf:  bytes.NewBuffer(nil),
-		size: -1,
+		buf:     bytes.NewBuffer(nil),
+		size:    -1,
+		maxSize: MaxPacketSize,
 	}
 }
 
 // Decoder -- reads and decodes network data slice
 type Decoder struct {
-	buf  *bytes.Buffer
-	size int  // last packet length
-	typ  byte // last packet type
+	buf     *bytes.Buffer
+	size    int          // last packet length
+	typ     byte         // last packet type
+	cipher  PacketCipher // optional body cipher
+	maxSize int          // packet length limitation
+}
+
+// SetMaxPacketSize sets the largest packet body accepted, MaxPacketSize
+// by default. It is capped to MaxFrameSize, n <= 0 restores the default.
+func (c *Decoder) SetMaxPacketSize(n int) {
+	switch {
+	case n <= 0:
+		n = MaxPacketSize
+	case n > MaxFrameSize:
+		n = MaxFrameSize
+	}
+	c.maxSize = n
+}
+
+// Missing returns the number of bytes still needed to complete the
+// buffered packet, its header included when it is not buffered yet.
+func (c *Decoder) Missing() int {
+	if c.size < 0 {
+		if n := HeadLength - c.buf.Len(); n > 0 {
+			return n
+		}
+		return 0
+	}
+	if n := c.size - c.buf.Len(); n > 0 {
+		return n
+	}
+	return 0
 }
 
 func (c *Decoder) forward() error {
@@ -30,13 +60,14 @@ func (c *Decoder) forward() error {
 	c.size = bytesToInt(header[1:])
 
 	// packet length limitation
-	if c.size > MaxPacketSize {
+	if c.size > c.maxSize {
 		return ErrPacketSizeExcced
 	}
 	return nil
 }
 
-// Decode decode the network bytes slice to packet.Packet(s)
+// Decode decode the network bytes slice to packet.Packet(s), on error
+// the packets decoded before the malformed one are returned with it.
 // TODO(Warning): shared slice
 func (c *Decoder) Decode(data []byte) ([]*packet.Packet, error) {
 	c.buf.Write(data)
@@ -59,6 +90,11 @@ func (c *Decoder) Decode(data []byte) ([]*packet.Packet, error) {
 
 	for c.size <= c.buf.Len() {
 		p := &packet.Packet{Type: byte(c.typ), Length: c.size, Data: c.buf.Next(c.size)}
+		if c.cipher != nil {
+			if p.Data, err = c.cipher.Decrypt(p.Type, p.Data); err != nil {
+				return packets, err
+			}
+		}
 		packets = append(packets, p)
 
 		// more packet
@@ -68,8 +104,7 @@ func (c *Decoder) Decode(data []byte) ([]*packet.Packet, error) {
 		}
 
 		if err = c.forward(); err != nil {
-			return nil, err
-
+			return packets, err
 		}
 
 	}
@@ -0,0 +1,82 @@
+package codec
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+// Target budget, measured with go test -bench . on a 4 core x86-64 VM:
+//
+//	BenchmarkEncode/64B       < 100 ns/op, 1 alloc
+//	BenchmarkEncode/4KB       < 1.5 us/op, 1 alloc
+//	BenchmarkDecode/64B       < 200 ns/op, 2 allocs
+//	BenchmarkDecode/4KB       < 300 ns/op, 2 allocs
+//	BenchmarkDecodeSplit/4KB  < 1.5 us/op (packet split across 512 bytes reads)
+//
+// A change on the hot path exceeding the budget needs a justification.
+
+var payloadSizes = []int{64, 512, 4 << 10, 60 << 10}
+
+func sizeName(n int) string {
+	if n >= 1<<10 {
+		return fmt.Sprintf("%dKB", n>>10)
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+func BenchmarkEncode(b *testing.B) {
+	for _, size := range payloadSizes {
+		data := make([]byte, size)
+		b.Run(sizeName(size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Encode(packet.Data, data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, size := range payloadSizes {
+		frame, _ := Encode(packet.Data, make([]byte, size))
+		b.Run(sizeName(size), func(b *testing.B) {
+			dec := NewDecoder()
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := dec.Decode(frame); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDecodeSplit decodes packets arriving in 512 bytes reads
+func BenchmarkDecodeSplit(b *testing.B) {
+	const chunk = 512
+	for _, size := range payloadSizes[1:] {
+		frame, _ := Encode(packet.Data, make([]byte, size))
+		b.Run(sizeName(size), func(b *testing.B) {
+			dec := NewDecoder()
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for off := 0; off < len(frame); off += chunk {
+					end := off + chunk
+					if end > len(frame) {
+						end = len(frame)
+					}
+					if _, err := dec.Decode(frame[off:end]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -4,4 +4,7 @@ package codec
 const (
 	HeadLength    = 4
 	MaxPacketSize = 64 * 1024
+
+	// MaxFrameSize is the largest length the 3 bytes header can declare
+	MaxFrameSize = 1<<24 - 1
 )
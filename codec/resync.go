@@ -0,0 +1,39 @@
+package codec
+
+import (
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+// Resync drops the buffered bytes up to the next plausible packet header
+// after a Decode error, Decode(nil) then continues from it. A header is
+// plausible when its type and length are valid and, if the packet is
+// fully buffered, it is followed by another valid type or the end of the
+// buffer. Resync returns the number of dropped bytes, and false when no
+// header was found, the buffer is then empty.
+func (c *Decoder) Resync() (int, bool) {
+	c.size = -1
+	data := c.buf.Bytes()
+
+	for i := 0; i+HeadLength <= len(data); i++ {
+		if !c.validHeader(data[i:]) {
+			continue
+		}
+		next := i + HeadLength + bytesToInt(data[i+1:i+HeadLength])
+		if next < len(data) && !validType(data[next]) {
+			continue
+		}
+		c.buf.Next(i)
+		return i, true
+	}
+
+	c.buf.Reset()
+	return len(data), false
+}
+
+func (c *Decoder) validHeader(b []byte) bool {
+	return validType(b[0]) && bytesToInt(b[1:HeadLength]) <= c.maxSize
+}
+
+func validType(typ byte) bool {
+	return typ >= packet.Handshake && typ <= packet.Kick
+}
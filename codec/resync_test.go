@@ -0,0 +1,83 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+func frame(t *testing.T, typ byte, data string) []byte {
+	t.Helper()
+
+	b, err := Encode(typ, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestResyncSkipsGarbage(t *testing.T) {
+	var stream []byte
+	stream = append(stream, frame(t, packet.Data, "first")...)
+	stream = append(stream, 0x09, 0xFF, 0xFF, 0xFF, 0x09, 0x09)
+	stream = append(stream, frame(t, packet.Data, "second")...)
+	stream = append(stream, frame(t, packet.Heartbeat, "")...)
+
+	dec := NewDecoder()
+	packets, err := dec.Decode(stream)
+	if err == nil {
+		t.Fatal("garbage decoded")
+	}
+	if len(packets) != 1 || string(packets[0].Data) != "first" {
+		t.Fatalf("packets before the garbage %v", packets)
+	}
+
+	skipped, ok := dec.Resync()
+	if !ok {
+		t.Fatal("no header found")
+	}
+	// the header of the garbage was consumed by Decode
+	if skipped != 2 {
+		t.Fatalf("skipped %d bytes, want 2", skipped)
+	}
+	packets, err = dec.Decode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 2 || string(packets[0].Data) != "second" || packets[1].Type != packet.Heartbeat {
+		t.Fatalf("packets after the garbage %v", packets)
+	}
+}
+
+func TestResyncRejectsImplausibleHeader(t *testing.T) {
+	// a valid type with a length reaching an invalid type is not a header
+	stream := []byte{0x09, 0, 0, 0, packet.Data, 0, 0, 1, 'x', 0x09}
+	stream = append(stream, frame(t, packet.Data, "ok")...)
+
+	dec := NewDecoder()
+	if _, err := dec.Decode(stream); err == nil {
+		t.Fatal("garbage decoded")
+	}
+	if _, ok := dec.Resync(); !ok {
+		t.Fatal("no header found")
+	}
+	packets, err := dec.Decode(nil)
+	if err != nil || len(packets) != 1 || string(packets[0].Data) != "ok" {
+		t.Fatalf("packets %v, %v", packets, err)
+	}
+}
+
+func TestResyncWithoutHeader(t *testing.T) {
+	dec := NewDecoder()
+	garbage := bytes.Repeat([]byte{0x09}, 12)
+	if _, err := dec.Decode(garbage); err == nil {
+		t.Fatal("garbage decoded")
+	}
+	if skipped, ok := dec.Resync(); ok || skipped != len(garbage)-HeadLength {
+		t.Fatalf("resync skipped %d bytes, %v", skipped, ok)
+	}
+	if n := dec.Missing(); n != HeadLength {
+		t.Fatalf("missing %d bytes after the reset, want a header", n)
+	}
+}
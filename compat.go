@@ -0,0 +1,33 @@
+package client
+
+import (
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+// SetMessageCompat selects the message header variant spoken by the
+// server, message.CompatPomelo by default. Use a preset or set the flags
+// individually to match a pomelo fork, e.g.
+//
+//	compat := message.CompatPomelo
+//	compat.WideRoute = true
+//	c.SetMessageCompat(compat)
+//
+// Without the gzip bit payloads are never compressed, whatever the
+// compressor negotiated in the handshake. It must be called before Run.
+func (c *Connector) SetMessageCompat(compat message.Compat) {
+	c.msgCompat = compat
+}
+
+// RouteDictionary returns a copy of the route dictionary sent by the
+// server in the handshake of the connection (sys.dict)
+func (c *Connector) RouteDictionary() map[string]uint16 {
+	return c.dict.Routes()
+}
+
+// codecCompat returns the header variant with the route dictionary of
+// the connection
+func (c *Connector) codecCompat() message.Compat {
+	compat := c.msgCompat
+	compat.Dict = c.dict
+	return compat
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/revzim/go-pomelo-client/message"
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+func TestMessageCompatPush(t *testing.T) {
+	c := NewConnector()
+	c.SetLogger(nopLogger{})
+	c.SetMessageCompat(message.CompatWide)
+	var got string
+	c.On("onChat", func(data []byte) { got = string(data) })
+
+	data, err := message.EncodeCompat(&message.Message{Type: message.Push, Route: "onChat", Data: []byte(`{}`)}, message.CompatWide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.InjectPacket(&packet.Packet{Type: packet.Data, Length: len(data), Data: data})
+	if got != `{}` {
+		t.Fatalf("push %q", got)
+	}
+
+	// a 1 byte route length is misread by the wide header
+	got = ""
+	c.InjectPacket(dataPacket(t, &message.Message{Type: message.Push, Route: "onChat", Data: []byte(`{}`)}))
+	if got != "" {
+		t.Fatal("pomelo header decoded as wide")
+	}
+}
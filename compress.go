@@ -0,0 +1,87 @@
+package client
+
+import (
+	"github.com/revzim/go-pomelo-client/compress"
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+// Handshake user data keys used to negotiate the payload compression
+const (
+	HandshakeUserCompressions = "compressions" // names offered by the client
+	HandshakeUserCompression  = "compression"  // name chosen by the server
+)
+
+// SetCompressors sets the compressors offered to the server in the
+// handshake user data, in order of preference. The server picks one by
+// answering its name in the handshake response user data, payloads are
+// then compressed in both directions.
+func (c *Connector) SetCompressors(cs ...compress.Compressor) {
+	c.compressors = cs
+}
+
+func (c *Connector) activeCompressor() compress.Compressor {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	return c.compressor
+}
+
+// selectCompressor picks the compressor chosen in the handshake response
+func (c *Connector) selectCompressor(resp *DefaultHandshakePacket) {
+	name, _ := resp.User[HandshakeUserCompression].(string)
+
+	var chosen compress.Compressor
+	for _, comp := range c.compressors {
+		if comp.Name() == name {
+			chosen = comp
+			break
+		}
+	}
+	if name != "" && chosen == nil {
+		c.logWarn("server chose an unknown compression", Field{"compression", name})
+	}
+
+	c.muConn.Lock()
+	c.compressor = chosen
+	c.muConn.Unlock()
+}
+
+// offeredCompressions returns the names of the offered compressors
+func (c *Connector) offeredCompressions() []string {
+	names := make([]string, len(c.compressors))
+	for i, comp := range c.compressors {
+		names[i] = comp.Name()
+	}
+	return names
+}
+
+func (c *Connector) compressMessage(msg *message.Message) error {
+	comp := c.activeCompressor()
+	if comp == nil || len(msg.Data) == 0 || !c.msgCompat.GzipBit {
+		return nil
+	}
+	data, err := comp.Compress(msg.Data)
+	if err != nil {
+		return err
+	}
+	msg.Data = data
+	msg.DataCompressed = true
+	return nil
+}
+
+func (c *Connector) decompressMessage(msg *message.Message) error {
+	if !msg.DataCompressed {
+		return nil
+	}
+	comp := c.activeCompressor()
+	if comp == nil {
+		return ErrNoCompressor
+	}
+	data, err := comp.Decompress(msg.Data)
+	if err != nil {
+		return err
+	}
+	msg.Data = data
+	msg.DataCompressed = false
+	return nil
+}
@@ -0,0 +1,9 @@
+package compress
+
+// Compressor compresses message payloads, Name is the identifier
+// negotiated with the server through the handshake user data.
+type Compressor interface {
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
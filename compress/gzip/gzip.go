@@ -0,0 +1,43 @@
+package gzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+// Compressor implements the compress.Compressor interface
+type Compressor struct{}
+
+// NewCompressor returns a new Compressor.
+func NewCompressor() *Compressor {
+	return &Compressor{}
+}
+
+// Name --
+func (c *Compressor) Name() string {
+	return "gzip"
+}
+
+// Compress --
+func (c *Compressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress --
+func (c *Compressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,28 @@
+package snappy
+
+import (
+	"github.com/golang/snappy"
+)
+
+// Compressor implements the compress.Compressor interface
+type Compressor struct{}
+
+// NewCompressor returns a new Compressor.
+func NewCompressor() *Compressor {
+	return &Compressor{}
+}
+
+// Name --
+func (c *Compressor) Name() string {
+	return "snappy"
+}
+
+// Compress --
+func (c *Compressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+// Decompress --
+func (c *Compressor) Decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
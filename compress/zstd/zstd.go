@@ -0,0 +1,39 @@
+package zstd
+
+import (
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressor implements the compress.Compressor interface
+type Compressor struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+// NewCompressor returns a new Compressor.
+func NewCompressor() (*Compressor, error) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Compressor{enc: enc, dec: dec}, nil
+}
+
+// Name --
+func (c *Compressor) Name() string {
+	return "zstd"
+}
+
+// Compress --
+func (c *Compressor) Compress(data []byte) ([]byte, error) {
+	return c.enc.EncodeAll(data, nil), nil
+}
+
+// Decompress --
+func (c *Compressor) Decompress(data []byte) ([]byte, error) {
+	return c.dec.DecodeAll(data, nil)
+}
@@ -1,53 +1,177 @@
 package client
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
-	"log"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/revzim/go-pomelo-client/clock"
 	"github.com/revzim/go-pomelo-client/codec"
+	"github.com/revzim/go-pomelo-client/compress"
 	"github.com/revzim/go-pomelo-client/message"
 	"github.com/revzim/go-pomelo-client/packet"
+	"github.com/revzim/go-pomelo-client/serialize"
 )
 
+// readBufferSize is the read loop buffer size, many small packets are
+// read in one syscall
+const readBufferSize = 16 << 10
+
 type (
 	// Connector is a Pomelo [nano] client
 	Connector struct {
+		mid               uint64         // next message id, atomic, first for 64-bit alignment
 		conn              net.Conn       // low-level connection
 		codec             *codec.Decoder // decoder
-		mid               uint           // message id
 		muConn            sync.RWMutex
-		connecting        bool        // connection status
-		die               chan byte   // connector close channel
-		chSend            chan []byte // send queue
+		connecting        bool           // connection status
+		closeOnce         *sync.Once     // guards Close
+		gen               uint64         // connection generation, bumped by Reset
+		loops             sync.WaitGroup // goroutines of the connection, waited by Reset
+		closeErr          error          // error of closing the connection
+		lastErr           error          // reason the connector was closed
+		disconnectReason  DisconnectReason
+		ready             chan struct{} // closed once the connection is usable
+		draining          int32         // set while draining, atomic
+		unflushed         int64         // packets queued or in a batch not written yet, atomic
+		die               chan byte     // connector close channel
+		chSend            chan outbound // send queue
 		connectedCallback func()
+		connectedUnsub    func()                // removes connectedCallback from the bus
+		connectScript     []ConnectStep         // requests run after the handshake
+		metrics           MetricsSink           // metrics sink
+		clock             clock.Clock           // time source
+		traffic           TrafficObserver       // byte accounting
+		logger            Logger                // logger
+		stats             *stats                // statistics
+		routeTable        *routeTable           // routes seen
+		wsOrigin          string                // websocket origin
+		dialTimeout       time.Duration         // tcp resolution and connection
+		wsProtocols       []string              // websocket subprotocols
+		compressors       []compress.Compressor // offered compressors
+		compressor        compress.Compressor   // negotiated compressor
+		serializer        serialize.Serializer  // default serializer
+		msgCompat         message.Compat        // message header variant
+		dict              *message.Dictionary   // route dictionary of the connection
 
 		// some packet data
-		handshakeData    []byte // handshake data
-		handshakeAckData []byte // handshake ack data
-		heartbeatData    []byte // heartbeat data
+		handshakeData       []byte // handshake body
+		handshakeVersion    string // sys.version sent in the handshake
+		handshakeAckData    []byte // handshake ack body
+		handshakeAckBuilder func(resp *DefaultHandshakePacket) (*HandshakeAck, error)
+		handshakeAckSent    []byte // ack body of the connection, guarded by muConn
+		handshakeGzip       bool   // handshake user data compressed
+		heartbeatData       []byte // heartbeat body
+		heartbeatMode       HeartbeatMode
+		heartbeatOverride   time.Duration // replaces the advertised interval
+		heartbeatMin        time.Duration // advertised interval clamp
+		heartbeatMax        time.Duration
+		heartbeatAt         int64 // last server heartbeat, unix nano, atomic
+		heartbeatInterval   int64 // negotiated heartbeat interval, atomic
+		packetAt            int64 // last received packet, unix nano, atomic
+		streamAt            int64 // last bytes of a partial packet, unix nano, atomic
+		heartbeatGrace      time.Duration
+		heartbeatEcho       bool // heartbeats carry a nonce echoed by the server
+		echo                heartbeatEcho
+		sendOrder           PipelineOrder // compression and encryption order
+		recvOrder           PipelineOrder
+		cipher              codec.PacketCipher
+		encoder             *codec.Encoder
+		middlewares         []Middleware
+		transports          map[string]Transport // registered transports, by scheme
+		connMiddlewares     []ConnMiddleware     // stacked around the transports
+		exchanges           *exchangeLog         // request/response records, nil when off
+		pushFilter          *routeFilter         // inbound push routes, nil passes all
+		outboundFilter      *routeFilter         // request and notify routes, nil passes all
+		tlsConfig           *tls.Config
+		tlsSessions         tls.ClientSessionCache // resumed across reconnects
+		preconn             *preconnect            // warm connection, guarded by muConn
+		tickrate            int64                  // max reads per second, zero is unlimited
+		budget              readBudget             // packets processed per read loop slice
+		lifecycle           lifecycleBus           // lifecycle event subscribers
+		plugins             []Plugin
+		acks                ackWaits       // notifies waiting for their ack push
+		window              *receiveWindow // push credit, nil when off
+		identities          IdentityProvider
+		identity            *Identity       // identity of the connection, guarded by muConn
+		pollDispatch        bool            // callbacks queued for Poll
+		polled              pollQueue       // callbacks waiting for Poll
+		name                string          // profiler label
+		labels              context.Context // profiler labels of the connection, guarded by muConn
+		connCtx             context.Context // connection context, guarded by muConn
+		connCancel          context.CancelFunc
+		connAddr            string
+		maxPacketSize       int // largest packet body read, zero is the codec default
+		transforms          []Transform
+		serverVersion       string                  // sys.version of the handshake response
+		handshakeResp       *DefaultHandshakePacket // last accepted handshake response
+		session             *Session                // server assigned identifiers
+		report              *ConnectReport          // last connection attempt, guarded by muConn
 
 		// events handler
 		sync.RWMutex
-		events map[string]Callback
+		events           map[string]Callback
+		owners           map[string]*Scope               // scope of the scoped handlers
+		replay           *replayBuffer                   // pushes waiting for a late handler
+		routeSerializers map[string]serialize.Serializer // per route serializer
+		errCodes         map[int]error                   // registered response codes
+		sequencer        *pushSequencer                  // push sequence tracking
+		sessionRoutes    map[string][]string             // session fields per route
+		streamRoutes     map[string]string               // follow-up push route => mid field of streamed responses
+		protoTypes       map[string]func() proto.Message // bridged protobuf routes
+		protoApp         map[string]bool                 // routes handled as protobuf
+		wireProtoClient  map[string]bool                 // routes sent in protobuf
+		wireProtoServer  map[string]bool                 // routes received in protobuf
+		duplicate        *duplicateLogin                 // duplicate login detection
+		unhandled        func(route string, data []byte) // catch-all push handler
+		unhandledCh      chan UnhandledPush              // unhandled push channel
+		schedules        map[*scheduledTask]struct{}     // tasks run while connected
+		pushGate         pushGate                        // pushes held while paused
+		watchdog         *handlerWatchdog                // push handler timeouts
+		values           map[interface{}]interface{}     // connection context values
 
 		// response handler
-		muResponses sync.RWMutex
-		responses   map[uint]Callback
+		muResponses      sync.RWMutex
+		responses        *pendingMap
+		admission        chan struct{}            // connector wide in-flight slots
+		requestTimeout   time.Duration            // default response timeout
+		routeTimeouts    map[string]time.Duration // per route response timeout
+		breaker          *circuitBreaker          // per route circuit breaker
+		dedup            *notifyDedup             // recent notifies
+		routeLimits      map[string]int           // per route max in-flight requests
+		routeInflight    map[string]int           // per route in-flight requests
+		slowThreshold    time.Duration            // slow request threshold
+		slowHook         func(route string, mid uint, elapsed time.Duration)
+		orphanHook       func(mid uint, data []byte)
+		messageHook      func(InboundMessage)
+		orphans          uint64 // responses without pending request, atomic
+		replayPolicy     ReplayPolicy
+		idempotentRoutes map[string]bool   // routes safe to re-send
+		replayQueue      []*pendingRequest // unanswered requests to re-send
+		orderedRoutes    map[string]bool   // routes with ordered callbacks
+		ordered          *orderedResponses
 	}
 	// DefaultACK --
 	DefaultHandshakePacket struct {
-		Code int              `json:"code"`
-		Sys  HeartbeatSysOpts `json:"sys"`
+		Code int                    `json:"code"`
+		Sys  HeartbeatSysOpts       `json:"sys"`
+		User map[string]interface{} `json:"user,omitempty"`
 	}
 	// HeartbeatSysOpts --
 	HeartbeatSysOpts struct {
-		Heartbeat int `json:"heartbeat"`
+		Heartbeat int               `json:"heartbeat"`
+		Version   string            `json:"version,omitempty"`
+		Dict      map[string]uint16 `json:"dict,omitempty"` // route dictionary
+		Protos    *HandshakeProtos  `json:"protos,omitempty"`
+		Gzip      bool              `json:"gzip,omitempty"` // user data compressed
 	}
 
 	// SysOpts --
@@ -55,6 +179,7 @@ type (
 		Version string                 `json:"version"`
 		Type    string                 `json:"type"`
 		RSA     map[string]interface{} `json:"rsa"`
+		Gzip    bool                   `json:"gzip,omitempty"` // user data compressed, see SetHandshakeGzip
 	}
 
 	// HandshakeOpts --
@@ -71,22 +196,19 @@ func (c *Connector) SetHandshake(handshake interface{}) error {
 		return err
 	}
 
-	c.handshakeData, err = codec.Encode(packet.Handshake, data)
-	if err != nil {
-		return err
+	var opts HandshakeOpts
+	if err := json.Unmarshal(data, &opts); err == nil {
+		c.handshakeVersion = opts.Sys.Version
 	}
 
+	c.handshakeData = data
 	return nil
 }
 
 // SetHandshakeAck --
 func (c *Connector) SetHandshakeAck(handshakeAck interface{}) error {
-	var err error
 	if handshakeAck == nil {
-		c.handshakeAckData, err = codec.Encode(packet.HandshakeAck, nil)
-		if err != nil {
-			return err
-		}
+		c.handshakeAckData = nil
 		return nil
 	}
 
@@ -95,22 +217,14 @@ func (c *Connector) SetHandshakeAck(handshakeAck interface{}) error {
 		return err
 	}
 
-	c.handshakeAckData, err = codec.Encode(packet.HandshakeAck, data)
-	if err != nil {
-		return err
-	}
-
+	c.handshakeAckData = data
 	return nil
 }
 
 // SetHeartBeat --
 func (c *Connector) SetHeartBeat(heartbeat interface{}) error {
-	var err error
 	if heartbeat == nil {
-		c.heartbeatData, err = codec.Encode(packet.Heartbeat, nil)
-		if err != nil {
-			return err
-		}
+		c.heartbeatData = nil
 		return nil
 	}
 	data, err := json.Marshal(heartbeat)
@@ -118,17 +232,22 @@ func (c *Connector) SetHeartBeat(heartbeat interface{}) error {
 		return err
 	}
 
-	c.heartbeatData, err = codec.Encode(packet.Heartbeat, data)
-	if err != nil {
-		return err
-	}
-
+	c.heartbeatData = data
 	return nil
 }
 
-// Connected --
+// Connected sets the callback fired once a connection is ready, it
+// subscribes to EventHandshakeComplete and replaces the callback of a
+// previous call, nil removes it. It must be set before Run.
 func (c *Connector) Connected(cb func()) {
+	if c.connectedUnsub != nil {
+		c.connectedUnsub()
+		c.connectedUnsub = nil
+	}
 	c.connectedCallback = cb
+	if cb != nil {
+		c.connectedUnsub = c.Subscribe(func(LifecycleEvent) { cb() }, EventHandshakeComplete)
+	}
 }
 
 // InitReqHandshake --
@@ -159,100 +278,340 @@ func (c *Connector) InitHandshakeACK(heartbeatDuration int) error {
 	return c.SetHandshakeAck(ackDataMap)
 }
 
-// Run --
-func (c *Connector) Run(addr string, ws bool, tickrate int64) error {
+// Run connects to addr and reads until the connection is closed, the
+// scheme of addr selects the transport: tcp://host:port (the default
+// without scheme), tls://host:port, ws://host/path, wss://host/path,
+// srv://_pomelo._tcp.example.com and srv+tls://... (SRV discovery, see
+// ResolveSRV) or a scheme registered with RegisterTransport.
+func (c *Connector) Run(addr string) error {
+	return c.runLabeled(addr, func() error {
+		return c.run(addr)
+	})
+}
+
+func (c *Connector) run(addr string) error {
 	if c.handshakeData == nil {
 		return errors.New("handshake not defined")
 	}
 
-	if c.handshakeAckData == nil {
-		err := c.SetHandshakeAck(nil)
-		if err != nil {
-			return err
-		}
+	if c.isDead() {
+		return ErrConnectorClosed
 	}
 
-	if c.heartbeatData == nil {
-		err := c.SetHeartBeat(nil)
-		if err != nil {
-			return err
-		}
+	c.beginReport(addr)
+	c.beginContext(addr)
+	c.countAttempt()
+	if err := c.acquireIdentity(); err != nil {
+		c.failReport(err)
+		return err
 	}
+	var phases ConnectReport
 	var err error
-	var conn net.Conn
-	if ws {
-		conn, err = websocket.Dial(addr, addr, addr)
-	} else {
-		conn, err = net.Dial("tcp", addr)
+	conn := c.preconnected(addr, &phases)
+	if conn == nil {
+		conn, err = c.dial(addr, &phases)
 	}
+	c.updateReport(func(r *ConnectReport) {
+		r.DNS, r.Dial, r.TLS, r.Resumed = phases.DNS, phases.Dial, phases.TLS, phases.Resumed
+	})
 	if err != nil {
+		c.releaseIdentity(err)
+		c.failReport(err)
 		return err
 	}
 
+	c.muConn.Lock()
+	if c.isDeadLocked() {
+		c.muConn.Unlock()
+		conn.Close()
+		c.releaseIdentity(nil)
+		return ErrConnectorClosed
+	}
 	c.conn = conn
 	c.connecting = true
+	gen, chSend, die := c.gen, c.chSend, c.die
+	// the read loop and the writer
+	c.loops.Add(2)
+	c.muConn.Unlock()
+	defer c.loops.Done()
+	c.metrics.IncrCounter(MetricConnects, 1)
+	c.publish(LifecycleEvent{Kind: EventConnected})
+
+	go func() {
+		defer c.loops.Done()
+		c.write(conn, chSend, die, gen)
+	}()
+
+	c.updateReport(func(r *ConnectReport) { r.sentAt = c.clock.Now() })
+	if err := c.sendPacket(packet.Handshake, c.handshakeBody()); err != nil {
+		c.closeConn(gen, DisconnectHandshake, err)
+		return err
+	}
 
-	go c.write()
+	return c.read(gen)
+}
 
-	c.send(c.handshakeData)
+// Request send a request to server and register a callbck for the response
+func (c *Connector) Request(route string, data []byte, callback Callback, opts ...RequestOption) error {
+	if c.isDraining() {
+		return ErrDraining
+	}
+	if err := c.checkOutbound(route); err != nil {
+		return err
+	}
 
-	err = c.read(tickrate)
+	var o requestOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-	return err
-}
+	if err := c.admit(o.ctx); err != nil {
+		return err
+	}
+	probe, err := c.breaker.allow(route)
+	if err != nil {
+		c.leave()
+		return err
+	}
+	o.probe = probe
+	pr, err := c.addPending(route, data, callback, o)
+	if err != nil {
+		c.leave()
+		if probe {
+			c.breaker.release(route)
+		}
+		return err
+	}
 
-// Request send a request to server and register a callbck for the response
-func (c *Connector) Request(route string, data []byte, callback Callback) error {
 	msg := &message.Message{
 		Type:  message.Request,
 		Route: route,
-		ID:    c.mid,
+		ID:    pr.mid,
 		Data:  data,
 	}
 
-	c.setResponseHandler(c.mid, callback)
-	if err := c.sendMessage(msg); err != nil {
-		log.Println(err)
-		c.setResponseHandler(c.mid, nil)
+	if err := c.sendMessageCtx(o.ctx, msg, nil); err != nil {
+		c.logError("request send failed", Field{"route", route}, Field{"mid", msg.ID}, Field{"error", err})
+		c.abortPending(pr)
+		if o.ctx == nil || o.ctx.Err() == nil {
+			c.breaker.failure(route)
+		} else {
+			// a saturated queue says nothing about the route health
+			c.releaseProbe(pr)
+		}
 		return err
 	}
 
+	c.metrics.IncrCounter(MetricRequests, 1)
+	c.routeTable.request(route, c.clock.Now())
+	c.reportPending()
 	return nil
 }
 
 // Notify send a notification to server
 func (c *Connector) Notify(route string, data []byte) error {
+	if c.isDraining() {
+		return ErrDraining
+	}
+	if err := c.checkOutbound(route); err != nil {
+		return err
+	}
+	if c.duplicateNotify(route, data) {
+		return nil
+	}
+	return c.sendNotify(route, data)
+}
+
+// sendNotify queues a notify, past the checks of Notify
+func (c *Connector) sendNotify(route string, data []byte) error {
 	msg := &message.Message{
 		Type:  message.Notify,
 		Route: route,
 		Data:  data,
 	}
-	return c.sendMessage(msg)
+	if err := c.sendMessage(msg); err != nil {
+		return err
+	}
+
+	c.metrics.IncrCounter(MetricNotifies, 1)
+	c.routeTable.notify(route, c.clock.Now())
+	return nil
+}
+
+// SendPacket queues a raw packet of type typ (packet.Handshake ...
+// packet.Kick) with body data, bypassing the message layer: middlewares,
+// transforms and compression are not applied. It is meant for custom
+// control packets or manual handshakes and heartbeats.
+func (c *Connector) SendPacket(typ byte, data []byte) error {
+	if c.isDead() {
+		return ErrConnectorClosed
+	}
+	return c.sendPacket(typ, data)
 }
 
 // On add the callback for the event
 func (c *Connector) On(event string, callback Callback) {
+	c.on(event, callback, nil)
+}
+
+// on registers the callback, owner is the scope it belongs to or nil
+func (c *Connector) on(event string, callback Callback, owner *Scope) {
+	c.Lock()
+	c.events[event] = callback
+	if owner != nil {
+		c.owners[event] = owner
+	} else {
+		delete(c.owners, event)
+	}
+	var pending [][]byte
+	if c.replay != nil {
+		pending = c.replay.take(event)
+	}
+	c.Unlock()
+
+	for _, data := range pending {
+		callback(data)
+	}
+}
+
+// Off remove the callback for the event
+func (c *Connector) Off(event string) {
 	c.Lock()
 	defer c.Unlock()
 
-	c.events[event] = callback
+	delete(c.events, event)
+	delete(c.owners, event)
 }
 
-// Close close the connection, and shutdown the benchmark
-func (c *Connector) Close() {
-	if !c.connecting {
-		return
-	}
-	c.conn.Close()
-	c.die <- 1
-	c.connecting = false
+// Close close the connection, and shutdown the benchmark. It is safe to
+// call Close multiple times, every call returns the error of closing the
+// underlying connection. Pending requests fail with ErrConnectorClosed,
+// after a connection drop the replay policy may requeue them instead.
+// Notifies waiting for their ack always fail with ErrConnectorClosed, and
+// so do the requests requeued by an earlier drop.
+func (c *Connector) Close() error {
+	err := c.shutdown()
+	// after a drop the requeued requests wait for Reset, Close gives up
+	// on them even though the connection is already closed
+	c.CancelReplay()
+	return err
+}
+
+// shutdown closes the connection once, the drops and Reset use it to
+// keep the requeued requests.
+func (c *Connector) shutdown() error {
+	c.muConn.RLock()
+	once := c.closeOnce
+	c.muConn.RUnlock()
+
+	once.Do(func() {
+		c.muConn.Lock()
+		conn := c.conn
+		c.connecting = false
+		close(c.die)
+		reason := c.disconnectReason
+		if reason == "" {
+			reason = DisconnectClosed
+			c.disconnectReason = reason
+		}
+		c.muConn.Unlock()
+
+		if conn != nil {
+			err := conn.Close()
+			c.muConn.Lock()
+			c.closeErr = err
+			c.muConn.Unlock()
+			c.countDisconnect(reason)
+		}
+		c.dropPreconnect()
+		c.endContext()
+		c.failAcks(ErrConnectorClosed)
+		if reason == DisconnectClosed {
+			if conn != nil {
+				c.abandonOutage()
+			}
+			c.failAllPending(ErrConnectorClosed)
+			c.CancelReplay()
+		} else {
+			c.retainPending(ErrConnectorClosed)
+		}
+	})
+
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+	return c.closeErr
+}
+
+// Reset makes a closed connector reusable for a new Run, registered
+// handlers and configuration are kept. An open connector is closed first
+// and Reset waits for the goroutines of its connection, the read loop
+// included, so it must not be called from a handler or callback.
+func (c *Connector) Reset() {
+	c.shutdown()
+	// a late failure of the old connection must not close the new one
+	c.loops.Wait()
+
+	c.muConn.Lock()
+	defer c.muConn.Unlock()
+
+	c.gen++
+	c.conn = nil
+	c.die = make(chan byte)
+	c.closeOnce = new(sync.Once)
+	c.closeErr = nil
+	c.lastErr = nil
+	c.disconnectReason = ""
+	c.ready = make(chan struct{})
+	c.compressor = nil
+	c.serverVersion = ""
+	c.chSend = make(chan outbound, 64)
+	atomic.StoreInt64(&c.unflushed, 0)
+	c.codec = codec.NewDecoder()
+	c.codec.SetCipher(c.decoderCipher())
+	c.codec.SetMaxPacketSize(c.maxPacketSize)
+	atomic.StoreInt32(&c.draining, 0)
+}
+
+func (c *Connector) lastError() error {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	return c.lastErr
 }
 
 // IsClosed check the connection is closed
 func (c *Connector) IsClosed() bool {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
 	return !c.connecting
 }
 
+// done returns a channel closed once the connector is closed
+func (c *Connector) done() <-chan byte {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	return c.die
+}
+
+func (c *Connector) isDead() bool {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	return c.isDeadLocked()
+}
+
+// isDeadLocked must be called with muConn held
+func (c *Connector) isDeadLocked() bool {
+	select {
+	case <-c.die:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *Connector) eventHandler(event string) (Callback, bool) {
 	c.RLock()
 	defer c.RUnlock()
@@ -261,157 +620,381 @@ func (c *Connector) eventHandler(event string) (Callback, bool) {
 	return cb, ok
 }
 
-func (c *Connector) responseHandler(mid uint) (Callback, bool) {
-	c.muResponses.RLock()
-	defer c.muResponses.RUnlock()
-
-	cb, ok := c.responses[mid]
-	return cb, ok
+func (c *Connector) sendMessage(msg *message.Message) error {
+	return c.sendMessageCtx(nil, msg, nil)
 }
 
-func (c *Connector) setResponseHandler(mid uint, cb Callback) {
-	c.muResponses.Lock()
-	defer c.muResponses.Unlock()
-
-	if cb == nil {
-		delete(c.responses, mid)
-	} else {
-		c.responses[mid] = cb
+// sendMessageCtx queues the message, done receives the write result. A
+// non nil ctx bounds the wait on a full send queue.
+func (c *Connector) sendMessageCtx(ctx context.Context, msg *message.Message, done chan error) error {
+	if err := c.outgoing(msg); err != nil {
+		return err
+	}
+	c.bridgeOutgoing(msg)
+	if err := c.transform(msg); err != nil {
+		return err
+	}
+	sealed := c.sealsPackets()
+	if !sealed {
+		if err := c.compressMessage(msg); err != nil {
+			return err
+		}
 	}
-}
 
-func (c *Connector) sendMessage(msg *message.Message) error {
-	data, err := msg.Encode()
+	data, err := message.EncodeCompat(msg, c.codecCompat())
 	if err != nil {
 		return err
 	}
 	// log.Printf("%+v | %+v | %+v\n", msg.Data, msg, data)
 
-	payload, err := codec.Encode(packet.Data, data)
+	c.observeSent(msg.Route, len(msg.Data))
+	if sealed {
+		return c.sendSealedCtx(ctx, data, done)
+	}
+	return c.sendPacketCtx(ctx, packet.Data, data, done)
+}
+
+// sendPacket encodes the packet body and queues it
+func (c *Connector) sendPacket(typ byte, body []byte) error {
+	return c.sendPacketCtx(nil, typ, body, nil)
+}
+
+func (c *Connector) sendPacketCtx(ctx context.Context, typ byte, body []byte, done chan error) error {
+	payload, err := c.encoder.Encode(typ, body)
 	if err != nil {
 		return err
 	}
 
-	c.mid++
-	c.send(payload)
-
-	return nil
+	return c.enqueue(ctx, outbound{data: payload, done: done})
 }
 
-func (c *Connector) write() {
+// maxWriteBatch bounds the frames flushed by a single vectored write
+const maxWriteBatch = 64
+
+// write flushes the queued frames, every frame already queued when the
+// loop wakes up is written with a single writev on tcp connections. gen
+// is the generation of conn.
+func (c *Connector) write(conn net.Conn, chSend chan outbound, die chan byte, gen uint64) {
+	batch := make([]outbound, 0, maxWriteBatch)
+	bufs := make(net.Buffers, 0, maxWriteBatch)
 	for {
 		select {
-		case data := <-c.chSend:
-			if c.conn != nil {
-				if _, err := c.conn.Write(data); err != nil {
-					log.Println("conn write err", err.Error())
-					// c.Close()
-				}
+		case out := <-chSend:
+			batch = append(batch[:0], out)
+		case <-die:
+			return
+		}
+
+	fill:
+		for len(batch) < maxWriteBatch {
+			select {
+			case out := <-chSend:
+				batch = append(batch, out)
+			default:
+				break fill
 			}
+		}
 
-		case <-c.die:
+		size := 0
+		bufs = bufs[:0]
+		for _, out := range batch {
+			bufs = append(bufs, out.data)
+			size += len(out.data)
+		}
+		// WriteTo consumes the slice it is called on, keep bufs for reuse
+		pending := bufs
+		_, err := pending.WriteTo(conn)
+		atomic.AddInt64(&c.unflushed, -int64(len(batch)))
+		for _, out := range batch {
+			if out.done != nil {
+				out.done <- err
+			}
+		}
+		if err != nil {
+			c.logError("conn write err", Field{"bytes", size}, Field{"error", err})
+			c.closeConn(gen, DisconnectWriteError, err)
 			return
 		}
+		c.metrics.IncrCounter(MetricPacketsSent, int64(len(batch)))
+		c.metrics.IncrCounter(MetricBytesSent, int64(size))
+		if c.traffic != nil {
+			c.traffic.BytesSent(size)
+		}
+	}
+}
+
+func (c *Connector) send(out outbound) {
+	c.muConn.RLock()
+	chSend, die := c.chSend, c.die
+	c.muConn.RUnlock()
+
+	atomic.AddInt64(&c.unflushed, 1)
+	select {
+	case chSend <- out:
+	case <-die:
+		atomic.AddInt64(&c.unflushed, -1)
 	}
 }
 
-func (c *Connector) send(data []byte) {
-	c.chSend <- data
+// sendQueue returns the send queue of the connection
+func (c *Connector) sendQueue() chan outbound {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	return c.chSend
 }
 
-func (c *Connector) read(tickrate int64) error {
-	buf := make([]byte, 2048)
+// read runs the read loop of the connection of generation gen
+func (c *Connector) read(gen uint64) error {
+	c.muConn.RLock()
+	conn, dec := c.conn, c.codec
+	c.muConn.RUnlock()
+	r := bufio.NewReaderSize(conn, readBufferSize)
+	buf := make([]byte, readBufferSize)
+	budget := &c.budget
+	budget.count, budget.busy = 0, 0
 
 	for {
-		time.Sleep(time.Second / time.Duration(tickrate))
+		if c.tickrate > 0 {
+			// throttling, not timing logic, it stays on the real clock
+			time.Sleep(time.Second / time.Duration(c.tickrate))
+		}
 		if c.IsClosed() {
+			if err := c.lastError(); err != nil {
+				return err
+			}
 			return errors.New("read err: connector is closed")
 		}
-		n, err := c.conn.Read(buf)
+		// a large packet is read straight into a buffer sized for it, bufio
+		// skips its own buffer for reads at least as large
+		missing := dec.Missing()
+		switch {
+		case missing > len(buf):
+			buf = make([]byte, missing)
+		case missing <= readBufferSize && len(buf) > readBufferSize:
+			buf = make([]byte, readBufferSize)
+		}
+		n, err := r.Read(buf)
+		if err != nil && c.IsClosed() {
+			if lastErr := c.lastError(); lastErr != nil {
+				return lastErr
+			}
+			return err
+		}
 		if err != nil {
-			log.Println("connector read err", err.Error())
-			c.Close()
+			reason := DisconnectReadError
+			var closeErr *WebSocketCloseError
+			if errors.As(err, &closeErr) {
+				reason = DisconnectServerClose
+			}
+			c.logError("connector read err", Field{"error", err})
+			c.closeConn(gen, reason, err)
 			return err
 			// continue
 		}
 
-		packets, err := c.codec.Decode(buf[:n])
-		if err != nil {
-			log.Println("connector read decode err", err.Error())
-			// c.Close()
-			// return
-			continue
+		c.metrics.IncrCounter(MetricBytesReceived, int64(n))
+		if c.traffic != nil {
+			c.traffic.BytesReceived(n)
+		}
+
+		packets, err := dec.Decode(buf[:n])
+		if missing > codec.HeadLength || dec.Missing() > codec.HeadLength {
+			// a packet spans several reads
+			c.touchStream()
+		}
+		for err != nil {
+			skipped, ok := dec.Resync()
+			if !ok {
+				c.logError("connector read desync", Field{"bytes", skipped}, Field{"error", err})
+				c.publish(LifecycleEvent{Kind: EventDecodeError, Err: err})
+				c.closeConn(gen, DisconnectProtocolError, ErrProtocolDesync)
+				return ErrProtocolDesync
+			}
+			c.logWarn("connector read resync", Field{"bytes", skipped}, Field{"error", err})
+			c.publish(LifecycleEvent{Kind: EventDecodeError, Err: err})
+
+			var more []*packet.Packet
+			more, err = dec.Decode(nil)
+			packets = append(packets, more...)
 		}
 
 		for i := range packets {
 			p := packets[i]
 			// log.Println("packet-->", p)
+			c.metrics.IncrCounter(MetricPacketsReceived, 1)
+			if !budget.budgeted() {
+				c.processPacket(p)
+				continue
+			}
+			start := time.Now()
 			c.processPacket(p)
+			budget.processed(time.Since(start))
 		}
+		budget.endRead()
 	}
 }
 
+// dispatchPush runs the handler of a push, it reports whether a handler
+// was run or queued
+func (c *Connector) dispatchPush(route string, data []byte) bool {
+	cb, ok := c.eventHandler(route)
+	if !ok {
+		var buffered bool
+		cb, buffered = c.bufferPush(route, data)
+		if buffered {
+			c.logDebug("push buffered for replay", Field{"route", route}, Field{"bytes", len(data)})
+			return false
+		}
+		ok = cb != nil
+	}
+	if !ok {
+		if c.spillPush(route, data) {
+			return false
+		}
+		c.logWarn("event handler not found", Field{"route", route}, Field{"bytes", len(data)})
+		return false
+	}
+
+	c.metrics.IncrCounter(MetricPushes, 1)
+	cb = c.settling(cb)
+	if c.pollDispatch {
+		buf := cloneBytes(data)
+		c.dispatch(func() { c.runHandler(route, cb, buf) })
+		return true
+	}
+	c.runHandler(route, cb, data)
+	return true
+}
+
+// cloneBytes returns a private copy of data. The payloads handed out by
+// the read loop alias the decoder buffer, which the next read reuses, so
+// whatever keeps one past the call must clone it.
+func cloneBytes(data []byte) []byte {
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	return buf
+}
+
+// InjectPacket pushes a synthetic packet through the normal packet
+// processing, so push and response handlers can be tested without a
+// transport. The caller must not inject concurrently with a running read
+// loop.
+func (c *Connector) InjectPacket(p *packet.Packet) {
+	c.processPacket(p)
+}
+
 func (c *Connector) processPacket(p *packet.Packet) {
+	c.touchPacket()
+	c.pluginsPacket(p)
 	// log.Printf("packet: %+v\n", p)
 	switch p.Type {
 	case packet.Handshake:
-		var handshakeResp DefaultHandshakePacket
-		err := json.Unmarshal(p.Data, &handshakeResp)
+		c.processHandshake(p)
+
+	case packet.Data:
+		body, err := c.openPacket(p.Data)
 		if err != nil {
-			c.Close()
+			c.logError("packet open failed", Field{"bytes", len(p.Data)}, Field{"error", err})
+			c.publish(LifecycleEvent{Kind: EventDecodeError, Err: err})
 			return
 		}
-		log.Println(handshakeResp.Code)
-		if handshakeResp.Code == 200 {
-			go func() {
-				ticker := time.NewTicker(time.Second * time.Duration(handshakeResp.Sys.Heartbeat))
-				for range ticker.C {
-					if c.IsClosed() {
-						return
-					}
-					c.send(c.heartbeatData)
-				}
-			}()
-			c.send(c.handshakeAckData)
-			if c.connectedCallback != nil {
-				c.connectedCallback()
-			}
-		} else {
-			log.Fatal("bad packet handshake code, not 200:", string(p.Data))
-			c.Close()
-		}
-	case packet.Data:
-		msg, err := message.Decode(p.Data)
+		msg, err := message.DecodeCompat(body, c.codecCompat())
 		if err != nil {
+			c.publish(LifecycleEvent{Kind: EventDecodeError, Err: err})
+			c.settleDropped(messageType(body))
+			return
+		}
+		if err := c.decompressMessage(msg); err != nil {
+			c.logError("message decompress failed", Field{"route", msg.Route}, Field{"mid", msg.ID}, Field{"bytes", len(msg.Data)}, Field{"error", err})
+			c.publish(LifecycleEvent{Kind: EventDecodeError, Err: err})
+			c.settleDropped(msg.Type)
+			return
+		}
+		c.pluginsMessage(msg)
+		c.observeMessage(msg)
+		c.bridgeIncoming(msg)
+		if !c.incoming(msg) {
+			c.settleDropped(msg.Type)
 			return
 		}
 		c.processMessage(msg)
 
+	case packet.Heartbeat:
+		c.processHeartbeat(p.Data)
+
 	case packet.Kick:
-		log.Fatal("server kick -->", p)
-		c.Close()
+		c.logWarn("server kick", Field{"bytes", p.Length}, Field{"data", string(p.Data)})
+		c.checkDuplicateLogin("", p.Data)
+		c.closeWithReason(DisconnectKick, nil)
+	}
+}
+
+// processPush delivers a push, it reports whether a handler was run or
+// queued, settling its credit
+func (c *Connector) processPush(msg *message.Message) bool {
+	c.observeReceived(msg.Route, len(msg.Data))
+	if !c.pushFilter.allowed(msg.Route) {
+		c.metrics.IncrCounter(MetricPushesFiltered, 1)
+		c.logDebug("push route blocked", Field{"route", msg.Route}, Field{"bytes", len(msg.Data)})
+		return false
+	}
+	c.routeTable.push(msg.Route, c.clock.Now())
+	if !c.checkSequence(msg.Route, msg.Data) {
+		return false
 	}
+	c.captureSession(msg.Route, msg.Data)
+	c.checkDuplicateLogin(msg.Route, msg.Data)
+	if c.streamPush(msg.Route, msg.Data) || c.ackPush(msg.Route, msg.Data) {
+		return false
+	}
+	if c.holdPush(msg.Route, msg.Data) {
+		// settled once resumed
+		return true
+	}
+	return c.dispatchPush(msg.Route, msg.Data)
 }
 
 func (c *Connector) processMessage(msg *message.Message) {
 	switch msg.Type {
 	case message.Push:
-		cb, ok := c.eventHandler(msg.Route)
-		if !ok {
-			log.Println("event handler not found", msg.Route)
-			return
+		if !c.processPush(msg) {
+			c.settlePush()
 		}
 
-		cb(msg.Data)
-
 	case message.Response:
-		cb, ok := c.responseHandler(msg.ID)
+		if c.streamChunk(msg.ID, msg.Data) {
+			return
+		}
+		pr, ok := c.takePending(msg.ID)
 		if !ok {
-			log.Println("response handler not found", msg.ID)
+			c.orphanResponse(msg.ID, msg.Data)
 			return
 		}
 
-		cb(msg.Data)
-		c.setResponseHandler(msg.ID, nil)
+		c.metrics.IncrCounter(MetricResponses, 1)
+		c.observeReceived(pr.route, len(msg.Data))
+		c.reportPending()
+		c.checkSlow(pr)
+		c.captureSession(pr.route, msg.Data)
+		err := c.responseError(pr.route, msg.Data)
+		if err != nil {
+			c.breaker.failure(pr.route)
+		} else {
+			c.breaker.success(pr.route)
+		}
+		c.logExchange(pr, msg.Data, err)
+		data := msg.Data
+		if c.pollDispatch {
+			data = cloneBytes(data)
+		}
+		c.deliver(pr, func() {
+			c.observeLatency(pr.route, pr.sentAt)
+			if err != nil && pr.onError != nil {
+				pr.onError(err)
+				return
+			}
+			pr.cb(data)
+		})
 	}
 }
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResetReconnect(t *testing.T) {
+	s := newTestServer(t, nil)
+	c := newTestConnector(t)
+
+	// writes in flight when the connection is closed fail on the old one
+	stop := make(chan struct{})
+	defer close(stop)
+	flood := func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			c.Notify("flood", []byte(`{}`))
+		}
+	}
+	go flood()
+
+	for i := 0; i < 10; i++ {
+		errCh := runConnector(t, c, s.addr())
+		s.next()
+		if resp, err := call(t, c, "echo", []byte(`{"n":1}`)); err != nil || !bytes.Equal(resp, []byte(`{"n":1}`)) {
+			t.Fatalf("round %d: echo %q, %v", i, resp, err)
+		}
+
+		// Reset right away, the old connection is still shutting down
+		c.Close()
+		c.Reset()
+		select {
+		case <-errCh:
+		case <-time.After(testTimeout):
+			t.Fatalf("round %d: Run did not return", i)
+		}
+		if c.isDead() {
+			t.Fatalf("round %d: reset connector is closed", i)
+		}
+	}
+}
+
+func TestResetIgnoresStaleConnection(t *testing.T) {
+	s := newTestServer(t, nil)
+	c := newTestConnector(t)
+
+	runConnector(t, c, s.addr())
+	s.next()
+	stale := c.generation()
+	c.Close()
+	c.Reset()
+	runConnector(t, c, s.addr())
+	s.next()
+
+	c.closeConn(stale, DisconnectWriteError, errStale)
+	if c.IsClosed() {
+		t.Fatal("a failure of the old connection closed the new one")
+	}
+	if _, err := call(t, c, "echo", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var errStale = errors.New("stale")
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+type (
+	// ConnInfo describes the connection of a connector context
+	ConnInfo struct {
+		Name          string // connector name, see SetName
+		Addr          string // address passed to Run
+		ServerVersion string // sys.version of the handshake response
+	}
+
+	// ContextMiddleware is a Middleware given the connection context,
+	// its context methods are called instead of Outgoing and Incoming.
+	ContextMiddleware interface {
+		Middleware
+		OutgoingContext(ctx context.Context, msg *message.Message) error
+		IncomingContext(ctx context.Context, msg *message.Message) error
+	}
+
+	// connContext is the connection context, its values are looked up in
+	// the connector value bag first
+	connContext struct {
+		context.Context
+		c *Connector
+	}
+
+	contextKey int
+)
+
+const (
+	connInfoKey contextKey = iota
+	sessionKey
+)
+
+// SetValue stores value under key in the connector value bag, visible to
+// every middleware and handler through the connection context. A nil
+// value removes it. Keys should be of an unexported type, like for
+// context.WithValue.
+func (c *Connector) SetValue(key, value interface{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	if value == nil {
+		delete(c.values, key)
+		return
+	}
+	if c.values == nil {
+		c.values = map[interface{}]interface{}{}
+	}
+	c.values[key] = value
+}
+
+// Context returns the context of the current connection, it is done once
+// the connection is closed and carries the value bag, the ConnInfo and the
+// session, see ConnInfoFrom and SessionFrom. Before the first Run it is
+// never done.
+func (c *Connector) Context() context.Context {
+	c.muConn.RLock()
+	base := c.connCtx
+	c.muConn.RUnlock()
+
+	if base == nil {
+		base = context.Background()
+	}
+	return connContext{Context: base, c: c}
+}
+
+// ConnInfoFrom returns the connection info of a connector context
+func ConnInfoFrom(ctx context.Context) (ConnInfo, bool) {
+	info, ok := ctx.Value(connInfoKey).(ConnInfo)
+	return info, ok
+}
+
+// SessionFrom returns the session of a connector context
+func SessionFrom(ctx context.Context) (*Session, bool) {
+	s, ok := ctx.Value(sessionKey).(*Session)
+	return s, ok
+}
+
+// OnContext adds a callback for the event receiving the connection
+// context along with the payload
+func (c *Connector) OnContext(event string, callback func(ctx context.Context, data []byte)) {
+	c.On(event, func(data []byte) {
+		callback(c.Context(), data)
+	})
+}
+
+func (cc connContext) Value(key interface{}) interface{} {
+	switch key {
+	case connInfoKey:
+		cc.c.muConn.RLock()
+		defer cc.c.muConn.RUnlock()
+		return ConnInfo{Name: cc.c.name, Addr: cc.c.connAddr, ServerVersion: cc.c.serverVersion}
+	case sessionKey:
+		return cc.c.session
+	}
+
+	cc.c.RLock()
+	v, ok := cc.c.values[key]
+	cc.c.RUnlock()
+	if ok {
+		return v
+	}
+	return cc.Context.Value(key)
+}
+
+// beginContext starts the context of a connection to addr
+func (c *Connector) beginContext(addr string) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.muConn.Lock()
+	c.connCtx, c.connCancel, c.connAddr = ctx, cancel, addr
+	c.muConn.Unlock()
+}
+
+// endContext cancels the context of the connection
+func (c *Connector) endContext() {
+	c.muConn.RLock()
+	cancel := c.connCancel
+	c.muConn.RUnlock()
+
+	if cancel != nil {
+		cancel()
+	}
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+// CreditField is the field of the credit notifies, {"credit": n} grants
+// the server n more pushes
+const CreditField = "credit"
+
+// receiveWindow is the push credit advertised to the server
+type receiveWindow struct {
+	route string // credit notify route
+	size  int
+
+	mu    sync.Mutex
+	freed int // pushes settled since the last grant
+}
+
+// SetReceiveWindow enables credit based flow control for servers that
+// support it: once connected the client notifies route with
+// {"credit": window}, and the server must stop pushing when its credit
+// is spent. A push is settled once its handler returned, or at once when
+// no handler runs it, and the settled pushes are granted back by half
+// windows, so a server flooding slow handlers, e.g. in polled dispatch,
+// is held back instead of filling the memory. The server starts every
+// session without credit. window <= 0 disables it. It must be set before
+// Run.
+func (c *Connector) SetReceiveWindow(route string, window int) {
+	if window <= 0 {
+		c.window = nil
+		return
+	}
+	c.window = &receiveWindow{route: route, size: window}
+}
+
+// openWindow grants the whole window to a new session
+func (c *Connector) openWindow() {
+	w := c.window
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.freed = 0
+	w.mu.Unlock()
+	c.grantCredit(w.size)
+}
+
+// settlePush frees the credit of a push
+func (c *Connector) settlePush() {
+	w := c.window
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.freed++
+	n := w.freed
+	if n < (w.size+1)/2 {
+		w.mu.Unlock()
+		return
+	}
+	w.freed = 0
+	w.mu.Unlock()
+	c.grantCredit(n)
+}
+
+// settleDropped settles a message dropped before its dispatch, e.g. not
+// decoded or rejected by a middleware, if it is a push: the server spent
+// its credit all the same.
+func (c *Connector) settleDropped(typ byte) {
+	if typ == message.Push {
+		c.settlePush()
+	}
+}
+
+// messageType peeks the type in the flag of an encoded message, for the
+// messages failing to decode
+func messageType(body []byte) byte {
+	if len(body) == 0 {
+		return 0xFF
+	}
+	return (body[0] >> 1) & 0x07
+}
+
+// settling wraps cb to settle its push once it returned
+func (c *Connector) settling(cb Callback) Callback {
+	if c.window == nil {
+		return cb
+	}
+	return func(data []byte) {
+		defer c.settlePush()
+		cb(data)
+	}
+}
+
+func (c *Connector) grantCredit(n int) {
+	data, err := json.Marshal(map[string]int{CreditField: n})
+	if err != nil {
+		return
+	}
+	// identical grants are not duplicates, they bypass the notify dedup
+	if err := c.sendNotify(c.window.route, data); err != nil {
+		c.logWarn("credit grant failed", Field{"route", c.window.route}, Field{"credit", n}, Field{"error", err})
+	}
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/message"
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+// creditServer returns a server sending the credit grants to the channel
+func creditServer(t *testing.T) (*testServer, <-chan string) {
+	grants := make(chan string, 16)
+	s := newTestServer(t, func(sc *serverConn, msg *message.Message) {
+		if msg.Type == message.Notify && msg.Route == "credit" {
+			grants <- string(msg.Data)
+		}
+	})
+	return s, grants
+}
+
+func expectGrant(t *testing.T, grants <-chan string, want string) {
+	t.Helper()
+
+	select {
+	case got := <-grants:
+		if got != want {
+			t.Fatalf("grant %s, want %s", got, want)
+		}
+	case <-time.After(testTimeout):
+		t.Fatalf("no grant, want %s", want)
+	}
+}
+
+func expectNoGrant(t *testing.T, grants <-chan string) {
+	t.Helper()
+
+	select {
+	case got := <-grants:
+		t.Fatalf("unexpected grant %s", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// dropPushes rejects every push
+type dropPushes struct{}
+
+func (dropPushes) Outgoing(*message.Message) error { return nil }
+
+func (dropPushes) Incoming(msg *message.Message) error {
+	if msg.Type == message.Push {
+		return errors.New("dropped")
+	}
+	return nil
+}
+
+func TestCreditSettlesDroppedPushes(t *testing.T) {
+	s, grants := creditServer(t)
+	c := newTestConnector(t)
+	c.SetReceiveWindow("credit", 4)
+	c.AddMiddleware(dropPushes{})
+	c.On("chat", func([]byte) { t.Error("dropped push dispatched") })
+	runConnector(t, c, s.addr())
+	sc := s.next()
+	expectGrant(t, grants, `{"credit":4}`)
+
+	// rejected by the middleware
+	sc.push("chat", []byte(`{}`))
+	sc.push("chat", []byte(`{}`))
+	expectGrant(t, grants, `{"credit":2}`)
+
+	// a push flag without route fails to decode
+	sc.send(packet.Data, []byte{message.Push << 1})
+	expectNoGrant(t, grants)
+	sc.send(packet.Data, []byte{message.Push << 1})
+	expectGrant(t, grants, `{"credit":2}`)
+}
+
+func TestCreditGrantedOncePolled(t *testing.T) {
+	s, grants := creditServer(t)
+	c := newTestConnector(t)
+	c.SetReceiveWindow("credit", 2)
+	c.SetPollDispatch(true)
+	var got []string
+	c.On("chat", func(data []byte) { got = append(got, string(data)) })
+	runConnector(t, c, s.addr())
+	sc := s.next()
+	expectGrant(t, grants, `{"credit":2}`)
+
+	sc.push("chat", []byte(`{"n":1}`))
+	waitFor(t, "queued push", func() bool { return c.Polled() == 1 })
+	// the handler did not run yet, its credit is still spent
+	expectNoGrant(t, grants)
+
+	c.Poll(0)
+	expectGrant(t, grants, `{"credit":1}`)
+	if len(got) != 1 || got[0] != `{"n":1}` {
+		t.Fatalf("pushes %q", got)
+	}
+}
+
+func TestCreditSettlesUnhandledPush(t *testing.T) {
+	s, grants := creditServer(t)
+	c := newTestConnector(t)
+	c.SetReceiveWindow("credit", 2)
+	runConnector(t, c, s.addr())
+	sc := s.next()
+	expectGrant(t, grants, `{"credit":2}`)
+
+	sc.push("nobody", []byte(`{}`))
+	expectGrant(t, grants, `{"credit":1}`)
+}
+
+func TestCreditHeldByPause(t *testing.T) {
+	s, grants := creditServer(t)
+	c := newTestConnector(t)
+	c.SetReceiveWindow("credit", 2)
+	pushes := make(chan string, 4)
+	c.On("chat", func(data []byte) { pushes <- string(data) })
+	runConnector(t, c, s.addr())
+	sc := s.next()
+	expectGrant(t, grants, `{"credit":2}`)
+
+	c.PausePushes()
+	sc.push("chat", []byte(`{}`))
+	waitFor(t, "held push", func() bool {
+		c.pushGate.mu.Lock()
+		defer c.pushGate.mu.Unlock()
+		return len(c.pushGate.held) == 1
+	})
+	expectNoGrant(t, grants)
+	if len(pushes) != 0 {
+		t.Fatal("push dispatched while paused")
+	}
+
+	c.ResumePushes()
+	expectGrant(t, grants, `{"credit":1}`)
+	if len(pushes) != 1 {
+		t.Fatal("held push not dispatched")
+	}
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// notifyDedup remembers the notifies sent recently, by route and payload
+// hash
+type notifyDedup struct {
+	mu     sync.Mutex
+	window time.Duration
+	routes map[string]map[uint64]time.Time
+}
+
+// SetNotifyDedup drops a notify identical, same route and payload hash,
+// to one sent less than window ago, guarding against UI double clicks and
+// retry storms. Dropped notifies return nil and are counted in
+// MetricNotifiesDeduplicated. window <= 0 disables it.
+func (c *Connector) SetNotifyDedup(window time.Duration) {
+	if window <= 0 {
+		c.dedup = nil
+		return
+	}
+	c.dedup = &notifyDedup{window: window, routes: map[string]map[uint64]time.Time{}}
+}
+
+// duplicateNotify reports whether the notify must be dropped, and records
+// it otherwise
+func (c *Connector) duplicateNotify(route string, data []byte) bool {
+	d := c.dedup
+	if d == nil {
+		return false
+	}
+
+	h := fnv.New64a()
+	h.Write(data)
+	sum := h.Sum64()
+	now := c.clock.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sent, ok := d.routes[route]
+	if !ok {
+		sent = map[uint64]time.Time{}
+		d.routes[route] = sent
+	}
+	for k, at := range sent {
+		if now.Sub(at) >= d.window {
+			delete(sent, k)
+		}
+	}
+	if _, dup := sent[sum]; dup {
+		c.metrics.IncrCounter(MetricNotifiesDeduplicated, 1)
+		c.logDebug("duplicate notify dropped", Field{"route", route}, Field{"bytes", len(data)})
+		return true
+	}
+	sent[sum] = now
+	return false
+}
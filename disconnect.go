@@ -0,0 +1,70 @@
+package client
+
+// DisconnectReason classifies why a connection was closed
+type DisconnectReason string
+
+// Disconnect reasons, also used as suffix of the disconnects metric
+// (e.g. "disconnects.kick").
+const (
+	DisconnectClosed           DisconnectReason = "closed" // Close called by the application
+	DisconnectKick             DisconnectReason = "kick"
+	DisconnectHeartbeatTimeout DisconnectReason = "heartbeat_timeout"
+	DisconnectReadError        DisconnectReason = "read_error"
+	DisconnectWriteError       DisconnectReason = "write_error"
+	DisconnectHandshake        DisconnectReason = "handshake" // handshake or connect script failure
+	DisconnectProtocolError    DisconnectReason = "protocol_error"
+	DisconnectServerClose      DisconnectReason = "server_close" // websocket close frame, see WebSocketCloseError
+)
+
+// closeWithReason closes the current connection, the first reason of a
+// connection is the one counted. Run returns err if it is not nil. It is
+// called on the read loop, the goroutines of a connection which may
+// outlive it use closeConn.
+func (c *Connector) closeWithReason(reason DisconnectReason, err error) {
+	c.closeConn(c.generation(), reason, err)
+}
+
+// closeConn is closeWithReason for the connection of generation gen, it
+// is ignored once the connector was Reset for a new connection.
+func (c *Connector) closeConn(gen uint64, reason DisconnectReason, err error) {
+	c.muConn.Lock()
+	if gen != c.gen {
+		c.muConn.Unlock()
+		return
+	}
+	if c.lastErr == nil {
+		c.lastErr = err
+	}
+	if c.disconnectReason == "" {
+		c.disconnectReason = reason
+	}
+	c.muConn.Unlock()
+
+	c.failReport(err)
+	c.shutdown()
+}
+
+// generation returns the generation of the current connection
+func (c *Connector) generation() uint64 {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	return c.gen
+}
+
+// countDisconnect must be called once per closed connection
+func (c *Connector) countDisconnect(reason DisconnectReason) {
+	c.metrics.IncrCounter(MetricDisconnects, 1)
+	c.metrics.IncrCounter(MetricDisconnects+"."+string(reason), 1)
+
+	c.stats.mu.Lock()
+	c.stats.disconnects[reason]++
+	c.stats.mu.Unlock()
+
+	if reason != DisconnectClosed {
+		c.beginOutage()
+	}
+	err := c.lastError()
+	c.releaseIdentity(err)
+	c.publish(LifecycleEvent{Kind: EventDisconnected, Reason: reason, Err: err})
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+)
+
+// drainPollInterval is how often Drain checks the queue and responses
+const drainPollInterval = 10 * time.Millisecond
+
+// Drain stops accepting new requests and notifies, waits until the send
+// queue is flushed and every in-flight request got its response, then
+// closes the connector. ErrDrainTimeout is returned if that took longer
+// than timeout, the connector is closed in both cases.
+func (c *Connector) Drain(timeout time.Duration) error {
+	atomic.StoreInt32(&c.draining, 1)
+
+	deadline := c.clock.Now().Add(timeout)
+	var err error
+	for !c.drained() {
+		if c.clock.Now().After(deadline) {
+			err = ErrDrainTimeout
+			c.logWarn("drain timeout", Field{"queued", atomic.LoadInt64(&c.unflushed)}, Field{"pending", c.pendingCount()})
+			break
+		}
+		c.clock.Sleep(drainPollInterval)
+	}
+
+	c.Close()
+	return err
+}
+
+// DrainOnSignal drains and closes c once sig is received, the returned
+// channel receives the result of Drain, or ErrConnectorClosed if c was
+// closed before the signal, the signal is then no longer watched.
+func DrainOnSignal(c *Connector, sig os.Signal, timeout time.Duration) <-chan error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, sig)
+
+	done := make(chan error, 1)
+	die := c.done()
+	go func() {
+		defer signal.Stop(sigCh)
+
+		select {
+		case <-sigCh:
+		case <-die:
+			done <- ErrConnectorClosed
+			return
+		}
+		c.logInfo("signal received, draining", Field{"signal", sig.String()})
+		done <- c.Drain(timeout)
+	}()
+	return done
+}
+
+func (c *Connector) isDraining() bool {
+	return atomic.LoadInt32(&c.draining) == 1
+}
+
+func (c *Connector) drained() bool {
+	// a batch taken off the queue counts until it is written
+	return c.IsClosed() || (atomic.LoadInt64(&c.unflushed) == 0 && c.pendingCount() == 0)
+}
+
+func (c *Connector) pendingCount() int {
+	return c.responses.len()
+}
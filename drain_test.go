@@ -0,0 +1,81 @@
+package client
+
+import (
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+func TestDrainFlushesNotifies(t *testing.T) {
+	const n = 500
+	var got int32
+	s := newTestServer(t, func(sc *serverConn, msg *message.Message) {
+		if msg.Type == message.Notify {
+			atomic.AddInt32(&got, 1)
+		}
+	})
+	c := newTestConnector(t)
+	runConnector(t, c, s.addr())
+	s.next()
+
+	for i := 0; i < n; i++ {
+		if err := c.Notify("chat", []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Drain(testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "flushed notifies", func() bool { return atomic.LoadInt32(&got) == n })
+}
+
+func TestDrainWaitsForResponses(t *testing.T) {
+	mids := make(chan uint, 1)
+	s := newTestServer(t, func(sc *serverConn, msg *message.Message) {
+		if msg.Type == message.Request {
+			mids <- msg.ID
+		}
+	})
+	c := newTestConnector(t)
+	runConnector(t, c, s.addr())
+	sc := s.next()
+
+	var resp int32
+	if err := c.Request("area.get", nil, func([]byte) { atomic.StoreInt32(&resp, 1) }); err != nil {
+		t.Fatal(err)
+	}
+	drained := make(chan error, 1)
+	go func() { drained <- c.Drain(testTimeout) }()
+
+	mid := <-mids
+	select {
+	case err := <-drained:
+		t.Fatalf("drained with a request in flight: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	sc.respond(mid, []byte(`{}`))
+	if err := <-drained; err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&resp) != 1 {
+		t.Fatal("response not delivered")
+	}
+}
+
+func TestDrainOnSignalStopsOnClose(t *testing.T) {
+	c := NewConnector()
+	done := DrainOnSignal(c, syscall.SIGUSR1, time.Second)
+	c.Close()
+
+	select {
+	case err := <-done:
+		if err != ErrConnectorClosed {
+			t.Fatalf("error %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("DrainOnSignal still waiting after close")
+	}
+}
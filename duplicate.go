@@ -0,0 +1,151 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DuplicateLogin describes a server notification that the account logged
+// in from another device
+type DuplicateLogin struct {
+	Route  string // push route, empty for a kick packet
+	Code   int
+	Reason string
+	Data   []byte // raw kick or push body
+}
+
+var (
+	// DefaultDuplicateLoginRoutes are the push routes inspected by default
+	DefaultDuplicateLoginRoutes = []string{"onKick", "onKicked"}
+
+	// DefaultDuplicateLoginReasons are the reason fragments recognized by
+	// default, matched case insensitively
+	DefaultDuplicateLoginReasons = []string{
+		"another device",
+		"other device",
+		"logged in elsewhere",
+		"login elsewhere",
+		"duplicate login",
+		"duplicated login",
+		"relogin",
+		"replaced",
+	}
+)
+
+type duplicateLogin struct {
+	cb      func(DuplicateLogin)
+	routes  map[string]bool
+	codes   map[int]bool
+	reasons []string
+}
+
+// OnDuplicateLogin sets the callback invoked when a kick packet or a push
+// on a duplicate login route carries a duplicate login code or reason,
+// before the connector is closed for a kick. Pushes are still delivered
+// to their handler. nil removes it.
+func (c *Connector) OnDuplicateLogin(cb func(DuplicateLogin)) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.duplicateLocked().cb = cb
+}
+
+// SetDuplicateLoginRoutes replaces the push routes inspected for
+// OnDuplicateLogin, DefaultDuplicateLoginRoutes by default
+func (c *Connector) SetDuplicateLoginRoutes(routes ...string) {
+	c.Lock()
+	defer c.Unlock()
+
+	d := c.duplicateLocked()
+	d.routes = map[string]bool{}
+	for _, route := range routes {
+		d.routes[route] = true
+	}
+}
+
+// SetDuplicateLoginCodes sets the codes recognized as a duplicate login,
+// none by default
+func (c *Connector) SetDuplicateLoginCodes(codes ...int) {
+	c.Lock()
+	defer c.Unlock()
+
+	d := c.duplicateLocked()
+	d.codes = map[int]bool{}
+	for _, code := range codes {
+		d.codes[code] = true
+	}
+}
+
+// SetDuplicateLoginReasons replaces the reason fragments recognized as a
+// duplicate login, DefaultDuplicateLoginReasons by default
+func (c *Connector) SetDuplicateLoginReasons(reasons ...string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.duplicateLocked().reasons = lowerAll(reasons)
+}
+
+// duplicateLocked returns the duplicate login configuration, created
+// with the defaults, it must be called with the lock held
+func (c *Connector) duplicateLocked() *duplicateLogin {
+	if c.duplicate == nil {
+		d := &duplicateLogin{
+			routes:  map[string]bool{},
+			codes:   map[int]bool{},
+			reasons: lowerAll(DefaultDuplicateLoginReasons),
+		}
+		for _, route := range DefaultDuplicateLoginRoutes {
+			d.routes[route] = true
+		}
+		c.duplicate = d
+	}
+	return c.duplicate
+}
+
+// checkDuplicateLogin invokes the duplicate login callback if the kick
+// (empty route) or push body is a duplicate login notification
+func (c *Connector) checkDuplicateLogin(route string, data []byte) {
+	c.RLock()
+	d := c.duplicate
+	if d == nil || d.cb == nil || route != "" && !d.routes[route] {
+		c.RUnlock()
+		return
+	}
+	cb, codes, reasons := d.cb, d.codes, d.reasons
+	c.RUnlock()
+
+	info := DuplicateLogin{Route: route, Data: data}
+	var body struct {
+		Code   int    `json:"code"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil {
+		info.Code, info.Reason = body.Code, body.Reason
+	} else {
+		// plain text kick body
+		info.Reason = string(data)
+	}
+
+	if !codes[info.Code] && !containsAny(strings.ToLower(info.Reason), reasons) {
+		return
+	}
+	c.logWarn("duplicate login", Field{"route", route}, Field{"code", info.Code}, Field{"reason", info.Reason})
+	cb(info)
+}
+
+func containsAny(s string, fragments []string) bool {
+	for _, f := range fragments {
+		if f != "" && strings.Contains(s, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(ss []string) []string {
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = strings.ToLower(s)
+	}
+	return out
+}
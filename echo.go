@@ -0,0 +1,104 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// maxEchoNonces bounds the outstanding heartbeat nonces, older ones are
+// forgotten but keep their send time for the timeout
+const maxEchoNonces = 8
+
+type (
+	heartbeatNonce struct {
+		nonce  []byte
+		sentAt time.Time
+	}
+
+	// heartbeatEcho tracks the heartbeat nonces not echoed yet
+	heartbeatEcho struct {
+		mu      sync.Mutex
+		pending []heartbeatNonce
+		oldest  time.Time // send time of the oldest unechoed nonce
+	}
+)
+
+// SetHeartbeatEcho makes HeartbeatInitiate send a random nonce, 16 hex
+// characters, as the body of every heartbeat instead of the SetHeartBeat
+// body, and expect the server heartbeats to echo it. A server heartbeat
+// without a pending nonce is not counted as liveness, and a nonce left
+// unechoed for two intervals closes the connection with ErrHeartbeatEcho,
+// exposing half-open connections and proxies answering heartbeats on
+// behalf of a dead server. Only for servers echoing the heartbeat body,
+// it must be set before Run.
+func (c *Connector) SetHeartbeatEcho(on bool) {
+	c.heartbeatEcho = on
+}
+
+// echoesHeartbeats reports whether heartbeat nonces are sent and verified
+func (c *Connector) echoesHeartbeats() bool {
+	return c.heartbeatEcho && c.heartbeatMode == HeartbeatInitiate
+}
+
+// resetEcho forgets the nonces of the previous connection
+func (c *Connector) resetEcho() {
+	c.echo.mu.Lock()
+	c.echo.pending = nil
+	c.echo.oldest = time.Time{}
+	c.echo.mu.Unlock()
+}
+
+// nextNonce returns the body of the next heartbeat
+func (c *Connector) nextNonce() []byte {
+	var raw [8]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		c.logWarn("heartbeat nonce failed", Field{"error", err})
+	}
+	nonce := make([]byte, hex.EncodedLen(len(raw)))
+	hex.Encode(nonce, raw[:])
+
+	now := c.clock.Now()
+	c.echo.mu.Lock()
+	defer c.echo.mu.Unlock()
+
+	if len(c.echo.pending) == 0 {
+		c.echo.oldest = now
+	}
+	if len(c.echo.pending) == maxEchoNonces {
+		c.echo.pending = c.echo.pending[1:]
+	}
+	c.echo.pending = append(c.echo.pending, heartbeatNonce{nonce: nonce, sentAt: now})
+	return nonce
+}
+
+// verifyEcho reports whether the server heartbeat body echoes a pending
+// nonce, which and the older ones are then acknowledged
+func (c *Connector) verifyEcho(data []byte) bool {
+	c.echo.mu.Lock()
+	defer c.echo.mu.Unlock()
+
+	for i, n := range c.echo.pending {
+		if !bytes.Equal(n.nonce, data) {
+			continue
+		}
+		c.echo.pending = c.echo.pending[i+1:]
+		if len(c.echo.pending) > 0 {
+			c.echo.oldest = c.echo.pending[0].sentAt
+		} else {
+			c.echo.oldest = time.Time{}
+		}
+		return true
+	}
+	return false
+}
+
+// echoOverdue reports whether a nonce is unechoed for longer than d
+func (c *Connector) echoOverdue(d time.Duration) bool {
+	c.echo.mu.Lock()
+	defer c.echo.mu.Unlock()
+
+	return !c.echo.oldest.IsZero() && c.clock.Since(c.echo.oldest) > d
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ResponseError is a response carrying a registered error code, it
+// unwraps to the registered error so errors.Is matches it.
+type ResponseError struct {
+	Route string
+	Code  int
+	Err   error
+	Data  []byte // raw response body
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s: code %d: %v", e.Route, e.Code, e.Err)
+}
+
+// Unwrap --
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// RegisterErrorCode maps a pomelo response code to err, responses with a
+// json "code" field equal to code are delivered to the request error
+// handler (and returned by Call) as a *ResponseError wrapping err.
+func (c *Connector) RegisterErrorCode(code int, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if err == nil {
+		delete(c.errCodes, code)
+		return
+	}
+	c.errCodes[code] = err
+}
+
+// responseError returns the registered error of the response, if any
+func (c *Connector) responseError(route string, data []byte) error {
+	c.RLock()
+	n := len(c.errCodes)
+	c.RUnlock()
+	if n == 0 || !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil
+	}
+
+	var body struct {
+		Code *int `json:"code"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil || body.Code == nil {
+		return nil
+	}
+
+	c.RLock()
+	err, ok := c.errCodes[*body.Code]
+	c.RUnlock()
+	if !ok {
+		return nil
+	}
+	return &ResponseError{Route: route, Code: *body.Code, Err: err, Data: cloneBytes(data)}
+}
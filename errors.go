@@ -0,0 +1,54 @@
+package client
+
+import "errors"
+
+/**
+ * ==========================
+ *    Connector Error Types
+ * ==========================
+ *
+ * ErrConnectorClosed
+ * ErrRequestTimeout
+ * ErrCircuitOpen
+ * ErrBulkheadFull
+ * ErrDraining
+ * ErrDrainTimeout
+ * ErrProtocolVersionMismatch
+ * ErrNoCompressor
+ * ErrHeartbeatTimeout
+ * ErrHeartbeatEcho
+ * ErrUnknownTransport
+ * ErrProtocolDesync
+ * ErrBackpressure
+ * ErrChannelClosed
+ * ErrRouteBlocked
+ * ErrStreamCanceled
+ * ErrRequestPruned
+ * ErrAckTimeout
+ * ErrSchemaViolation
+ * ErrNoIdentity
+ *
+ */
+var (
+	ErrConnectorClosed  = errors.New("connector is closed")
+	ErrRequestTimeout   = errors.New("request timeout")
+	ErrCircuitOpen      = errors.New("circuit open for route")
+	ErrBulkheadFull     = errors.New("too many in-flight requests for route")
+	ErrDraining         = errors.New("connector is draining")
+	ErrDrainTimeout     = errors.New("drain timeout")
+	ErrNoCompressor     = errors.New("compressed payload but no compression negotiated")
+	ErrHeartbeatTimeout = errors.New("heartbeat timeout")
+	ErrHeartbeatEcho    = errors.New("heartbeat not echoed")
+	ErrUnknownTransport = errors.New("unknown transport scheme")
+	ErrProtocolDesync   = errors.New("packet stream desynchronized")
+	ErrBackpressure     = errors.New("too many in-flight requests")
+	ErrChannelClosed    = errors.New("mux channel is closed")
+	ErrRouteBlocked     = errors.New("route blocked by the outbound filter")
+	ErrStreamCanceled   = errors.New("response stream canceled")
+	ErrRequestPruned    = errors.New("stale request pruned")
+	ErrAckTimeout       = errors.New("notify ack timeout")
+	ErrSchemaViolation  = errors.New("schema violation")
+	ErrNoIdentity       = errors.New("no identity available")
+
+	ErrProtocolVersionMismatch = errors.New("protocol version mismatch")
+)
@@ -45,8 +45,9 @@ func InitPomeloClient(addr string) {
 			panic(err)
 		}
 	})
+	PomeloClient.SetTickrate(2)
 	go func() {
-		err := PomeloClient.Run(addr, true, 2)
+		err := PomeloClient.Run(addr)
 		if err != nil {
 			panic(err)
 		}
@@ -61,15 +62,21 @@ func InitPomeloClient(addr string) {
 	PomeloClient.On("onMessage", func(data []byte) {
 		log.Println("onMessage", string(data))
 	})
-	defer PomeloClient.Close()
 }
 
 func handleClose() {
-	sigChan := make(chan os.Signal)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	drained := client.DrainOnSignal(PomeloClient, syscall.SIGTERM, 5*time.Second)
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt)
 	go func() {
-		<-sigChan
-		PomeloClient.Close()
+		select {
+		case <-sigChan:
+			PomeloClient.Close()
+		case err := <-drained:
+			if err != nil {
+				log.Println("drain err:", err)
+			}
+		}
 		os.Exit(1)
 	}()
 }
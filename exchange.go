@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultExchangeBody is the default number of body bytes kept in the
+// exchange records
+const DefaultExchangeBody = 256
+
+// ExchangeRecord is the NDJSON line written for every completed request.
+// Bodies that are not valid UTF-8, e.g. protobuf, are base64 encoded and
+// prefixed with "base64:".
+type ExchangeRecord struct {
+	Time         time.Time `json:"time"` // request sent
+	Route        string    `json:"route"`
+	Mid          uint      `json:"mid"`
+	RequestSize  int       `json:"req_size"`  // payload bytes
+	ResponseSize int       `json:"resp_size"` // payload bytes, zero when failed
+	Latency      float64   `json:"latency_ms"`
+	Request      string    `json:"req,omitempty"`
+	Response     string    `json:"resp,omitempty"`
+	Truncated    bool      `json:"truncated,omitempty"` // a body was cut to the limit
+	Error        string    `json:"error,omitempty"`
+}
+
+type exchangeLog struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	maxBody int
+}
+
+// SetExchangeLog writes an ExchangeRecord line to w for every request
+// answered or failed, keeping at most maxBody bytes of each body (zero
+// keeps none, negative keeps DefaultExchangeBody). Diffing the logs of
+// two builds shows the requests they disagree on, and a log keeping the
+// full bodies is a session package mockserver serves back. A nil w stops
+// the log. It must be set before Run.
+func (c *Connector) SetExchangeLog(w io.Writer, maxBody int) {
+	if w == nil {
+		c.exchanges = nil
+		return
+	}
+	if maxBody < 0 {
+		maxBody = DefaultExchangeBody
+	}
+	c.exchanges = &exchangeLog{enc: json.NewEncoder(w), maxBody: maxBody}
+}
+
+// keepExchange records the request payload in pr for the exchange log
+func (c *Connector) keepExchange(pr *pendingRequest, data []byte) {
+	if c.exchanges == nil {
+		return
+	}
+	pr.size = len(data)
+	if n := c.exchanges.maxBody; len(data) > n {
+		data = data[:n]
+	}
+	// the payload may be reused by the caller, keep a private copy
+	pr.body = cloneBytes(data)
+}
+
+// logExchange writes the record of pr, completed with data or err
+func (c *Connector) logExchange(pr *pendingRequest, data []byte, err error) {
+	l := c.exchanges
+	if l == nil {
+		return
+	}
+
+	r := ExchangeRecord{
+		Time:         pr.sentAt,
+		Route:        pr.route,
+		Mid:          pr.mid,
+		RequestSize:  pr.size,
+		ResponseSize: len(data),
+		Latency:      float64(c.clock.Since(pr.sentAt)) / float64(time.Millisecond),
+	}
+	reqCut := pr.size > len(pr.body)
+	respCut := len(data) > l.maxBody
+	if respCut {
+		data = data[:l.maxBody]
+	}
+	r.Request = exchangeBody(pr.body, reqCut)
+	r.Response = exchangeBody(data, respCut)
+	r.Truncated = reqCut || respCut
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.enc.Encode(r); err != nil {
+		c.logWarn("exchange log failed", Field{"error", err})
+	}
+}
+
+// exchangeBody returns data as a record body, cut tells data was
+// truncated
+func exchangeBody(data []byte, cut bool) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	if cut {
+		// the cut may split the last rune
+		for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+			if utf8.Valid(data[:len(data)-i]) {
+				return string(data[:len(data)-i])
+			}
+		}
+	}
+	return "base64:" + base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,230 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultFailoverBackoff is the default delay before a failed connection
+// of a Failover is dialed again
+const DefaultFailoverBackoff = time.Second
+
+// Failover keeps a warm standby connection, handshaken, to a secondary
+// endpoint next to the active one, and switches the traffic to it as
+// soon as the active connection fails, without waiting for a reconnect.
+// The failed side reconnects in the background with a new connector and
+// becomes the standby, a connector handed out by Active is never reused.
+// Requests in flight on the failed connection fail like on any
+// disconnect, see SetReplayPolicy.
+type Failover struct {
+	mu       sync.RWMutex
+	template *Connector
+	conns    [2]*Connector
+	addrs    [2]string
+	events   map[string]Callback // added with On, bound to every connector
+	active   int
+	closed   bool
+	stop     chan struct{} // closed by Close
+	backoff  time.Duration
+	onSwitch func(from, to string)
+}
+
+// NewFailover returns a failover between primary, active first, and
+// secondary, the standby. The connectors are clones of template, which
+// is not run itself: configure it, handlers included, beforehand.
+func NewFailover(template *Connector, primary, secondary string) *Failover {
+	f := &Failover{
+		template: template.CloneConfig(),
+		addrs:    [2]string{primary, secondary},
+		events:   map[string]Callback{},
+		stop:     make(chan struct{}),
+		backoff:  DefaultFailoverBackoff,
+	}
+	for i := range f.conns {
+		f.conns[i] = f.template.CloneConfig()
+	}
+	return f
+}
+
+// SetBackoff sets the delay before a failed connection is dialed again,
+// it must be set before Start
+func (f *Failover) SetBackoff(d time.Duration) {
+	f.backoff = d
+}
+
+// OnSwitch sets the hook called with the addresses when the traffic
+// switches to the other connection, it must be set before Start
+func (f *Failover) OnSwitch(hook func(from, to string)) {
+	f.onSwitch = hook
+}
+
+// Start runs both connections in the background until Close
+func (f *Failover) Start() {
+	for i := range f.conns {
+		go f.supervise(i)
+	}
+}
+
+// Active returns the connector carrying the traffic
+func (f *Failover) Active() *Connector {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.conns[f.active]
+}
+
+// Standby returns the connector waiting to take over
+func (f *Failover) Standby() *Connector {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.conns[1-f.active]
+}
+
+// On adds a callback for the event on both connectors, only the pushes
+// of the active connection are delivered
+func (f *Failover) On(event string, callback Callback) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.events[event] = callback
+	for _, c := range f.conns {
+		f.bind(c, event, callback)
+	}
+}
+
+// bind adds the callback of a Failover handler to c
+func (f *Failover) bind(c *Connector, event string, callback Callback) {
+	c.On(event, func(data []byte) {
+		if f.Active() == c {
+			callback(data)
+		}
+	})
+}
+
+// Request sends a request on the active connection
+func (f *Failover) Request(route string, data []byte, callback Callback, opts ...RequestOption) error {
+	return f.Active().Request(route, data, callback, opts...)
+}
+
+// Notify sends a notification on the active connection
+func (f *Failover) Notify(route string, data []byte) error {
+	return f.Active().Notify(route, data)
+}
+
+// Close closes both connections, it returns the first close error
+func (f *Failover) Close() error {
+	f.mu.Lock()
+	if !f.closed {
+		f.closed = true
+		close(f.stop)
+	}
+	conns := f.conns
+	f.mu.Unlock()
+
+	var first error
+	for _, c := range conns {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (f *Failover) isClosed() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.closed
+}
+
+// supervise runs connection i, replacing it after every failure
+func (f *Failover) supervise(i int) {
+	for !f.isClosed() {
+		f.mu.RLock()
+		c := f.conns[i]
+		f.mu.RUnlock()
+
+		ready, die := c.Ready(), c.done()
+		go func() {
+			select {
+			case <-ready:
+				f.up(i)
+			case <-die:
+			case <-f.stop:
+			}
+		}()
+
+		err := c.Run(f.addrs[i])
+		if f.isClosed() {
+			return
+		}
+		c.logWarn("failover connection lost", Field{"addr", f.addrs[i]}, Field{"error", err})
+		f.down(i)
+		// a failed dial returns without closing c, release it
+		c.Close()
+
+		select {
+		case <-time.After(f.backoff):
+		case <-f.stop:
+			return
+		}
+		if !f.replace(i) {
+			return
+		}
+	}
+}
+
+// replace swaps a new connector in for connection i, the failed one is
+// closed and fails the calls of those still holding it from Active. It
+// reports false once closed.
+func (f *Failover) replace(i int) bool {
+	n := f.template.CloneConfig()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return false
+	}
+	for event, callback := range f.events {
+		f.bind(n, event, callback)
+	}
+	f.conns[i] = n
+	return true
+}
+
+// up takes the traffic back with connection i if the active one is down
+func (f *Failover) up(i int) {
+	f.mu.Lock()
+	if f.closed || f.active == i || f.conns[f.active].isReady() {
+		f.mu.Unlock()
+		return
+	}
+	f.switchLocked(i)
+}
+
+// down moves the traffic off the failed connection i if the other one
+// is ready
+func (f *Failover) down(i int) {
+	f.mu.Lock()
+	other := 1 - i
+	if f.closed || f.active != i || !f.conns[other].isReady() {
+		f.mu.Unlock()
+		return
+	}
+	f.switchLocked(other)
+}
+
+// switchLocked activates connection i and unlocks f
+func (f *Failover) switchLocked(i int) {
+	from, to := f.addrs[f.active], f.addrs[i]
+	f.active = i
+	hook := f.onSwitch
+	f.mu.Unlock()
+
+	f.conns[i].logInfo("failover switched", Field{"from", from}, Field{"to", to})
+	if hook != nil {
+		hook(from, to)
+	}
+}
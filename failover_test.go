@@ -0,0 +1,97 @@
+package client
+
+import (
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFailoverReplacesFailedConnector(t *testing.T) {
+	primary, secondary := newTestServer(t, nil), newTestServer(t, nil)
+	f := NewFailover(newTestConnector(t), primary.addr(), secondary.addr())
+	f.SetBackoff(10 * time.Millisecond)
+	f.Start()
+	defer f.Close()
+
+	first := primary.next()
+	secondary.next()
+	f.mu.RLock()
+	old := f.conns[0]
+	f.mu.RUnlock()
+	waitFor(t, "primary ready", old.isReady)
+
+	first.conn.Close()
+	waitFor(t, "switch", func() bool { a := f.Active(); return a != old && a.isReady() })
+
+	// the failed side comes back on a new connector
+	primary.next()
+	f.mu.RLock()
+	renewed := f.conns[0]
+	f.mu.RUnlock()
+	if renewed == old {
+		t.Fatal("failed connector reused")
+	}
+	if !old.isDead() {
+		t.Fatal("failed connector reopened")
+	}
+	if _, err := f.Active().requestSync("echo", []byte(`{}`), WithTimeout(testTimeout)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailoverCloseDuringBackoff(t *testing.T) {
+	primary, secondary := newTestServer(t, nil), newTestServer(t, nil)
+	f := NewFailover(newTestConnector(t), primary.addr(), secondary.addr())
+	f.SetBackoff(100 * time.Millisecond)
+	f.Start()
+
+	first := primary.next()
+	secondary.next()
+	waitFor(t, "primary ready", f.Active().isReady)
+
+	first.conn.Close()
+	waitFor(t, "primary down", func() bool { return f.Standby().isDead() || f.Active().isDead() })
+	f.Close()
+
+	select {
+	case <-primary.conns:
+		t.Fatal("closed failover reconnected")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestFailoverUnreachableDoesNotLeak(t *testing.T) {
+	// a closed listener refuses the connections
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := "tcp://" + ln.Addr().String()
+	ln.Close()
+
+	f := NewFailover(newTestConnector(t), unreachable, unreachable)
+	f.SetBackoff(time.Millisecond)
+	f.Start()
+	defer f.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	before := runtime.NumGoroutine()
+	time.Sleep(300 * time.Millisecond)
+	if after := runtime.NumGoroutine(); after > before+4 {
+		t.Fatalf("%d goroutines after the failed attempts, %d before", after, before)
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RouteFilter selects routes by allow and deny lists. An entry matches
+// the route equal to it or, when it ends with ".*", every route under
+// its prefix ("area.*" matches "area.move"). A denied route is blocked
+// even when allowed, and a non-empty allow list blocks every route it
+// does not match.
+type RouteFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+// routeFilter is a compiled RouteFilter
+type routeFilter struct {
+	allow, deny routeSet
+}
+
+type routeSet struct {
+	routes   map[string]bool
+	prefixes []string
+}
+
+// SetPushFilter filters the inbound pushes, a blocked push is dropped
+// before its handler, replay buffer and unhandled hooks. The zero filter
+// removes it. It must be set before Run.
+func (c *Connector) SetPushFilter(f RouteFilter) {
+	c.pushFilter = compileFilter(f)
+}
+
+// SetOutboundFilter filters the outbound requests and notifies, a
+// blocked one fails with ErrRouteBlocked without being sent. The zero
+// filter removes it. It must be set before Run.
+func (c *Connector) SetOutboundFilter(f RouteFilter) {
+	c.outboundFilter = compileFilter(f)
+}
+
+// checkOutbound returns ErrRouteBlocked if route may not be sent
+func (c *Connector) checkOutbound(route string) error {
+	if !c.outboundFilter.allowed(route) {
+		c.logWarn("outbound route blocked", Field{"route", route})
+		return fmt.Errorf("%w: %s", ErrRouteBlocked, route)
+	}
+	return nil
+}
+
+func compileFilter(f RouteFilter) *routeFilter {
+	if len(f.Allow) == 0 && len(f.Deny) == 0 {
+		return nil
+	}
+	return &routeFilter{allow: newRouteSet(f.Allow), deny: newRouteSet(f.Deny)}
+}
+
+func newRouteSet(entries []string) routeSet {
+	s := routeSet{routes: map[string]bool{}}
+	for _, e := range entries {
+		if strings.HasSuffix(e, ".*") {
+			s.prefixes = append(s.prefixes, e[:len(e)-1])
+			continue
+		}
+		s.routes[e] = true
+	}
+	return s
+}
+
+func (s routeSet) empty() bool {
+	return len(s.routes) == 0 && len(s.prefixes) == 0
+}
+
+func (s routeSet) match(route string) bool {
+	if s.routes[route] {
+		return true
+	}
+	for _, p := range s.prefixes {
+		if strings.HasPrefix(route, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether route passes, a nil filter passes every route
+func (f *routeFilter) allowed(route string) bool {
+	if f == nil {
+		return true
+	}
+	if f.deny.match(route) {
+		return false
+	}
+	return f.allow.empty() || f.allow.match(route)
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// OnStream adds a callback for the event writing every push payload to
+// w, as is and in arrival order, e.g. to a file or a pipe receiving an
+// asset or a patch. The payload is written straight from the read
+// buffer, without copy. After a write error, logged once, the next
+// pushes of route are dropped.
+func (c *Connector) OnStream(route string, w io.Writer) {
+	var failed int32
+	c.On(route, func(data []byte) {
+		if atomic.LoadInt32(&failed) != 0 {
+			c.logDebug("stream push dropped", Field{"route", route}, Field{"bytes", len(data)})
+			return
+		}
+		if _, err := w.Write(data); err != nil {
+			atomic.StoreInt32(&failed, 1)
+			c.logError("stream push write failed", Field{"route", route}, Field{"bytes", len(data)}, Field{"error", err})
+		}
+	})
+}
@@ -3,6 +3,13 @@ module github.com/revzim/go-pomelo-client
 go 1.16
 
 require (
+	github.com/fxamacker/cbor/v2 v2.3.0
+	github.com/golang/mock v1.6.0
+	github.com/golang/snappy v0.0.4
+	github.com/klauspost/compress v1.13.6
 	github.com/urfave/cli v1.22.5
+	go.uber.org/zap v1.19.1
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 	golang.org/x/net v0.0.0-20210917221730-978cfadd31cf
+	google.golang.org/protobuf v1.27.1
 )
@@ -0,0 +1,235 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+// Handshake response codes
+const (
+	HandshakeCodeOK        = 200
+	HandshakeCodeOldClient = 501
+)
+
+// ProtocolVersionError reports a handshake version mismatch, it matches
+// ErrProtocolVersionMismatch with errors.Is.
+type ProtocolVersionError struct {
+	Client string // sys.version sent by the client
+	Server string // sys.version answered by the server, may be empty
+}
+
+func (e *ProtocolVersionError) Error() string {
+	return fmt.Sprintf("%s: client %q, server %q", ErrProtocolVersionMismatch, e.Client, e.Server)
+}
+
+// Is --
+func (e *ProtocolVersionError) Is(target error) bool {
+	return target == ErrProtocolVersionMismatch
+}
+
+// HandshakeAck is the handshake ack body of a connection, its sys and
+// user data are encoded as distinct JSON objects
+type HandshakeAck struct {
+	Sys  map[string]interface{} `json:"sys,omitempty"`
+	User map[string]interface{} `json:"user,omitempty"`
+}
+
+// SetHandshakeAckBuilder builds the handshake ack body from the handshake
+// response of every connection, it replaces the fixed SetHandshakeAck
+// body. A nil ack sends an empty body, an error closes the connection.
+// A nil builder restores the fixed body.
+func (c *Connector) SetHandshakeAckBuilder(build func(resp *DefaultHandshakePacket) (*HandshakeAck, error)) {
+	c.handshakeAckBuilder = build
+}
+
+// handshakeAck returns the handshake ack body for resp
+func (c *Connector) handshakeAck(resp *DefaultHandshakePacket) ([]byte, error) {
+	if c.handshakeAckBuilder == nil {
+		return c.handshakeAckData, nil
+	}
+	ack, err := c.handshakeAckBuilder(resp)
+	if err != nil || ack == nil {
+		return nil, err
+	}
+	return json.Marshal(ack)
+}
+
+func (c *Connector) processHandshake(p *packet.Packet) {
+	var handshakeResp DefaultHandshakePacket
+	err := decodeHandshake(p.Data, &handshakeResp)
+	if err != nil {
+		c.logError("bad handshake response", Field{"bytes", len(p.Data)}, Field{"error", err})
+		c.closeWithReason(DisconnectHandshake, err)
+		return
+	}
+	c.logInfo("handshake response", Field{"code", handshakeResp.Code})
+
+	if err := c.checkVersion(&handshakeResp); err != nil {
+		c.logError("handshake version mismatch", Field{"error", err})
+		c.closeWithReason(DisconnectHandshake, err)
+		return
+	}
+
+	if handshakeResp.Code != HandshakeCodeOK {
+		c.logError("bad packet handshake code, not 200", Field{"code", handshakeResp.Code}, Field{"data", string(p.Data)})
+		c.closeWithReason(DisconnectHandshake, fmt.Errorf("handshake failed with code %d", handshakeResp.Code))
+		return
+	}
+
+	c.muConn.Lock()
+	c.serverVersion = handshakeResp.Sys.Version
+	c.handshakeResp = &handshakeResp
+	c.muConn.Unlock()
+	c.setWireProtos(handshakeResp.Sys.Protos)
+	c.dict.Set(handshakeResp.Sys.Dict)
+	c.selectCompressor(&handshakeResp)
+
+	heartbeat := c.effectiveHeartbeat(time.Second * time.Duration(handshakeResp.Sys.Heartbeat))
+	c.updateReport(func(r *ConnectReport) {
+		if !r.sentAt.IsZero() {
+			r.Handshake = c.clock.Since(r.sentAt)
+		}
+		r.ServerVersion = handshakeResp.Sys.Version
+		r.Heartbeat = heartbeat
+		r.Compression, _ = handshakeResp.User[HandshakeUserCompression].(string)
+		r.Dictionary = len(handshakeResp.Sys.Dict)
+		r.Protos = handshakeResp.Sys.Protos != nil
+	})
+	c.startHeartbeat(heartbeat)
+	ack, err := c.handshakeAck(&handshakeResp)
+	if err != nil {
+		c.logError("handshake ack build failed", Field{"error", err})
+		c.closeWithReason(DisconnectHandshake, err)
+		return
+	}
+	if err := c.sendPacket(packet.HandshakeAck, ack); err != nil {
+		c.logError("handshake ack encode failed", Field{"error", err})
+		c.closeWithReason(DisconnectHandshake, err)
+		return
+	}
+	c.muConn.Lock()
+	c.handshakeAckSent = ack
+	c.muConn.Unlock()
+	if len(c.scriptSteps()) > 0 {
+		// the script waits for responses, it can't block the read loop
+		gen := c.generation()
+		c.loops.Add(1)
+		go func() {
+			defer c.loops.Done()
+			if err := c.runConnectScript(); err != nil {
+				c.logError("connect script failed", Field{"error", err})
+				c.closeConn(gen, DisconnectHandshake, err)
+				return
+			}
+			c.connected()
+		}()
+	} else {
+		c.connected()
+	}
+}
+
+// HandshakeResponse returns the last handshake response accepted, false
+// before the first one
+func (c *Connector) HandshakeResponse() (DefaultHandshakePacket, bool) {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	if c.handshakeResp == nil {
+		return DefaultHandshakePacket{}, false
+	}
+	return *c.handshakeResp, true
+}
+
+// Ready returns a channel closed once the handshake ack has been sent and
+// the connect script succeeded, i.e. when Connected fires. A new channel
+// is used after Reset.
+func (c *Connector) Ready() <-chan struct{} {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	return c.ready
+}
+
+// connected marks the connector ready and fires the Connected callback
+func (c *Connector) connected() {
+	c.muConn.Lock()
+	select {
+	case <-c.ready:
+	default:
+		close(c.ready)
+		if c.report != nil {
+			c.report.Total = c.clock.Since(c.report.StartedAt)
+		}
+	}
+	c.muConn.Unlock()
+
+	c.endOutage()
+	c.openWindow()
+	c.replayPending()
+	c.startSchedules()
+	c.publish(LifecycleEvent{Kind: EventHandshakeComplete})
+}
+
+// checkVersion compares the client and server sys.version
+func (c *Connector) checkVersion(resp *DefaultHandshakePacket) error {
+	if resp.Code == HandshakeCodeOldClient {
+		return &ProtocolVersionError{Client: c.handshakeVersion, Server: resp.Sys.Version}
+	}
+	if resp.Sys.Version == "" || c.handshakeVersion == "" {
+		return nil
+	}
+	if resp.Sys.Version != c.handshakeVersion {
+		return &ProtocolVersionError{Client: c.handshakeVersion, Server: resp.Sys.Version}
+	}
+	return nil
+}
+
+// handshakeBody returns the handshake body sent, see handshakeJSON
+func (c *Connector) handshakeBody() []byte {
+	data := c.handshakeJSON()
+	if c.handshakeGzip {
+		return c.gzipHandshake(data)
+	}
+	return data
+}
+
+// handshakeJSON returns the handshake body with the offered compressors
+// and the session values added to the user data.
+func (c *Connector) handshakeJSON() []byte {
+	extra := map[string]interface{}{}
+	if len(c.compressors) > 0 {
+		extra[HandshakeUserCompressions] = c.offeredCompressions()
+	}
+	if values := c.session.Values(); len(values) > 0 {
+		extra[HandshakeUserSession] = values
+	}
+	for k, v := range c.identityUser() {
+		extra[k] = v
+	}
+	if len(extra) == 0 {
+		return c.handshakeData
+	}
+
+	var hs map[string]interface{}
+	if err := json.Unmarshal(c.handshakeData, &hs); err != nil || hs == nil {
+		c.logWarn("handshake is not a json object, user data not extended", Field{"error", err})
+		return c.handshakeData
+	}
+	user, ok := hs["user"].(map[string]interface{})
+	if !ok {
+		user = map[string]interface{}{}
+	}
+	for k, v := range extra {
+		user[k] = v
+	}
+	hs["user"] = user
+
+	data, err := json.Marshal(hs)
+	if err != nil {
+		return c.handshakeData
+	}
+	return data
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/revzim/go-pomelo-client/compress/gzip"
+)
+
+var gzipCompressor = gzip.NewCompressor()
+
+// SetHandshakeGzip compresses the handshake user data, for servers with
+// handshake gzip enabled: sys.gzip is set and user is replaced by the
+// base64 of the gzipped JSON user data. Compressed handshake responses,
+// flagged the same way, are decompressed whether or not it is set. It
+// must be set before Run.
+func (c *Connector) SetHandshakeGzip(on bool) {
+	c.handshakeGzip = on
+}
+
+// gzipHandshake returns the handshake body with the user data compressed
+func (c *Connector) gzipHandshake(body []byte) []byte {
+	var hs map[string]interface{}
+	if err := json.Unmarshal(body, &hs); err != nil || hs == nil {
+		c.logWarn("handshake is not a json object, user data not compressed", Field{"error", err})
+		return body
+	}
+	user, ok := hs["user"]
+	if !ok || user == nil {
+		return body
+	}
+	sys, ok := hs["sys"].(map[string]interface{})
+	if !ok {
+		sys = map[string]interface{}{}
+	}
+
+	plain, err := json.Marshal(user)
+	if err != nil {
+		return body
+	}
+	packed, err := gzipCompressor.Compress(plain)
+	if err != nil {
+		c.logWarn("handshake user data compression failed", Field{"error", err})
+		return body
+	}
+	sys["gzip"] = true
+	hs["sys"] = sys
+	hs["user"] = base64.StdEncoding.EncodeToString(packed)
+
+	data, err := json.Marshal(hs)
+	if err != nil {
+		return body
+	}
+	return data
+}
+
+// decodeHandshake unmarshals a handshake response, the user data is
+// decompressed when sys.gzip is set
+func decodeHandshake(data []byte, resp *DefaultHandshakePacket) error {
+	var raw struct {
+		Code int              `json:"code"`
+		Sys  HeartbeatSysOpts `json:"sys"`
+		User json.RawMessage  `json:"user,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	resp.Code, resp.Sys = raw.Code, raw.Sys
+
+	user := []byte(raw.User)
+	if raw.Sys.Gzip && len(user) > 0 && user[0] == '"' {
+		var encoded string
+		if err := json.Unmarshal(user, &encoded); err != nil {
+			return err
+		}
+		packed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return err
+		}
+		if user, err = gzipCompressor.Decompress(packed); err != nil {
+			return err
+		}
+	}
+	if len(user) == 0 {
+		return nil
+	}
+	return json.Unmarshal(user, &resp.User)
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// Negotiated are the connection parameters settled by the last handshake
+type Negotiated struct {
+	ServerVersion string
+	Heartbeat     time.Duration // effective interval, after override and clamp
+	Compression   string        // chosen compressor, empty when none
+	Dictionary    int           // routes of the dictionary
+	Protos        bool          // protobuf schemas announced
+	HandshakeAck  interface{}   // ack body sent, decoded, nil when empty
+}
+
+// HandshakePayload returns the handshake body sent on the next
+// connection, decoded, with the offered compressions and the session
+// values merged into the user data. It reports false without handshake.
+func (c *Connector) HandshakePayload() (interface{}, bool) {
+	if c.handshakeData == nil {
+		return nil, false
+	}
+	return decodePayload(c.handshakeJSON())
+}
+
+// HandshakeAckPayload returns the fixed handshake ack body, decoded. It
+// reports false when the ack is empty or built per connection by
+// SetHandshakeAckBuilder, see Negotiated for the body actually sent.
+func (c *Connector) HandshakeAckPayload() (interface{}, bool) {
+	if c.handshakeAckBuilder != nil {
+		return nil, false
+	}
+	return decodePayload(c.handshakeAckData)
+}
+
+// HeartbeatPayload returns the heartbeat body, decoded, it reports false
+// when heartbeats are empty. With SetHeartbeatEcho the heartbeats
+// initiated by the client carry a nonce instead.
+func (c *Connector) HeartbeatPayload() (interface{}, bool) {
+	return decodePayload(c.heartbeatData)
+}
+
+// Negotiated returns the parameters of the last handshake, false before
+// the first one
+func (c *Connector) Negotiated() (Negotiated, bool) {
+	c.muConn.RLock()
+	resp := c.handshakeResp
+	ack := c.handshakeAckSent
+	comp := c.compressor
+	c.muConn.RUnlock()
+	if resp == nil {
+		return Negotiated{}, false
+	}
+
+	n := Negotiated{
+		ServerVersion: resp.Sys.Version,
+		Heartbeat:     time.Duration(atomic.LoadInt64(&c.heartbeatInterval)),
+		Dictionary:    len(resp.Sys.Dict),
+		Protos:        resp.Sys.Protos != nil,
+	}
+	if comp != nil {
+		n.Compression = comp.Name()
+	}
+	n.HandshakeAck, _ = decodePayload(ack)
+	return n, true
+}
+
+// decodePayload decodes a JSON packet body, false when empty or invalid
+func decodePayload(data []byte) (interface{}, bool) {
+	if len(data) == 0 {
+		return nil, false
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
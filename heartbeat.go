@@ -0,0 +1,196 @@
+package client
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+// HeartbeatMode selects which side drives the heartbeats
+type HeartbeatMode int
+
+const (
+	// HeartbeatInitiate sends a heartbeat every negotiated interval
+	HeartbeatInitiate HeartbeatMode = iota
+	// HeartbeatRespond echoes every server heartbeat immediately and
+	// closes the connection with ErrHeartbeatTimeout when the server
+	// heartbeats stop for two intervals.
+	HeartbeatRespond
+)
+
+// SetHeartbeatMode sets the heartbeat mode, HeartbeatInitiate by default
+func (c *Connector) SetHeartbeatMode(mode HeartbeatMode) {
+	c.heartbeatMode = mode
+}
+
+// SetHeartbeatInterval overrides the heartbeat interval advertised by
+// the server, zero restores it.
+func (c *Connector) SetHeartbeatInterval(d time.Duration) {
+	c.heartbeatOverride = d
+}
+
+// SetHeartbeatBounds clamps the heartbeat interval advertised by the
+// server into [min, max], a zero bound is not enforced. A server
+// disabling heartbeats is left alone.
+func (c *Connector) SetHeartbeatBounds(min, max time.Duration) {
+	c.heartbeatMin, c.heartbeatMax = min, max
+}
+
+// SetHeartbeatGrace suppresses the server heartbeat timeout of
+// HeartbeatRespond while a packet spanning several reads is being
+// received and for grace after its last bytes, so a multi-megabyte
+// payload saturating a slow link, and the heartbeats queued behind it, is
+// not taken for a dead server. Zero (the default) disables it.
+func (c *Connector) SetHeartbeatGrace(grace time.Duration) {
+	c.heartbeatGrace = grace
+}
+
+// effectiveHeartbeat returns the interval to use for the advertised one
+func (c *Connector) effectiveHeartbeat(advertised time.Duration) time.Duration {
+	if c.heartbeatOverride > 0 {
+		return c.heartbeatOverride
+	}
+	if advertised <= 0 {
+		return advertised
+	}
+
+	d := advertised
+	if c.heartbeatMin > 0 && d < c.heartbeatMin {
+		d = c.heartbeatMin
+	}
+	if c.heartbeatMax > 0 && d > c.heartbeatMax {
+		d = c.heartbeatMax
+	}
+	if d != advertised {
+		c.logWarn("heartbeat interval clamped", Field{"advertised", advertised}, Field{"interval", d})
+	}
+	return d
+}
+
+// startHeartbeat runs the heartbeat loop until the connector is closed
+func (c *Connector) startHeartbeat(interval time.Duration) {
+	if interval <= 0 {
+		// heartbeat disabled by the server
+		return
+	}
+
+	die, gen := c.done(), c.generation()
+	atomic.StoreInt64(&c.heartbeatInterval, int64(interval))
+	c.touchHeartbeat()
+	c.resetEcho()
+	// armed before returning so that a fake clock advanced right after
+	// the handshake already sees the ticker
+	ticker := c.clock.NewTicker(interval)
+	c.loops.Add(1)
+	go func() {
+		defer c.loops.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-die:
+				return
+			case <-ticker.C():
+			}
+
+			if c.heartbeatMode == HeartbeatRespond {
+				if c.clock.Since(c.lastHeartbeat()) > 2*interval && !c.inHeartbeatGrace() {
+					c.logError("server heartbeat timeout", Field{"interval", interval})
+					c.closeConn(gen, DisconnectHeartbeatTimeout, ErrHeartbeatTimeout)
+					return
+				}
+				continue
+			}
+			data := c.heartbeatData
+			if c.echoesHeartbeats() {
+				if c.echoOverdue(2 * interval) {
+					c.logError("heartbeat echo timeout", Field{"interval", interval})
+					c.closeConn(gen, DisconnectHeartbeatTimeout, ErrHeartbeatEcho)
+					return
+				}
+				data = c.nextNonce()
+			}
+			c.sendHeartbeat(data)
+		}
+	}()
+}
+
+// processHeartbeat handles a heartbeat sent by the server
+func (c *Connector) processHeartbeat(data []byte) {
+	if c.echoesHeartbeats() && !c.verifyEcho(data) {
+		c.logWarn("heartbeat without echo", Field{"bytes", len(data)})
+		return
+	}
+	c.touchHeartbeat()
+	if c.heartbeatMode != HeartbeatRespond {
+		return
+	}
+	c.sendHeartbeat(c.heartbeatData)
+}
+
+func (c *Connector) sendHeartbeat(data []byte) {
+	if err := c.sendPacket(packet.Heartbeat, data); err != nil {
+		c.logError("heartbeat encode failed", Field{"error", err})
+		return
+	}
+	c.publish(LifecycleEvent{Kind: EventHeartbeatSent})
+}
+
+func (c *Connector) touchHeartbeat() {
+	atomic.StoreInt64(&c.heartbeatAt, c.clock.Now().UnixNano())
+}
+
+func (c *Connector) lastHeartbeat() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.heartbeatAt))
+}
+
+// touchStream records bytes of a partially received packet
+func (c *Connector) touchStream() {
+	atomic.StoreInt64(&c.streamAt, c.clock.Now().UnixNano())
+}
+
+// inHeartbeatGrace reports whether a large packet is being, or was just,
+// received
+func (c *Connector) inHeartbeatGrace() bool {
+	if c.heartbeatGrace <= 0 {
+		return false
+	}
+	at := atomic.LoadInt64(&c.streamAt)
+	return at != 0 && c.clock.Since(time.Unix(0, at)) <= c.heartbeatGrace
+}
+
+func (c *Connector) touchPacket() {
+	atomic.StoreInt64(&c.packetAt, c.clock.Now().UnixNano())
+}
+
+// LastPacketReceivedAt returns when the last packet of any type was
+// received, the zero time if none was.
+func (c *Connector) LastPacketReceivedAt() time.Time {
+	return unixNano(atomic.LoadInt64(&c.packetAt))
+}
+
+// LastHeartbeatAt returns when the last server heartbeat was received,
+// the handshake time until the first one and the zero time before the
+// handshake.
+func (c *Connector) LastHeartbeatAt() time.Time {
+	return unixNano(atomic.LoadInt64(&c.heartbeatAt))
+}
+
+// MissedHeartbeats returns the number of negotiated heartbeat intervals
+// elapsed since the last server heartbeat, 0 while heartbeats are
+// disabled or not negotiated yet.
+func (c *Connector) MissedHeartbeats() int {
+	interval := time.Duration(atomic.LoadInt64(&c.heartbeatInterval))
+	at := atomic.LoadInt64(&c.heartbeatAt)
+	if interval <= 0 || at == 0 {
+		return 0
+	}
+	return int(c.clock.Since(time.Unix(0, at)) / interval)
+}
+
+func unixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,217 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/clock"
+)
+
+type (
+	// Identity is the account a connection authenticates as
+	Identity struct {
+		// Name identifies the account, it must be unique in a provider
+		Name string
+		// User is merged into the handshake user data, e.g. a token
+		User map[string]interface{}
+		// Auth are requests run after the handshake, before the connect
+		// script, e.g. a login with the account credentials
+		Auth []ConnectStep
+	}
+
+	// IdentityProvider hands out the identities of the connections. It
+	// must be safe for concurrent use when shared, e.g. by a Pool.
+	IdentityProvider interface {
+		// Acquire returns the identity of a new connection
+		Acquire() (Identity, error)
+		// Release returns the identity of a closed connection, err is
+		// the error it was closed with, nil after Close
+		Release(id Identity, err error)
+	}
+
+	// RotationPolicy configures an IdentityRotation
+	RotationPolicy struct {
+		// Cooldown is the rest of an identity after every connection,
+		// spreading the logins of an account below the server limits
+		Cooldown time.Duration
+		// FailureCooldown replaces Cooldown after a connection closed on
+		// an error, e.g. a kick for exceeding a per account rate limit
+		FailureCooldown time.Duration
+		// MaxUses retires an identity after that many connections, zero
+		// is unlimited
+		MaxUses int
+	}
+
+	// IdentityRotation is an IdentityProvider handing out every identity
+	// to one connection at a time, in rotation, skipping the identities
+	// resting after their last connection.
+	IdentityRotation struct {
+		mu     sync.Mutex
+		ids    []rotatedIdentity
+		next   int
+		policy RotationPolicy
+		clock  clock.Clock
+	}
+
+	rotatedIdentity struct {
+		id        Identity
+		held      bool
+		uses      int
+		restUntil time.Time
+	}
+)
+
+// NewIdentityRotation returns a rotation of ids
+func NewIdentityRotation(ids []Identity, policy RotationPolicy) *IdentityRotation {
+	r := &IdentityRotation{policy: policy, clock: clock.Real}
+	for _, id := range ids {
+		r.ids = append(r.ids, rotatedIdentity{id: id})
+	}
+	return r
+}
+
+// SetClock sets the time source of the cooldowns, nil restores the real
+// clock. The rotation may be shared by many connectors, none of them
+// changes its clock: set it to the fake clock of the connectors in tests.
+func (r *IdentityRotation) SetClock(clk clock.Clock) {
+	if clk == nil {
+		clk = clock.Real
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clock = clk
+}
+
+// Acquire returns the next identity neither held nor resting, or
+// ErrNoIdentity
+func (r *IdentityRotation) Acquire() (Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	for i := 0; i < len(r.ids); i++ {
+		n := (r.next + i) % len(r.ids)
+		ri := &r.ids[n]
+		if ri.held || now.Before(ri.restUntil) {
+			continue
+		}
+		if r.policy.MaxUses > 0 && ri.uses >= r.policy.MaxUses {
+			continue
+		}
+		ri.held = true
+		ri.uses++
+		r.next = n + 1
+		return ri.id, nil
+	}
+	return Identity{}, ErrNoIdentity
+}
+
+// Release makes id available again once rested
+func (r *IdentityRotation) Release(id Identity, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.ids {
+		ri := &r.ids[i]
+		if ri.id.Name != id.Name || !ri.held {
+			continue
+		}
+		ri.held = false
+		rest := r.policy.Cooldown
+		if err != nil && r.policy.FailureCooldown > 0 {
+			rest = r.policy.FailureCooldown
+		}
+		ri.restUntil = r.clock.Now().Add(rest)
+		return
+	}
+}
+
+// Available returns the number of identities Acquire may hand out now
+func (r *IdentityRotation) Available() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	n := 0
+	for _, ri := range r.ids {
+		if !ri.held && !now.Before(ri.restUntil) && (r.policy.MaxUses <= 0 || ri.uses < r.policy.MaxUses) {
+			n++
+		}
+	}
+	return n
+}
+
+// SetIdentityProvider makes every connection acquire its identity from
+// p: its user data is merged into the handshake and its auth requests run
+// before the connect script. The identity is released when the
+// connection closes, a reconnect may get another one. Run fails with the
+// error of Acquire. p is not modified, an IdentityRotation keeps its own
+// clock. It must be set before Run.
+func (c *Connector) SetIdentityProvider(p IdentityProvider) {
+	c.identities = p
+}
+
+// Identity returns the identity of the current connection, false without
+// identity provider or connection
+func (c *Connector) Identity() (Identity, bool) {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	if c.identity == nil {
+		return Identity{}, false
+	}
+	return *c.identity, true
+}
+
+// acquireIdentity takes the identity of a new connection
+func (c *Connector) acquireIdentity() error {
+	if c.identities == nil {
+		return nil
+	}
+	id, err := c.identities.Acquire()
+	if err != nil {
+		return err
+	}
+	c.logDebug("identity acquired", Field{"identity", id.Name})
+
+	c.muConn.Lock()
+	c.identity = &id
+	c.muConn.Unlock()
+	return nil
+}
+
+// releaseIdentity returns the identity of the closed connection
+func (c *Connector) releaseIdentity(err error) {
+	c.muConn.Lock()
+	id := c.identity
+	c.identity = nil
+	c.muConn.Unlock()
+
+	if id != nil {
+		c.identities.Release(*id, err)
+	}
+}
+
+// identityUser returns the handshake user data of the identity
+func (c *Connector) identityUser() map[string]interface{} {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	if c.identity == nil {
+		return nil
+	}
+	return c.identity.User
+}
+
+// scriptSteps returns the identity auth requests and the connect script
+func (c *Connector) scriptSteps() []ConnectStep {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	if c.identity == nil || len(c.identity.Auth) == 0 {
+		return c.connectScript
+	}
+	steps := append([]ConnectStep(nil), c.identity.Auth...)
+	return append(steps, c.connectScript...)
+}
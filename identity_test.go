@@ -0,0 +1,83 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/clock"
+)
+
+func acquireName(t *testing.T, r *IdentityRotation) string {
+	t.Helper()
+
+	id, err := r.Acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id.Name
+}
+
+func TestIdentityRotationCooldown(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	r := NewIdentityRotation([]Identity{{Name: "a"}, {Name: "b"}}, RotationPolicy{
+		Cooldown:        time.Minute,
+		FailureCooldown: time.Hour,
+	})
+	r.SetClock(clk)
+
+	if a, b := acquireName(t, r), acquireName(t, r); a != "a" || b != "b" {
+		t.Fatalf("acquired %s, %s", a, b)
+	}
+	if _, err := r.Acquire(); err != ErrNoIdentity {
+		t.Fatalf("every identity held: %v", err)
+	}
+
+	r.Release(Identity{Name: "a"}, nil)
+	r.Release(Identity{Name: "b"}, errors.New("kicked"))
+	if n := r.Available(); n != 0 {
+		t.Fatalf("%d available while resting", n)
+	}
+	clk.Advance(time.Minute)
+	if name := acquireName(t, r); name != "a" {
+		t.Fatalf("acquired %s after the cooldown", name)
+	}
+	if _, err := r.Acquire(); err != ErrNoIdentity {
+		t.Fatalf("b acquired during its failure cooldown: %v", err)
+	}
+	clk.Advance(time.Hour)
+	if name := acquireName(t, r); name != "b" {
+		t.Fatalf("acquired %s after the failure cooldown", name)
+	}
+}
+
+func TestIdentityRotationMaxUses(t *testing.T) {
+	r := NewIdentityRotation([]Identity{{Name: "a"}}, RotationPolicy{MaxUses: 2})
+	for i := 0; i < 2; i++ {
+		acquireName(t, r)
+		r.Release(Identity{Name: "a"}, nil)
+	}
+	if _, err := r.Acquire(); err != ErrNoIdentity {
+		t.Fatalf("retired identity acquired: %v", err)
+	}
+}
+
+func TestIdentityRotationKeepsItsClock(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	r := NewIdentityRotation([]Identity{{Name: "a"}}, RotationPolicy{Cooldown: time.Minute})
+	r.SetClock(clk)
+
+	// connectors sharing the rotation don't change its clock
+	for i := 0; i < 2; i++ {
+		c := NewConnector()
+		c.SetClock(clock.NewFake(time.Unix(int64(i)*3600, 0)))
+		c.SetIdentityProvider(r)
+	}
+
+	acquireName(t, r)
+	r.Release(Identity{Name: "a"}, nil)
+	clk.Advance(time.Minute)
+	if n := r.Available(); n != 1 {
+		t.Fatal("rotation clock replaced by a connector")
+	}
+}
@@ -0,0 +1,39 @@
+package client
+
+import "github.com/revzim/go-pomelo-client/message"
+
+// InboundMessage describes a decoded inbound message
+type InboundMessage struct {
+	Type    byte   // message.Push or message.Response
+	Route   string // push route, or request route of a response
+	ID      uint   // message id, zero for a push
+	Size    int    // payload bytes, decompressed
+	Handled bool   // a handler or a pending request is waiting for it
+}
+
+// OnMessage sets the hook told about every decoded inbound message, before
+// middlewares and whether a handler exists or not, e.g. to measure the
+// unhandled pushes. The route of an orphan response is empty. It is called
+// from the read loop and must not block, nil removes it. It must be called
+// before Run.
+func (c *Connector) OnMessage(hook func(InboundMessage)) {
+	c.messageHook = hook
+}
+
+func (c *Connector) observeMessage(msg *message.Message) {
+	if c.messageHook == nil {
+		return
+	}
+
+	in := InboundMessage{Type: msg.Type, Route: msg.Route, ID: msg.ID, Size: len(msg.Data)}
+	switch msg.Type {
+	case message.Push:
+		_, in.Handled = c.eventHandler(msg.Route)
+	case message.Response:
+		if pr, ok := c.responses.get(msg.ID); ok {
+			in.Route = pr.route
+			in.Handled = true
+		}
+	}
+	c.messageHook(in)
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/clock"
+	"github.com/revzim/go-pomelo-client/message"
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+// dataPacket encodes msg in a data packet for InjectPacket
+func dataPacket(t *testing.T, msg *message.Message) *packet.Packet {
+	t.Helper()
+
+	data, err := message.Encode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &packet.Packet{Type: packet.Data, Length: len(data), Data: data}
+}
+
+// pendingMid returns the message id of the single pending request
+func pendingMid(t *testing.T, c *Connector) uint {
+	t.Helper()
+
+	all := c.responses.all()
+	if len(all) != 1 {
+		t.Fatalf("%d pending requests, want 1", len(all))
+	}
+	return all[0].mid
+}
+
+func TestInjectPush(t *testing.T) {
+	c := NewConnector()
+	c.SetLogger(nopLogger{})
+	var got []string
+	c.On("onChat", func(data []byte) {
+		got = append(got, string(data))
+	})
+
+	c.InjectPacket(dataPacket(t, &message.Message{Type: message.Push, Route: "onChat", Data: []byte(`{"msg":"hi"}`)}))
+	c.InjectPacket(dataPacket(t, &message.Message{Type: message.Push, Route: "onOther", Data: []byte(`{}`)}))
+	if len(got) != 1 || got[0] != `{"msg":"hi"}` {
+		t.Fatalf("pushes %q", got)
+	}
+}
+
+func TestInjectResponse(t *testing.T) {
+	c := NewConnector()
+	c.SetLogger(nopLogger{})
+	var resp string
+	if err := c.Request("area.get", []byte(`{}`), func(data []byte) {
+		resp = string(data)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	mid := pendingMid(t, c)
+	c.InjectPacket(dataPacket(t, &message.Message{Type: message.Response, ID: mid, Data: []byte(`{"code":200}`)}))
+	if resp != `{"code":200}` {
+		t.Fatalf("response %q", resp)
+	}
+	if n := len(c.responses.all()); n != 0 {
+		t.Fatalf("%d requests still pending", n)
+	}
+
+	// a second response of the same mid is an orphan
+	c.InjectPacket(dataPacket(t, &message.Message{Type: message.Response, ID: mid, Data: []byte(`{"code":500}`)}))
+	if resp != `{"code":200}` {
+		t.Fatalf("orphan delivered: %q", resp)
+	}
+}
+
+func TestInjectResponseAfterTimeout(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	c := NewConnector()
+	c.SetLogger(nopLogger{})
+	c.SetClock(clk)
+
+	failed := make(chan error, 1)
+	if err := c.Request("area.get", nil, func([]byte) {
+		t.Error("response delivered after the timeout")
+	}, WithTimeout(time.Second), WithErrorHandler(func(err error) {
+		failed <- err
+	})); err != nil {
+		t.Fatal(err)
+	}
+	mid := pendingMid(t, c)
+
+	clk.Advance(999 * time.Millisecond)
+	select {
+	case err := <-failed:
+		t.Fatalf("failed before its timeout: %v", err)
+	default:
+	}
+	clk.Advance(time.Millisecond)
+	select {
+	case err := <-failed:
+		if err != ErrRequestTimeout {
+			t.Fatalf("error %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("request did not time out")
+	}
+
+	c.InjectPacket(dataPacket(t, &message.Message{Type: message.Response, ID: mid, Data: []byte(`{}`)}))
+}
@@ -0,0 +1,187 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/revzim/go-pomelo-client"
+	"github.com/revzim/go-pomelo-client/codec"
+)
+
+type server struct {
+	name       string
+	addr       string
+	echoRoute  string
+	kickRoute  string
+	protoRoute string
+}
+
+func servers(t *testing.T) []server {
+	var list []server
+	for _, name := range []string{"pomelo", "nano", "pitaya"} {
+		env := strings.ToUpper(name)
+		addr := os.Getenv(env + "_ADDR")
+		if addr == "" {
+			continue
+		}
+		list = append(list, server{
+			name:       name,
+			addr:       addr,
+			echoRoute:  os.Getenv(env + "_ECHO_ROUTE"),
+			kickRoute:  os.Getenv(env + "_KICK_ROUTE"),
+			protoRoute: os.Getenv(env + "_PROTO_ROUTE"),
+		})
+	}
+	if len(list) == 0 {
+		t.Skip("no reference server configured, set POMELO_ADDR, NANO_ADDR or PITAYA_ADDR")
+	}
+	return list
+}
+
+// connect runs a connector to s and waits for the handshake
+func connect(t *testing.T, s server) (*client.Connector, <-chan error) {
+	t.Helper()
+
+	c := client.NewConnector()
+	if err := c.InitReqHandshake("0.6.0", "go-conformance", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InitHandshakeACK(1); err != nil {
+		t.Fatal(err)
+	}
+	c.SetRequestTimeout(10 * time.Second)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Run(s.addr)
+	}()
+
+	select {
+	case <-c.Ready():
+	case err := <-errCh:
+		t.Fatalf("%s: run: %v", s.name, err)
+	case <-time.After(5 * time.Second):
+		c.Close()
+		t.Fatalf("%s: handshake timeout", s.name)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, errCh
+}
+
+func echo(t *testing.T, c *client.Connector, s server, body []byte) []byte {
+	t.Helper()
+
+	if s.echoRoute == "" {
+		t.Skipf("%s: no echo route configured", s.name)
+	}
+	var resp []byte
+	if err := c.Call(s.echoRoute, json.RawMessage(body), (*json.RawMessage)(&resp)); err != nil {
+		t.Fatalf("%s: %s: %v", s.name, s.echoRoute, err)
+	}
+	return resp
+}
+
+func TestHandshake(t *testing.T) {
+	for _, s := range servers(t) {
+		t.Run(s.name, func(t *testing.T) {
+			c, _ := connect(t, s)
+			if c.IsClosed() {
+				t.Fatal("connector closed after handshake")
+			}
+			t.Logf("server version %q", c.ServerVersion())
+		})
+	}
+}
+
+func TestDictionary(t *testing.T) {
+	for _, s := range servers(t) {
+		t.Run(s.name, func(t *testing.T) {
+			c, _ := connect(t, s)
+			dict := c.RouteDictionary()
+			if len(dict) == 0 {
+				t.Skip("server sent no route dictionary")
+			}
+			if _, ok := dict[s.echoRoute]; !ok {
+				t.Skipf("echo route %q not in the dictionary", s.echoRoute)
+			}
+			// the request route is now encoded as a dictionary code
+			echo(t, c, s, []byte(`{"dict":true}`))
+		})
+	}
+}
+
+func TestProtobuf(t *testing.T) {
+	for _, s := range servers(t) {
+		t.Run(s.name, func(t *testing.T) {
+			if s.protoRoute == "" {
+				t.Skip("no protobuf route configured")
+			}
+			c, _ := connect(t, s)
+			c.SetRouteRaw(s.protoRoute)
+			// an empty message is a valid encoding of every proto schema
+			var resp []byte
+			if err := c.Call(s.protoRoute, []byte{}, &resp); err != nil {
+				t.Fatalf("%s: %v", s.protoRoute, err)
+			}
+		})
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	for _, s := range servers(t) {
+		t.Run(s.name, func(t *testing.T) {
+			c, _ := connect(t, s)
+			time.Sleep(5 * time.Second)
+			if c.IsClosed() {
+				t.Fatal("connection closed while idle, heartbeats not kept")
+			}
+			if n := c.MissedHeartbeats(); n > 1 {
+				t.Fatalf("missed %d server heartbeats", n)
+			}
+		})
+	}
+}
+
+func TestLargeMessage(t *testing.T) {
+	for _, s := range servers(t) {
+		t.Run(s.name, func(t *testing.T) {
+			c, _ := connect(t, s)
+			// largest payload the decoder accepts, spanning many reads
+			payload := bytes.Repeat([]byte("x"), codec.MaxPacketSize-1024)
+			body, _ := json.Marshal(map[string]string{"payload": string(payload)})
+			resp := echo(t, c, s, body)
+			if !bytes.Contains(resp, payload) {
+				t.Fatalf("echo of %d bytes returned %d bytes", len(body), len(resp))
+			}
+		})
+	}
+}
+
+func TestKick(t *testing.T) {
+	for _, s := range servers(t) {
+		t.Run(s.name, func(t *testing.T) {
+			if s.kickRoute == "" {
+				t.Skip("no kick route configured")
+			}
+			c, errCh := connect(t, s)
+			if err := c.Request(s.kickRoute, []byte(`{}`), func([]byte) {}); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-errCh:
+			case <-time.After(5 * time.Second):
+				t.Fatal("not kicked")
+			}
+			if n := c.Stats().Disconnects[client.DisconnectKick]; n != 1 {
+				t.Fatalf("kick disconnects = %d, want 1", n)
+			}
+		})
+	}
+}
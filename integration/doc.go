@@ -0,0 +1,22 @@
+// Package integration holds the protocol conformance suite run against
+// reference servers, it is built with the integration tag only:
+//
+//	go test -tags integration ./integration/
+//
+// Every server is enabled by its address variable, servers without one
+// are skipped:
+//
+//	POMELO_ADDR   pomelo (node) server, e.g. ws://127.0.0.1:3010
+//	NANO_ADDR     nano server, e.g. tcp://127.0.0.1:3250
+//	PITAYA_ADDR   pitaya server, e.g. tcp://127.0.0.1:3250
+//
+// The routes exercised are read from <NAME>_ECHO_ROUTE (a request route
+// answering its body, required) and <NAME>_KICK_ROUTE (a request route
+// kicking the session, optional). <NAME>_PROTO_ROUTE enables the
+// protobuf test.
+//
+// run.sh starts the reference servers of testdata/docker-compose.yml,
+// sets the variables and runs the suite, it needs docker compose v2:
+//
+//	./integration/run.sh -v
+package integration
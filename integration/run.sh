@@ -0,0 +1,21 @@
+#!/bin/sh
+# Runs the conformance suite against the reference servers of
+# testdata/docker-compose.yml, the arguments are passed to go test.
+set -eu
+
+cd "$(dirname "$0")"
+compose="docker compose -f testdata/docker-compose.yml -p pomelo-conformance"
+$compose up -d --build --wait
+trap '$compose down' EXIT
+
+export POMELO_ADDR=ws://127.0.0.1:3010
+export POMELO_ECHO_ROUTE=connector.entryHandler.echo
+export POMELO_KICK_ROUTE=connector.entryHandler.kick
+export POMELO_PROTO_ROUTE=connector.entryHandler.proto
+export NANO_ADDR=tcp://127.0.0.1:3250
+export NANO_ECHO_ROUTE=Echo.Echo
+export PITAYA_ADDR=tcp://127.0.0.1:3251
+export PITAYA_ECHO_ROUTE=echo.echo
+export PITAYA_KICK_ROUTE=echo.kick
+
+go test -tags integration -count=1 "$@" .
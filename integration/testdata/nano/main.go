@@ -0,0 +1,37 @@
+// Command nano-echo is the nano reference server of the conformance
+// suite: Echo.Echo answers its body, routes are sent in the handshake
+// dictionary.
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/lonng/nano"
+	"github.com/lonng/nano/component"
+	"github.com/lonng/nano/serialize/json"
+	"github.com/lonng/nano/session"
+)
+
+// Echo --
+type Echo struct {
+	component.Base
+}
+
+// Echo answers the request body
+func (e *Echo) Echo(s *session.Session, data []byte) error {
+	return s.Response(data)
+}
+
+func main() {
+	components := &component.Components{}
+	components.Register(&Echo{})
+
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	nano.Listen(":3250",
+		nano.WithComponents(components),
+		nano.WithSerializer(json.NewSerializer()),
+		nano.WithHeartbeatInterval(3*time.Second),
+		nano.WithDictionary(map[string]uint16{"Echo.Echo": 1}),
+	)
+}
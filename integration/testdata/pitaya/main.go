@@ -0,0 +1,51 @@
+// Command pitaya-echo is the pitaya reference server of the conformance
+// suite, standalone: echo.echo answers its body and echo.kick kicks the
+// session once answered.
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/topfreegames/pitaya/v2"
+	"github.com/topfreegames/pitaya/v2/acceptor"
+	"github.com/topfreegames/pitaya/v2/component"
+	"github.com/topfreegames/pitaya/v2/config"
+)
+
+var app pitaya.Pitaya
+
+// Echo --
+type Echo struct {
+	component.Base
+}
+
+// Echo answers the request body
+func (e *Echo) Echo(ctx context.Context, data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// Kick answers then kicks the session
+func (e *Echo) Kick(ctx context.Context, data []byte) ([]byte, error) {
+	s := app.GetSessionFromCtx(ctx)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		s.Kick(context.Background())
+	}()
+	return []byte(`{"code":200}`), nil
+}
+
+func main() {
+	conf := config.NewDefaultBuilderConfig()
+	builder := pitaya.NewDefaultBuilder(true, "conformance", pitaya.Standalone, map[string]string{}, *conf)
+	builder.AddAcceptor(acceptor.NewTCPAcceptor(":3251"))
+	app = builder.Build()
+	defer app.Shutdown()
+
+	app.Register(&Echo{}, component.WithName("echo"), component.WithNameFunc(strings.ToLower))
+	if err := app.SetDictionary(map[string]uint16{"echo.echo": 1}); err != nil {
+		panic(err)
+	}
+	app.Start()
+}
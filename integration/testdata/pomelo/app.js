@@ -0,0 +1,17 @@
+// pomelo reference server of the conformance suite: a single hybrid
+// (tcp and websocket) connector with route dictionary and protobuf
+var pomelo = require('pomelo');
+
+var app = pomelo.createApp();
+app.set('name', 'conformance');
+
+app.configure('development', 'connector', function () {
+  app.set('connectorConfig', {
+    connector: pomelo.connectors.hybridconnector,
+    heartbeat: 3,
+    useDict: true,
+    useProtobuf: true
+  });
+});
+
+app.start();
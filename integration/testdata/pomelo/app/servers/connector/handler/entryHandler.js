@@ -0,0 +1,26 @@
+module.exports = function (app) {
+  return new Handler(app);
+};
+
+var Handler = function (app) {
+  this.app = app;
+};
+
+// echo answers the request body
+Handler.prototype.echo = function (msg, session, next) {
+  next(null, msg);
+};
+
+// proto answers the request body, the route is protobuf encoded
+Handler.prototype.proto = function (msg, session, next) {
+  next(null, msg);
+};
+
+// kick answers then kicks the session
+Handler.prototype.kick = function (msg, session, next) {
+  var sessionService = this.app.get('sessionService');
+  next(null, {code: 200});
+  setTimeout(function () {
+    sessionService.kickBySessionId(session.id, 'conformance');
+  }, 100);
+};
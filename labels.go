@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// Profiler labels set on the connector goroutines
+const (
+	LabelConnector = "pomelo.connector" // connector name, see SetName
+	LabelAddr      = "pomelo.addr"      // address passed to Run
+)
+
+// SetName names the connector in the profiler labels of its goroutines,
+// so CPU and goroutine profiles of many connectors can be told apart. It
+// must be called before Run.
+func (c *Connector) SetName(name string) {
+	c.name = name
+}
+
+// Name --
+func (c *Connector) Name() string {
+	return c.name
+}
+
+// runLabeled runs fn with the profiler labels of a connection to addr,
+// the goroutines it starts inherit them
+func (c *Connector) runLabeled(addr string, fn func() error) error {
+	labels := []string{LabelAddr, addr}
+	if c.name != "" {
+		labels = append(labels, LabelConnector, c.name)
+	}
+
+	var err error
+	pprof.Do(context.Background(), pprof.Labels(labels...), func(ctx context.Context) {
+		c.muConn.Lock()
+		c.labels = ctx
+		c.muConn.Unlock()
+
+		err = fn()
+	})
+	return err
+}
+
+// labelGoroutine sets the connection profiler labels on a goroutine not
+// started by the read loop
+func (c *Connector) labelGoroutine() {
+	c.muConn.RLock()
+	ctx := c.labels
+	c.muConn.RUnlock()
+
+	if ctx != nil {
+		pprof.SetGoroutineLabels(ctx)
+	}
+}
@@ -0,0 +1,253 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// errLazyPath is returned by LazyPush.Unmarshal for a missing path
+var errLazyPath = errors.New("json path not found")
+
+// LazyPush is a JSON push payload read on demand, a lookup only scans the
+// bytes up to the field and allocates nothing but the returned value. It
+// refers to the read buffer and is only valid during the handler, Raw
+// must be copied to be kept.
+type LazyPush struct {
+	data []byte
+}
+
+// NewLazyPush wraps a JSON payload, e.g. a response body
+func NewLazyPush(data []byte) LazyPush {
+	return LazyPush{data: data}
+}
+
+// OnLazy adds a callback for the event receiving the payload as a
+// LazyPush, for handlers reading a few fields of large or frequent
+// pushes without unmarshaling them.
+func (c *Connector) OnLazy(route string, cb func(LazyPush)) {
+	c.On(route, func(data []byte) {
+		cb(LazyPush{data: data})
+	})
+}
+
+// Raw returns the payload
+func (p LazyPush) Raw() []byte {
+	return p.data
+}
+
+// Get returns the raw JSON value at path, nested fields are separated by
+// dots and array elements are addressed by index, e.g. "players.0.pos".
+// An empty path is the whole payload.
+func (p LazyPush) Get(path string) (json.RawMessage, bool) {
+	v := skipSpace(p.data)
+	for path != "" {
+		var key string
+		if i := strings.IndexByte(path, '.'); i >= 0 {
+			key, path = path[:i], path[i+1:]
+		} else {
+			key, path = path, ""
+		}
+
+		var ok bool
+		switch {
+		case len(v) > 0 && v[0] == '{':
+			v, ok = objectField(v, key)
+		case len(v) > 0 && v[0] == '[':
+			v, ok = arrayElem(v, key)
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+
+	end, ok := valueEnd(v)
+	if !ok {
+		return nil, false
+	}
+	return json.RawMessage(v[:end]), true
+}
+
+// String returns the string at path
+func (p LazyPush) String(path string) (string, bool) {
+	raw, ok := p.Get(path)
+	if !ok || raw[0] != '"' {
+		return "", false
+	}
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw[1 : len(raw)-1]), true
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// Int returns the integer at path
+func (p LazyPush) Int(path string) (int64, bool) {
+	raw, ok := p.Get(path)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(raw), 10, 64)
+	return n, err == nil
+}
+
+// Float returns the number at path
+func (p LazyPush) Float(path string) (float64, bool) {
+	raw, ok := p.Get(path)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(string(raw), 64)
+	return f, err == nil
+}
+
+// Bool returns the boolean at path
+func (p LazyPush) Bool(path string) (bool, bool) {
+	raw, ok := p.Get(path)
+	if !ok {
+		return false, false
+	}
+	switch string(raw) {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return false, false
+}
+
+// Unmarshal unmarshals the value at path into v
+func (p LazyPush) Unmarshal(path string, v interface{}) error {
+	raw, ok := p.Get(path)
+	if !ok {
+		return errLazyPath
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// objectField returns the bytes starting at the value of key in the
+// object starting data
+func objectField(data []byte, key string) ([]byte, bool) {
+	data = skipSpace(data[1:])
+	for len(data) > 0 && data[0] != '}' {
+		end, ok := valueEnd(data)
+		if !ok || data[0] != '"' {
+			return nil, false
+		}
+		name := data[1 : end-1]
+		matched := string(name) == key
+		if !matched && bytes.IndexByte(name, '\\') >= 0 {
+			var s string
+			matched = json.Unmarshal(data[:end], &s) == nil && s == key
+		}
+
+		data = skipSpace(data[end:])
+		if len(data) == 0 || data[0] != ':' {
+			return nil, false
+		}
+		data = skipSpace(data[1:])
+		if matched {
+			return data, true
+		}
+		if data, ok = skipValue(data); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// arrayElem returns the bytes starting at element index of the array
+// starting data
+func arrayElem(data []byte, index string) ([]byte, bool) {
+	n, err := strconv.Atoi(index)
+	if err != nil || n < 0 {
+		return nil, false
+	}
+	data = skipSpace(data[1:])
+	for i := 0; len(data) > 0 && data[0] != ']'; i++ {
+		if i == n {
+			return data, true
+		}
+		var ok bool
+		if data, ok = skipValue(data); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// skipValue skips the value starting data and the comma following it
+func skipValue(data []byte) ([]byte, bool) {
+	end, ok := valueEnd(data)
+	if !ok {
+		return nil, false
+	}
+	data = skipSpace(data[end:])
+	if len(data) > 0 && data[0] == ',' {
+		data = skipSpace(data[1:])
+	}
+	return data, true
+}
+
+// valueEnd returns the length of the JSON value starting data
+func valueEnd(data []byte) (int, bool) {
+	if len(data) == 0 {
+		return 0, false
+	}
+	switch data[0] {
+	case '"':
+		for i := 1; i < len(data); i++ {
+			switch data[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, true
+			}
+		}
+		return 0, false
+	case '{', '[':
+		depth := 0
+		for i := 0; i < len(data); i++ {
+			switch data[i] {
+			case '"':
+				end, ok := valueEnd(data[i:])
+				if !ok {
+					return 0, false
+				}
+				i += end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1, true
+				}
+			}
+		}
+		return 0, false
+	}
+	for i, b := range data {
+		switch b {
+		case ',', '}', ']', ' ', '\t', '\r', '\n':
+			return i, i > 0
+		}
+	}
+	return len(data), true
+}
+
+func skipSpace(data []byte) []byte {
+	for len(data) > 0 {
+		switch data[0] {
+		case ' ', '\t', '\r', '\n':
+			data = data[1:]
+		default:
+			return data
+		}
+	}
+	return data
+}
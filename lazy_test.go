@@ -0,0 +1,134 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+const lazyPayload = `{
+	"room": "lobby",
+	"count": 3,
+	"ratio": 0.5,
+	"open": true,
+	"esc\"aped": "q\"uote",
+	"players": [{"name": "a", "pos": [1, 2]}, {"name": "b", "pos": [3, 4]}],
+	"meta": {"owner": {"id": 7}, "empty": {}}
+}`
+
+func TestLazyPushGet(t *testing.T) {
+	p := NewLazyPush([]byte(lazyPayload))
+	for _, tc := range []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"room", `"lobby"`, true},
+		{"players.1.name", `"b"`, true},
+		{"players.0.pos.1", `2`, true},
+		{"meta.owner", `{"id": 7}`, true},
+		{"meta.owner.id", `7`, true},
+		{"meta.empty", `{}`, true},
+		{`esc"aped`, `"q\"uote"`, true},
+		// missing fields and elements
+		{"missing", "", false},
+		{"meta.owner.name", "", false},
+		{"players.2", "", false},
+		{"players.x", "", false},
+		{"players.-1", "", false},
+		// descending into a scalar
+		{"room.name", "", false},
+		{"count.0", "", false},
+	} {
+		raw, ok := p.Get(tc.path)
+		if ok != tc.ok || string(raw) != tc.want {
+			t.Errorf("Get(%q) = %s, %v, want %s, %v", tc.path, raw, ok, tc.want, tc.ok)
+		}
+	}
+	if raw, ok := p.Get(""); !ok || len(raw) != len(lazyPayload) {
+		t.Errorf("Get of the whole payload: %v", ok)
+	}
+}
+
+func TestLazyPushTypes(t *testing.T) {
+	p := NewLazyPush([]byte(lazyPayload))
+
+	if s, ok := p.String("players.0.name"); !ok || s != "a" {
+		t.Errorf("String %q, %v", s, ok)
+	}
+	if s, ok := p.String(`esc"aped`); !ok || s != `q"uote` {
+		t.Errorf("escaped String %q, %v", s, ok)
+	}
+	if n, ok := p.Int("count"); !ok || n != 3 {
+		t.Errorf("Int %d, %v", n, ok)
+	}
+	if f, ok := p.Float("ratio"); !ok || f != 0.5 {
+		t.Errorf("Float %v, %v", f, ok)
+	}
+	if b, ok := p.Bool("open"); !ok || !b {
+		t.Errorf("Bool %v, %v", b, ok)
+	}
+	var owner struct{ ID int }
+	if err := p.Unmarshal("meta.owner", &owner); err != nil || owner.ID != 7 {
+		t.Errorf("Unmarshal %+v, %v", owner, err)
+	}
+
+	// wrong types
+	if _, ok := p.String("count"); ok {
+		t.Error("String of a number")
+	}
+	if _, ok := p.Int("ratio"); ok {
+		t.Error("Int of a float")
+	}
+	if _, ok := p.Int("room"); ok {
+		t.Error("Int of a string")
+	}
+	if _, ok := p.Float("open"); ok {
+		t.Error("Float of a boolean")
+	}
+	if _, ok := p.Bool("count"); ok {
+		t.Error("Bool of a number")
+	}
+	var n int
+	if err := p.Unmarshal("room", &n); err == nil {
+		t.Error("Unmarshal of a string into an int")
+	}
+	if err := p.Unmarshal("missing", &n); err != errLazyPath {
+		t.Errorf("Unmarshal of a missing path: %v", err)
+	}
+}
+
+func TestLazyPushMalformed(t *testing.T) {
+	for _, payload := range []string{
+		``,
+		`{`,
+		`{"a" 1}`,
+		`{"a":`,
+		`{a:1}`,
+		`{"b":"unterminated, "a":1}`,
+		`{"b":[1,2, "a":1}`,
+		`not json`,
+	} {
+		if raw, ok := NewLazyPush([]byte(payload)).Get("a"); ok {
+			t.Errorf("%s: Get found %s", payload, raw)
+		}
+	}
+	// the scan stops at the field, the rest is not validated
+	if n, ok := NewLazyPush([]byte(`{"a":1,`)).Int("a"); !ok || n != 1 {
+		t.Errorf("field before a truncation: %d, %v", n, ok)
+	}
+}
+
+func TestOnLazy(t *testing.T) {
+	c := NewConnector()
+	c.SetLogger(nopLogger{})
+	var room string
+	c.OnLazy("onJoin", func(p LazyPush) {
+		room, _ = p.String("room")
+	})
+
+	c.InjectPacket(dataPacket(t, &message.Message{Type: message.Push, Route: "onJoin", Data: []byte(lazyPayload)}))
+	if room != "lobby" {
+		t.Fatalf("room %q", room)
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// LifecycleKind is the kind of a LifecycleEvent
+type LifecycleKind int
+
+// Lifecycle events, in the order of a connection
+const (
+	// EventReconnectAttempt is a Run call during an outage, Attempt is
+	// its number
+	EventReconnectAttempt LifecycleKind = iota
+	// EventConnected is the transport connected, before the handshake
+	EventConnected
+	// EventHandshakeComplete is the connector ready, when Connected fires
+	EventHandshakeComplete
+	// EventHeartbeatSent is a heartbeat sent to the server
+	EventHeartbeatSent
+	// EventDecodeError is an inbound packet or message which could not be
+	// decoded, Err tells why
+	EventDecodeError
+	// EventDisconnected is the connection closed, Reason tells why
+	EventDisconnected
+)
+
+var lifecycleNames = [...]string{
+	EventReconnectAttempt:  "reconnect-attempt",
+	EventConnected:         "connected",
+	EventHandshakeComplete: "handshake-complete",
+	EventHeartbeatSent:     "heartbeat-sent",
+	EventDecodeError:       "decode-error",
+	EventDisconnected:      "disconnected",
+}
+
+func (k LifecycleKind) String() string {
+	if k >= 0 && int(k) < len(lifecycleNames) {
+		return lifecycleNames[k]
+	}
+	return "unknown"
+}
+
+// LifecycleEvent is published on the connector event bus
+type LifecycleEvent struct {
+	Kind    LifecycleKind
+	Time    time.Time
+	Addr    string           // address of the connection
+	Attempt int              // EventReconnectAttempt
+	Reason  DisconnectReason // EventDisconnected
+	Err     error            // EventDecodeError, EventDisconnected
+}
+
+type (
+	// lifecycleBus dispatches the lifecycle events to the subscribers in
+	// registration order. subs is copied on write, publish iterates a
+	// snapshot without holding mu.
+	lifecycleBus struct {
+		mu   sync.RWMutex
+		subs []*lifecycleSub
+	}
+
+	lifecycleSub struct {
+		fn    func(LifecycleEvent)
+		kinds map[LifecycleKind]bool // nil is every kind
+	}
+)
+
+// Subscribe calls fn for every lifecycle event of kinds, every kind when
+// none is given, until unsubscribe is called. The subscribers of an event
+// are called in registration order, the Connected callback and the
+// plugin OnConnect and OnClose hooks among them. fn runs synchronously on
+// the goroutine raising the event, the read loop for most of them, it
+// must not block. Subscribing is safe at any time, e.g. from plugins
+// attached to a running connector. Subscribers belong to c, CloneConfig
+// does not copy them.
+func (c *Connector) Subscribe(fn func(LifecycleEvent), kinds ...LifecycleKind) (unsubscribe func()) {
+	sub := &lifecycleSub{fn: fn}
+	if len(kinds) > 0 {
+		sub.kinds = make(map[LifecycleKind]bool, len(kinds))
+		for _, k := range kinds {
+			sub.kinds[k] = true
+		}
+	}
+
+	b := &c.lifecycle
+	b.mu.Lock()
+	subs := make([]*lifecycleSub, len(b.subs), len(b.subs)+1)
+	copy(subs, b.subs)
+	b.subs = append(subs, sub)
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		for i, s := range b.subs {
+			if s == sub {
+				subs := make([]*lifecycleSub, 0, len(b.subs)-1)
+				subs = append(subs, b.subs[:i]...)
+				b.subs = append(subs, b.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// publish sends ev, stamped with the time and address, to the subscribers
+func (c *Connector) publish(ev LifecycleEvent) {
+	b := &c.lifecycle
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	ev.Time = c.clock.Now()
+	c.muConn.RLock()
+	ev.Addr = c.connAddr
+	c.muConn.RUnlock()
+	for _, sub := range subs {
+		if sub.kinds == nil || sub.kinds[ev.Kind] {
+			sub.fn(ev)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// orderPlugin records its connection hooks
+type orderPlugin struct {
+	PluginBase
+	record func(string)
+}
+
+func (p orderPlugin) OnConnect(*Connector) { p.record("plugin") }
+
+func TestLifecycleRegistrationOrder(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	record := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, s)
+	}
+
+	s := newTestServer(t, nil)
+	c := newTestConnector(t)
+	c.Subscribe(func(LifecycleEvent) { record("first") }, EventHandshakeComplete)
+	unsubscribe := c.Subscribe(func(LifecycleEvent) { record("removed") }, EventHandshakeComplete)
+	c.Connected(func() { record("connected") })
+	if err := c.Use(orderPlugin{record: record}); err != nil {
+		t.Fatal(err)
+	}
+	c.Subscribe(func(LifecycleEvent) { record("last") }, EventHandshakeComplete)
+	// a second Connected replaces the first one
+	c.Connected(func() { record("connected again") })
+	unsubscribe()
+
+	runConnector(t, c, s.addr())
+	s.next()
+
+	want := []string{"first", "plugin", "last", "connected again"}
+	// Ready is closed before the event is published
+	waitFor(t, "subscribers", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) >= len(want)
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("order %q, want %q", got, want)
+	}
+}
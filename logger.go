@@ -0,0 +1,105 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Field is a structured key/value pair attached to a log entry
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger is the logging interface used by the Connector, every entry
+// carries structured fields such as route, mid, bytes and state.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+}
+
+// LogLevel is the lowest level of the entries written by StdLogger
+type LogLevel int
+
+// Log levels, in increasing severity
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+// StdLogger returns a logger writing the entries of level and above
+// through the standard library log package. The connectors log to
+// StdLogger(LogInfo) by default, SetLogger(StdLogger(LogDebug)) shows
+// their debug entries.
+func StdLogger(level LogLevel) Logger {
+	return stdLogger{level: level}
+}
+
+// stdLogger writes entries through the standard library log package
+type stdLogger struct {
+	level LogLevel
+}
+
+func (l stdLogger) Debug(msg string, fields ...Field) { l.print(LogDebug, "DEBUG", msg, fields) }
+func (l stdLogger) Info(msg string, fields ...Field)  { l.print(LogInfo, "INFO", msg, fields) }
+func (l stdLogger) Warn(msg string, fields ...Field)  { l.print(LogWarn, "WARN", msg, fields) }
+func (l stdLogger) Error(msg string, fields ...Field) { l.print(LogError, "ERROR", msg, fields) }
+
+func (l stdLogger) print(level LogLevel, name, msg string, fields []Field) {
+	if level < l.level {
+		return
+	}
+	stdPrint(name, msg, fields)
+}
+
+func stdPrint(level, msg string, fields []Field) {
+	var sb strings.Builder
+	sb.WriteString(level)
+	sb.WriteByte(' ')
+	sb.WriteString(msg)
+	for _, f := range fields {
+		fmt.Fprintf(&sb, " %s=%v", f.Key, f.Value)
+	}
+	log.Println(sb.String())
+}
+
+// SetLogger sets the logger used by the connector, nil restores the
+// default one, StdLogger(LogInfo).
+func (c *Connector) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = StdLogger(LogInfo)
+	}
+	c.logger = logger
+}
+
+func (c *Connector) state() string {
+	if c.IsClosed() {
+		return "closed"
+	}
+	return "connected"
+}
+
+func (c *Connector) withState(fields []Field) []Field {
+	return append(fields, Field{"state", c.state()})
+}
+
+func (c *Connector) logDebug(msg string, fields ...Field) {
+	c.logger.Debug(msg, c.withState(fields)...)
+}
+
+func (c *Connector) logInfo(msg string, fields ...Field) {
+	c.logger.Info(msg, c.withState(fields)...)
+}
+
+func (c *Connector) logWarn(msg string, fields ...Field) {
+	c.logger.Warn(msg, c.withState(fields)...)
+}
+
+func (c *Connector) logError(msg string, fields ...Field) {
+	c.logger.Error(msg, c.withState(fields)...)
+}
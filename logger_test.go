@@ -0,0 +1,37 @@
+package client
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStdLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	c := NewConnector()
+	c.logDebug("hidden")
+	c.logInfo("shown", Field{"route", "area.get"})
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "INFO shown route=area.get") {
+		t.Fatalf("default logger wrote %q", out)
+	}
+
+	buf.Reset()
+	c.SetLogger(StdLogger(LogDebug))
+	c.logDebug("debug")
+	if !strings.Contains(buf.String(), "DEBUG debug") {
+		t.Fatalf("debug logger wrote %q", buf.String())
+	}
+
+	buf.Reset()
+	c.SetLogger(StdLogger(LogError))
+	c.logWarn("warn")
+	c.logError("error")
+	if out := buf.String(); strings.Contains(out, "warn") || !strings.Contains(out, "ERROR error") {
+		t.Fatalf("error logger wrote %q", out)
+	}
+}
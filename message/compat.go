@@ -0,0 +1,43 @@
+package message
+
+// Compat selects the message header variant spoken by the server, pomelo
+// forks disagree on a few bits of the header.
+type Compat struct {
+	// RouteCompress encodes dictionary routes as 2 bytes codes, flagged by
+	// the 0x01 bit. When off the bit is neither written nor read and routes
+	// are always sent as strings.
+	RouteCompress bool
+
+	// GzipBit carries DataCompressed in the 0x10 flag bit. When off the bit
+	// is ignored on decode and a compressed payload can't be encoded.
+	GzipBit bool
+
+	// WideRoute encodes the route length on 2 bytes, big endian, instead
+	// of 1 byte.
+	WideRoute bool
+
+	// Dict is the route dictionary of RouteCompress, nil sends every route
+	// as a string. The connector uses the dictionary of its connection.
+	Dict *Dictionary
+}
+
+// Presets of the header variants found in the wild
+var (
+	// CompatPomelo is the NetEase pomelo header, the default
+	CompatPomelo = Compat{RouteCompress: true, GzipBit: true}
+
+	// CompatNano is the header of the forks without payload compression
+	CompatNano = Compat{RouteCompress: true}
+
+	// CompatWide is the header of the forks with 2 bytes route lengths and
+	// no route dictionary
+	CompatWide = Compat{WideRoute: true}
+)
+
+// maxRouteLength returns the longest route the header can carry
+func (c Compat) maxRouteLength() int {
+	if c.WideRoute {
+		return 0xFFFF
+	}
+	return msgRouteLengthMask
+}
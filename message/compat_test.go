@@ -0,0 +1,83 @@
+package message
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompatHeader(t *testing.T) {
+	dict := NewDictionary(map[string]uint16{"dict.route": 0x0102})
+	withDict := func(c Compat) Compat {
+		c.Dict = dict
+		return c
+	}
+	for _, tc := range []struct {
+		name   string
+		compat Compat
+		msg    Message
+		want   []byte
+	}{
+		{"pomelo", CompatPomelo, Message{Type: Notify, Route: "ab", Data: []byte("x")},
+			[]byte{Notify << 1, 2, 'a', 'b', 'x'}},
+		{"pomelo gzip", CompatPomelo, Message{Type: Notify, Route: "ab", DataCompressed: true},
+			[]byte{Notify<<1 | msgDataCompressMask, 2, 'a', 'b'}},
+		{"pomelo dict", withDict(CompatPomelo), Message{Type: Notify, Route: "dict.route"},
+			[]byte{Notify<<1 | msgRouteCompressMask, 0x01, 0x02}},
+		{"nano dict", withDict(CompatNano), Message{Type: Push, Route: "dict.route"},
+			[]byte{Push<<1 | msgRouteCompressMask, 0x01, 0x02}},
+		{"wide", CompatWide, Message{Type: Request, ID: 3, Route: "ab"},
+			[]byte{Request << 1, 3, 0, 2, 'a', 'b'}},
+		{"wide ignores dict", withDict(CompatWide), Message{Type: Notify, Route: "dict.route"},
+			append([]byte{Notify << 1, 0, 10}, "dict.route"...)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := EncodeCompat(&tc.msg, tc.compat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, tc.want) {
+				t.Fatalf("encoded % x, want % x", data, tc.want)
+			}
+			m, err := DecodeCompat(data, tc.compat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Type != tc.msg.Type || m.ID != tc.msg.ID || m.Route != tc.msg.Route ||
+				m.DataCompressed != tc.msg.DataCompressed || !bytes.Equal(m.Data, tc.msg.Data) {
+				t.Fatalf("decoded %s, want %s", m, &tc.msg)
+			}
+		})
+	}
+}
+
+func TestCompatGzipBit(t *testing.T) {
+	if _, err := EncodeCompat(&Message{Type: Notify, Route: "r", DataCompressed: true}, CompatNano); err != ErrDataCompressUnsupported {
+		t.Fatalf("compressed payload without the gzip bit: %v", err)
+	}
+	// the bit is ignored on decode
+	m, err := DecodeCompat([]byte{Push<<1 | msgDataCompressMask, 1, 'r'}, CompatNano)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.DataCompressed {
+		t.Fatal("gzip bit read without GzipBit")
+	}
+}
+
+func TestCompatRouteLength(t *testing.T) {
+	long := &Message{Type: Notify, Route: strings.Repeat("r", 256)}
+	if _, err := EncodeCompat(long, CompatPomelo); err != ErrRouteTooLong {
+		t.Fatalf("256 bytes route on 1 byte: %v", err)
+	}
+	data, err := EncodeCompat(long, CompatWide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := DecodeCompat(data, CompatWide); err != nil || m.Route != long.Route {
+		t.Fatalf("wide route: %v", err)
+	}
+	if _, err := DecodeCompat([]byte{Notify << 1, 0}, CompatWide); err != ErrInvalidMessage {
+		t.Fatalf("truncated wide length: %v", err)
+	}
+}
@@ -26,6 +26,7 @@ const (
 
 const (
 	msgRouteCompressMask = 0x01
+	msgDataCompressMask  = 0x10
 	msgTypeMask          = 0x07
 	msgRouteLengthMask   = 0xFF
 	msgHeadLength        = 0x02
@@ -37,8 +38,3 @@ var types = map[byte]string{
 	Response: "Response",
 	Push:     "Push",
 }
-
-var (
-	routes = make(map[string]uint16) // route map to code
-	codes  = make(map[uint16]string) // code map to route
-)
@@ -0,0 +1,74 @@
+package message
+
+import (
+	"strings"
+	"sync"
+)
+
+// Dictionary is a route dictionary, the routes sent by the server in the
+// handshake (sys.dict) are encoded as 2 bytes codes. Every connection has
+// its own, servers don't share their codes. It is safe for concurrent
+// use.
+type Dictionary struct {
+	mu     sync.RWMutex
+	routes map[string]uint16 // route map to code
+	codes  map[uint16]string // code map to route
+}
+
+// NewDictionary returns a dictionary of dict, which may be nil
+func NewDictionary(dict map[string]uint16) *Dictionary {
+	d := &Dictionary{}
+	d.Set(dict)
+	return d
+}
+
+// Set replaces the routes of the dictionary with dict, e.g. with the
+// dictionary of a new handshake
+func (d *Dictionary) Set(dict map[string]uint16) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.routes = make(map[string]uint16, len(dict))
+	d.codes = make(map[uint16]string, len(dict))
+	for route, code := range dict {
+		r := strings.TrimSpace(route)
+		d.routes[r] = code
+		d.codes[code] = r
+	}
+}
+
+// Routes returns a copy of the dictionary
+func (d *Dictionary) Routes() map[string]uint16 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	dict := make(map[string]uint16, len(d.routes))
+	for route, code := range d.routes {
+		dict[route] = code
+	}
+	return dict
+}
+
+// code returns the code of route, a nil dictionary is empty
+func (d *Dictionary) code(route string) (uint16, bool) {
+	if d == nil {
+		return 0, false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	code, ok := d.routes[route]
+	return code, ok
+}
+
+// route returns the route of code, a nil dictionary is empty
+func (d *Dictionary) route(code uint16) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	route, ok := d.codes[code]
+	return route, ok
+}
@@ -0,0 +1,53 @@
+package message
+
+import (
+	"testing"
+)
+
+func TestDictionaryRoundTrip(t *testing.T) {
+	compat := CompatPomelo
+	compat.Dict = NewDictionary(map[string]uint16{" area.move ": 7})
+
+	data, err := EncodeCompat(&Message{Type: Notify, Route: "area.move", Data: []byte("{}")}, compat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{Notify<<1 | msgRouteCompressMask, 0, 7, '{', '}'}; string(data) != string(want) {
+		t.Fatalf("encoded % x, want % x", data, want)
+	}
+	m, err := DecodeCompat(data, compat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Route != "area.move" || string(m.Data) != "{}" {
+		t.Fatalf("decoded %s", m)
+	}
+
+	// without the dictionary the route is sent as a string and a code
+	// can't be decoded
+	plain, err := Encode(&Message{Type: Notify, Route: "area.move"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain[0]&msgRouteCompressMask != 0 {
+		t.Fatal("route compressed without dictionary")
+	}
+	if _, err := Decode(data); err != ErrRouteInfoNotFound {
+		t.Fatalf("decode without dictionary: %v", err)
+	}
+}
+
+func TestDictionarySet(t *testing.T) {
+	d := NewDictionary(map[string]uint16{"a": 1, "b": 2})
+	d.Set(map[string]uint16{"c": 1})
+
+	if _, ok := d.code("a"); ok {
+		t.Fatal("route of the previous dictionary kept")
+	}
+	if route, ok := d.route(1); !ok || route != "c" {
+		t.Fatalf("code 1 = %q, %v", route, ok)
+	}
+	if got := d.Routes(); len(got) != 1 || got["c"] != 1 {
+		t.Fatalf("routes %v", got)
+	}
+}
@@ -10,10 +10,15 @@ import "errors"
  * ErrWrongMessageType
  * ErrInvalidMessage
  * ErrRouteInfoNotFound
+ * ErrRouteTooLong
+ * ErrDataCompressUnsupported
  *
  */
 var (
 	ErrWrongMessageType  = errors.New("wrong message type")
 	ErrInvalidMessage    = errors.New("invalid message")
 	ErrRouteInfoNotFound = errors.New("route info not found in dictionary")
+	ErrRouteTooLong      = errors.New("route too long for the message header")
+
+	ErrDataCompressUnsupported = errors.New("compressed payload not supported by the message header")
 )
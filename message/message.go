@@ -16,6 +16,10 @@ type Message struct {
 	Route      string // route for locating service
 	Data       []byte // payload
 	compressed bool   // is message compressed
+
+	// DataCompressed reports the payload is compressed by the negotiated
+	// compressor, carried in the 0x10 flag bit
+	DataCompressed bool
 }
 
 // String --
@@ -0,0 +1,109 @@
+package message
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/revzim/go-pomelo-client/compress/gzip"
+)
+
+// Target budget, measured with go test -bench . on a 4 core x86-64 VM:
+//
+//	BenchmarkEncode/route/64B         < 400 ns/op, 3 allocs
+//	BenchmarkEncode/dict/64B          < 200 ns/op, 2 allocs
+//	BenchmarkDecode/route/64B         < 150 ns/op, 2 allocs
+//	BenchmarkDecode/dict/64B          < 150 ns/op, 1 alloc
+//	BenchmarkCompressedRoundTrip/4KB  < 250 us/op (gzip dominated)
+//
+// A change on the hot path exceeding the budget needs a justification.
+
+const benchRoute = "area.playerHandler.move"
+
+var payloadSizes = []int{64, 512, 4 << 10}
+
+func sizeName(n int) string {
+	if n >= 1<<10 {
+		return fmt.Sprintf("%dKB", n>>10)
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// benchCompat is the pomelo header with a dictionary, the dict variants
+// use a compressed route, the others a plain one
+var benchCompat = Compat{
+	RouteCompress: true,
+	GzipBit:       true,
+	Dict:          NewDictionary(map[string]uint16{benchRoute + ".dict": 1}),
+}
+
+func benchRoutes() map[string]string {
+	return map[string]string{"route": benchRoute, "dict": benchRoute + ".dict"}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	for name, route := range benchRoutes() {
+		for _, size := range payloadSizes {
+			m := &Message{Type: Request, ID: 1 << 20, Route: route, Data: make([]byte, size)}
+			b.Run(name+"/"+sizeName(size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := EncodeCompat(m, benchCompat); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for name, route := range benchRoutes() {
+		for _, size := range payloadSizes {
+			data, _ := EncodeCompat(&Message{Type: Push, Route: route, Data: make([]byte, size)}, benchCompat)
+			b.Run(name+"/"+sizeName(size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := DecodeCompat(data, benchCompat); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkCompressedRoundTrip compresses, encodes, decodes and
+// decompresses a JSON like payload, as the connector does with gzip
+// negotiated.
+func BenchmarkCompressedRoundTrip(b *testing.B) {
+	comp := gzip.NewCompressor()
+	for _, size := range payloadSizes {
+		payload := make([]byte, size)
+		for i := range payload {
+			payload[i] = `{"x":1,"name":"player"}`[i%23]
+		}
+		b.Run(sizeName(size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				body, err := comp.Compress(payload)
+				if err != nil {
+					b.Fatal(err)
+				}
+				data, err := (&Message{Type: Request, ID: 1, Route: benchRoute, Data: body, DataCompressed: true}).Encode()
+				if err != nil {
+					b.Fatal(err)
+				}
+				m, err := Decode(data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := comp.Decompress(m.Data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
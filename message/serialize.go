@@ -2,6 +2,7 @@ package message
 
 import (
 	"encoding/binary"
+	"math/bits"
 )
 
 // Encode marshals message to binary format. Different message types is corresponding to
@@ -18,6 +19,11 @@ import (
 // The figure above indicates that the bit does not affect the type of message.
 // See ref: https://github.com/lonnng/nano/blob/master/docs/communication_protocol.md
 func Encode(m *Message) ([]byte, error) {
+	return EncodeCompat(m, CompatPomelo)
+}
+
+// EncodeCompat marshals message to the header variant selected by compat
+func EncodeCompat(m *Message, compat Compat) ([]byte, error) {
 	if invalidType(m.Type) {
 		return nil, ErrWrongMessageType
 	}
@@ -25,10 +31,20 @@ func Encode(m *Message) ([]byte, error) {
 	buf := make([]byte, 0)
 	flag := byte(m.Type) << 1
 
-	code, compressed := routes[m.Route]
+	var code uint16
+	compressed := false
+	if compat.RouteCompress {
+		code, compressed = compat.Dict.code(m.Route)
+	}
 	if compressed {
 		flag |= msgRouteCompressMask
 	}
+	if m.DataCompressed {
+		if !compat.GzipBit {
+			return nil, ErrDataCompressUnsupported
+		}
+		flag |= msgDataCompressMask
+	}
 	buf = append(buf, flag)
 
 	if m.Type == Request || m.Type == Response {
@@ -51,7 +67,14 @@ func Encode(m *Message) ([]byte, error) {
 			buf = append(buf, byte((code>>8)&0xFF))
 			buf = append(buf, byte(code&0xFF))
 		} else {
-			buf = append(buf, byte(len(m.Route)))
+			rl := len(m.Route)
+			if rl > compat.maxRouteLength() {
+				return nil, ErrRouteTooLong
+			}
+			if compat.WideRoute {
+				buf = append(buf, byte((rl>>8)&0xFF))
+			}
+			buf = append(buf, byte(rl&0xFF))
 			buf = append(buf, []byte(m.Route)...)
 		}
 	}
@@ -63,6 +86,12 @@ func Encode(m *Message) ([]byte, error) {
 // Decode unmarshal the bytes slice to a message
 // See ref: https://github.com/lonnng/nano/blob/master/docs/communication_protocol.md
 func Decode(data []byte) (*Message, error) {
+	return DecodeCompat(data, CompatPomelo)
+}
+
+// DecodeCompat unmarshal the bytes slice in the header variant selected by
+// compat to a message
+func DecodeCompat(data []byte, compat Compat) (*Message, error) {
 	if len(data) < msgHeadLength {
 		return nil, ErrInvalidMessage
 	}
@@ -70,21 +99,27 @@ func Decode(data []byte) (*Message, error) {
 	flag := data[0]
 	offset := 1
 	m.Type = byte((flag >> 1) & msgTypeMask)
+	m.DataCompressed = compat.GzipBit && flag&msgDataCompressMask != 0
 
 	if invalidType(m.Type) {
 		return nil, ErrWrongMessageType
 	}
 
 	if m.Type == Request || m.Type == Response {
+		// little endian base 128 varint, the full uint range
 		id := uint(0)
-		// little end byte order
-		// WARNING: must can be stored in 64 bits integer
-		// variant length encode
-		for i := offset; i < len(data); i++ {
-			b := data[i]
-			id += uint(b&0x7F) << uint(7*(i-offset))
+		for shift := uint(0); ; shift += 7 {
+			if offset >= len(data) || shift >= bits.UintSize {
+				return nil, ErrInvalidMessage
+			}
+			b := data[offset]
+			offset++
+			if shift+7 > bits.UintSize && uint(b&0x7F)>>(bits.UintSize-shift) != 0 {
+				// overflows uint
+				return nil, ErrInvalidMessage
+			}
+			id |= uint(b&0x7F) << shift
 			if b < 128 {
-				offset = i + 1
 				break
 			}
 		}
@@ -92,10 +127,13 @@ func Decode(data []byte) (*Message, error) {
 	}
 
 	if routable(m.Type) {
-		if flag&msgRouteCompressMask == 1 {
+		if compat.RouteCompress && flag&msgRouteCompressMask == 1 {
+			if len(data) < offset+2 {
+				return nil, ErrInvalidMessage
+			}
 			m.compressed = true
 			code := binary.BigEndian.Uint16(data[offset:(offset + 2)])
-			route, ok := codes[code]
+			route, ok := compat.Dict.route(code)
 			if !ok {
 				return nil, ErrRouteInfoNotFound
 			}
@@ -103,10 +141,23 @@ func Decode(data []byte) (*Message, error) {
 			offset += 2
 		} else {
 			m.compressed = false
-			rl := data[offset]
-			offset++
-			m.Route = string(data[offset:(offset + int(rl))])
-			offset += int(rl)
+			size := 1
+			if compat.WideRoute {
+				size = 2
+			}
+			if len(data) < offset+size {
+				return nil, ErrInvalidMessage
+			}
+			rl := int(data[offset])
+			if compat.WideRoute {
+				rl = int(binary.BigEndian.Uint16(data[offset:(offset + 2)]))
+			}
+			offset += size
+			if len(data) < offset+rl {
+				return nil, ErrInvalidMessage
+			}
+			m.Route = string(data[offset:(offset + rl)])
+			offset += rl
 		}
 	}
 
@@ -0,0 +1,34 @@
+package message
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMidVarint(t *testing.T) {
+	for _, mid := range []uint{1, 127, 128, 1<<14 - 1, 1 << 14, 1 << 16, 1<<32 + 5, math.MaxUint32, math.MaxUint} {
+		data, err := Encode(&Message{Type: Request, ID: mid, Route: "r", Data: []byte("x")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := Decode(data)
+		if err != nil {
+			t.Fatalf("mid %d: %v", mid, err)
+		}
+		if m.ID != mid || m.Route != "r" || string(m.Data) != "x" {
+			t.Fatalf("mid %d decoded as %s", mid, m)
+		}
+	}
+}
+
+func TestMidVarintMalformed(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"truncated": {Response << 1, 0x80},
+		"too long":  append([]byte{Response << 1}, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01),
+		"overflow":  append([]byte{Response << 1}, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F),
+	} {
+		if _, err := Decode(data); err != ErrInvalidMessage {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Metric names emitted by the Connector.
+const (
+	MetricConnects        = "connects"
+	MetricDisconnects     = "disconnects"
+	MetricPacketsSent     = "packets.sent"
+	MetricPacketsReceived = "packets.received"
+	MetricBytesSent       = "bytes.sent"
+	MetricBytesReceived   = "bytes.received"
+	MetricRequests        = "requests"
+	MetricResponses       = "responses"
+	MetricNotifies        = "notifies"
+	MetricPushes          = "pushes"
+	MetricPending         = "requests.pending"
+	MetricPayloadSent     = "payload.sent"
+	MetricPayloadReceived = "payload.received"
+	MetricOrphanResponses = "responses.orphan"
+	MetricLatency         = "requests.latency" // milliseconds
+
+	MetricNotifiesDeduplicated = "notifies.deduplicated"
+	MetricPushesFiltered       = "pushes.filtered"
+	MetricReconnectTime        = "reconnect.time" // milliseconds
+	MetricReconnectAttempts    = "reconnect.attempts"
+)
+
+// MetricsSink receives the connector metrics, implementations must be
+// safe for concurrent use.
+type MetricsSink interface {
+	// IncrCounter adds delta to the named counter
+	IncrCounter(name string, delta int64)
+	// SetGauge sets the named gauge to value
+	SetGauge(name string, value float64)
+	// Observe records one sample of the named distribution
+	Observe(name string, value float64)
+}
+
+type nopSink struct{}
+
+func (nopSink) IncrCounter(string, int64) {}
+func (nopSink) SetGauge(string, float64)  {}
+func (nopSink) Observe(string, float64)   {}
+
+// ExpvarSink publishes metrics as an expvar.Map, visible at /debug/vars
+type ExpvarSink struct {
+	vars *expvar.Map
+
+	mu     sync.Mutex
+	gauges map[string]*expvar.Float
+	hists  map[string]*expvar.Map
+}
+
+// NewExpvarSink returns an ExpvarSink publishing under name, an existing
+// map with the same name is reused.
+func NewExpvarSink(name string) *ExpvarSink {
+	vars, ok := expvar.Get(name).(*expvar.Map)
+	if !ok {
+		vars = expvar.NewMap(name)
+	}
+	return &ExpvarSink{
+		vars:   vars,
+		gauges: map[string]*expvar.Float{},
+		hists:  map[string]*expvar.Map{},
+	}
+}
+
+// IncrCounter --
+func (s *ExpvarSink) IncrCounter(name string, delta int64) {
+	s.vars.Add(name, delta)
+}
+
+// SetGauge --
+func (s *ExpvarSink) SetGauge(name string, value float64) {
+	s.mu.Lock()
+	g, ok := s.gauges[name]
+	if !ok {
+		g = new(expvar.Float)
+		s.gauges[name] = g
+		s.vars.Set(name, g)
+	}
+	s.mu.Unlock()
+
+	g.Set(value)
+}
+
+// Observe records count, sum and last value of the distribution
+func (s *ExpvarSink) Observe(name string, value float64) {
+	s.mu.Lock()
+	h, ok := s.hists[name]
+	if !ok {
+		h = new(expvar.Map).Init()
+		s.hists[name] = h
+		s.vars.Set(name, h)
+	}
+	s.mu.Unlock()
+
+	h.Add("count", 1)
+	h.AddFloat("sum", value)
+	last := new(expvar.Float)
+	last.Set(value)
+	h.Set("last", last)
+}
+
+// StatsdSink sends metrics to a StatsD daemon over UDP
+type StatsdSink struct {
+	conn   net.Conn
+	prefix string
+}
+
+// NewStatsdSink dials the StatsD daemon at addr, every metric name is
+// prefixed with prefix (e.g. "pomelo.").
+func NewStatsdSink(addr, prefix string) (*StatsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsdSink{conn: conn, prefix: prefix}, nil
+}
+
+// IncrCounter --
+func (s *StatsdSink) IncrCounter(name string, delta int64) {
+	s.emit(name, strconv.FormatInt(delta, 10), "c")
+}
+
+// SetGauge --
+func (s *StatsdSink) SetGauge(name string, value float64) {
+	s.emit(name, strconv.FormatFloat(value, 'f', -1, 64), "g")
+}
+
+// Observe --
+func (s *StatsdSink) Observe(name string, value float64) {
+	s.emit(name, strconv.FormatFloat(value, 'f', -1, 64), "h")
+}
+
+// Close closes the underlying UDP socket
+func (s *StatsdSink) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsdSink) emit(name, value, typ string) {
+	// statsd is fire and forget, a lost datagram is not an error
+	fmt.Fprintf(s.conn, "%s%s:%s|%s", s.prefix, name, value, typ)
+}
+
+// SetMetricsSink sets the sink receiving the connector metrics
+func (c *Connector) SetMetricsSink(sink MetricsSink) {
+	if sink == nil {
+		sink = nopSink{}
+	}
+	c.metrics = sink
+}
+
+func (c *Connector) reportPending() {
+	c.metrics.SetGauge(MetricPending, float64(c.pendingCount()))
+}
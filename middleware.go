@@ -0,0 +1,97 @@
+package client
+
+import (
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+// Middleware intercepts the messages exchanged with the server, it may
+// rewrite the message in place. Middlewares run on the goroutine sending
+// or reading the message and must be safe for concurrent use.
+type Middleware interface {
+	// Outgoing is called for every request and notify before it is
+	// compressed and encoded, an error aborts the send.
+	Outgoing(msg *message.Message) error
+	// Incoming is called for every response and push after it is decoded,
+	// before its handler. The route of responses is the request route. An
+	// error drops a push and fails the pending request of a response.
+	Incoming(msg *message.Message) error
+}
+
+// AddMiddleware appends middlewares, outgoing messages go through them in
+// order and incoming messages in reverse order. Middlewares implementing
+// SessionAware are given the connector session. Middlewares must be added
+// before Run. Middlewares implementing ContextMiddleware are given the
+// connection context.
+func (c *Connector) AddMiddleware(mws ...Middleware) {
+	for _, mw := range mws {
+		if sa, ok := mw.(SessionAware); ok {
+			sa.SetSession(c.session)
+		}
+	}
+	c.middlewares = append(c.middlewares, mws...)
+}
+
+func (c *Connector) outgoing(msg *message.Message) error {
+	if len(c.middlewares) == 0 {
+		return nil
+	}
+
+	ctx := c.Context()
+	for _, mw := range c.middlewares {
+		var err error
+		if cm, ok := mw.(ContextMiddleware); ok {
+			err = cm.OutgoingContext(ctx, msg)
+		} else {
+			err = mw.Outgoing(msg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// incoming reports whether the message must be processed
+func (c *Connector) incoming(msg *message.Message) bool {
+	if len(c.middlewares) == 0 {
+		return true
+	}
+
+	if msg.Type == message.Response {
+		msg.Route = c.pendingRoute(msg.ID)
+	}
+	ctx := c.Context()
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		var err error
+		if cm, ok := c.middlewares[i].(ContextMiddleware); ok {
+			err = cm.IncomingContext(ctx, msg)
+		} else {
+			err = c.middlewares[i].Incoming(msg)
+		}
+		if err == nil {
+			continue
+		}
+
+		c.logWarn("message rejected by middleware", Field{"route", msg.Route}, Field{"mid", msg.ID}, Field{"error", err})
+		if msg.Type == message.Response {
+			if pr, ok := c.takePending(msg.ID); ok {
+				c.releaseProbe(pr)
+				c.reportPending()
+				c.deliver(pr, func() {
+					if pr.onError != nil {
+						pr.onError(err)
+					}
+				})
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (c *Connector) pendingRoute(mid uint) string {
+	if pr, ok := c.responses.get(mid); ok {
+		return pr.route
+	}
+	return ""
+}
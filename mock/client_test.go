@@ -0,0 +1,53 @@
+package mock_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+
+	client "github.com/revzim/go-pomelo-client"
+	"github.com/revzim/go-pomelo-client/mock"
+)
+
+// joinRoom is application code depending on client.Client
+func joinRoom(c client.Client, room string, onChat func(msg string)) error {
+	c.On("onChat", func(data []byte) {
+		onChat(string(data))
+	})
+	return c.Request("room.join", []byte(room), func([]byte) {})
+}
+
+func TestMockClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockClient(ctrl)
+	var push client.Callback
+	m.EXPECT().On("onChat", gomock.Any()).Do(func(_ string, cb client.Callback) {
+		push = cb
+	})
+	m.EXPECT().Request("room.join", []byte("lobby"), gomock.Any()).Return(nil)
+
+	var got []string
+	if err := joinRoom(m, "lobby", func(msg string) { got = append(got, msg) }); err != nil {
+		t.Fatal(err)
+	}
+	push([]byte("hello"))
+	if len(got) != 1 || got[0] != "hello" {
+		t.Fatalf("chat %q", got)
+	}
+}
+
+func TestMockClientError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockClient(ctrl)
+	m.EXPECT().On(gomock.Any(), gomock.Any())
+	m.EXPECT().Request(gomock.Any(), gomock.Any(), gomock.Any()).Return(client.ErrConnectorClosed)
+
+	if err := joinRoom(m, "lobby", func(string) {}); !errors.Is(err, client.ErrConnectorClosed) {
+		t.Fatalf("error %v", err)
+	}
+}
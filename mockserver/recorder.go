@@ -0,0 +1,98 @@
+package mockserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+// Record is the NDJSON line of a request and its response in a recorded
+// session. Bodies that are not valid UTF-8, e.g. protobuf, are base64
+// encoded and prefixed with "base64:".
+type Record struct {
+	Route        string `json:"route"`
+	Mid          uint   `json:"mid"`
+	RequestSize  int    `json:"req_size"`  // payload bytes
+	ResponseSize int    `json:"resp_size"` // payload bytes
+	Request      string `json:"req,omitempty"`
+	Response     string `json:"resp,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// Recorder is a client middleware recording the session of a connector,
+// a Record line is written for every answered request:
+//
+//	c.AddMiddleware(mockserver.NewRecorder(f))
+//
+// The requests are recorded as given to Request, before the transforms
+// and compression of the connector, and the responses after their
+// decompression. A request never answered, e.g. timed out, is not
+// recorded.
+type Recorder struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	pending map[uint]Record
+}
+
+// NewRecorder returns a recorder writing to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w), pending: map[uint]Record{}}
+}
+
+// Outgoing keeps the payload of the requests until their response
+func (r *Recorder) Outgoing(msg *message.Message) error {
+	if msg.Type != message.Request {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending[msg.ID] = Record{
+		Route:       msg.Route,
+		Mid:         msg.ID,
+		RequestSize: len(msg.Data),
+		Request:     encodeBody(msg.Data),
+	}
+	return nil
+}
+
+// Incoming writes the record of a response, a failed write does not fail
+// the request
+func (r *Recorder) Incoming(msg *message.Message) error {
+	if msg.Type != message.Response {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec, ok := r.pending[msg.ID]
+	if !ok {
+		return nil
+	}
+	delete(r.pending, msg.ID)
+	rec.ResponseSize = len(msg.Data)
+	rec.Response = encodeBody(msg.Data)
+	r.enc.Encode(rec)
+	return nil
+}
+
+// encodeBody returns data as a record body
+func encodeBody(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return "base64:" + base64.StdEncoding.EncodeToString(data)
+}
+
+// decodeBody decodes a body of a record
+func decodeBody(body string) ([]byte, error) {
+	if b := strings.TrimPrefix(body, "base64:"); len(b) < len(body) {
+		return base64.StdEncoding.DecodeString(b)
+	}
+	return []byte(body), nil
+}
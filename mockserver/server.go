@@ -0,0 +1,254 @@
+// Package mockserver records the session of a connector and serves it
+// back: a pomelo server answering the requests with the recorded
+// responses, so clients can be developed offline against production-like
+// behavior. It speaks the pomelo protocol over tcp.
+//
+// Only the request/response exchanges are replayed. Notifies are read
+// and ignored, the server sends no push, and the handshake announces
+// neither route dictionary nor compression, so the client sends every
+// route in full.
+package mockserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/revzim/go-pomelo-client/codec"
+	"github.com/revzim/go-pomelo-client/message"
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+// DefaultMiss is the response to the requests without recorded response
+var DefaultMiss = []byte(`{"code":500,"msg":"no recorded response"}`)
+
+// Server answers the recorded requests. A request is matched on its route
+// and body first, then on its route alone, the responses of a match are
+// sent in recorded order and the last one is repeated once exhausted.
+type Server struct {
+	mu        sync.Mutex
+	exact     map[exchangeKey]*responses
+	routes    map[string]*responses
+	miss      []byte
+	heartbeat int // handshake sys.heartbeat, seconds
+	ln        net.Listener
+}
+
+type exchangeKey struct {
+	route string
+	body  string
+}
+
+// responses are the recorded responses of a request
+type responses struct {
+	bodies [][]byte
+	next   int
+}
+
+func (r *responses) take() []byte {
+	body := r.bodies[r.next]
+	if r.next < len(r.bodies)-1 {
+		r.next++
+	}
+	return body
+}
+
+// New returns a server answering with the session read from r, Record
+// lines as written by a Recorder or the ExchangeRecord lines of
+// Connector.SetExchangeLog. Failed requests and truncated responses can't
+// be replayed and are skipped, a truncated request only matches on its
+// route.
+func New(r io.Reader) (*Server, error) {
+	s := &Server{
+		exact:  map[exchangeKey]*responses{},
+		routes: map[string]*responses{},
+		miss:   DefaultMiss,
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("session line %d: %w", line, err)
+		}
+		if err := s.add(&rec); err != nil {
+			return nil, fmt.Errorf("session line %d: %w", line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Server) add(rec *Record) error {
+	if rec.Error != "" {
+		return nil
+	}
+	req, err := decodeBody(rec.Request)
+	if err != nil {
+		return err
+	}
+	resp, err := decodeBody(rec.Response)
+	if err != nil {
+		return err
+	}
+	if len(resp) < rec.ResponseSize {
+		return nil
+	}
+
+	if len(req) == rec.RequestSize {
+		key := exchangeKey{route: rec.Route, body: string(req)}
+		s.exact[key] = appendResponse(s.exact[key], resp)
+	}
+	s.routes[rec.Route] = appendResponse(s.routes[rec.Route], resp)
+	return nil
+}
+
+func appendResponse(r *responses, body []byte) *responses {
+	if r == nil {
+		r = &responses{}
+	}
+	r.bodies = append(r.bodies, body)
+	return r
+}
+
+// Routes returns the number of routes with a recorded response
+func (s *Server) Routes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.routes)
+}
+
+// SetMiss sets the response to the requests without recorded response,
+// DefaultMiss by default. It must be set before Serve.
+func (s *Server) SetMiss(data []byte) {
+	s.miss = data
+}
+
+// SetHeartbeat sets the heartbeat interval announced in the handshake,
+// in seconds, zero disables the heartbeats. It must be set before Serve.
+func (s *Server) SetHeartbeat(seconds int) {
+	s.heartbeat = seconds
+}
+
+// ListenAndServe listens on the tcp address addr and serves the
+// connections until Close.
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve serves the connections accepted on ln until Close.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serve(conn)
+	}
+}
+
+// Close stops accepting connections
+func (s *Server) Close() error {
+	s.mu.Lock()
+	ln := s.ln
+	s.mu.Unlock()
+
+	if ln == nil {
+		return nil
+	}
+	return ln.Close()
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	dec := codec.NewDecoder()
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		packets, err := dec.Decode(buf[:n])
+		if err != nil {
+			return
+		}
+		for _, p := range packets {
+			if err := s.handle(conn, p); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) handle(conn net.Conn, p *packet.Packet) error {
+	switch p.Type {
+	case packet.Handshake:
+		resp, err := json.Marshal(map[string]interface{}{
+			"code": 200,
+			"sys":  map[string]interface{}{"heartbeat": s.heartbeat},
+		})
+		if err != nil {
+			return err
+		}
+		return send(conn, packet.Handshake, resp)
+	case packet.Heartbeat:
+		return send(conn, packet.Heartbeat, nil)
+	case packet.Data:
+		msg, err := message.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		if msg.Type != message.Request {
+			return nil
+		}
+		data, err := message.Encode(&message.Message{Type: message.Response, ID: msg.ID, Data: s.response(msg.Route, msg.Data)})
+		if err != nil {
+			return err
+		}
+		return send(conn, packet.Data, data)
+	}
+	return nil
+}
+
+// response returns the response to the request of route with body
+func (s *Server) response(route string, body []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.exact[exchangeKey{route: route, body: string(body)}]; ok {
+		return r.take()
+	}
+	if r, ok := s.routes[route]; ok {
+		return r.take()
+	}
+	return s.miss
+}
+
+func send(conn net.Conn, typ byte, data []byte) error {
+	frame, err := codec.Encode(typ, data)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(frame)
+	return err
+}
@@ -0,0 +1,233 @@
+package mockserver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/revzim/go-pomelo-client"
+	"github.com/revzim/go-pomelo-client/message"
+	"github.com/revzim/go-pomelo-client/mockserver"
+)
+
+const testTimeout = 5 * time.Second
+
+// session returns the recorded session of recs
+func session(t *testing.T, recs ...mockserver.Record) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range recs {
+		if err := enc.Encode(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &buf
+}
+
+// serve starts s and returns its address
+func serve(t *testing.T, s *mockserver.Server) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	t.Cleanup(func() { s.Close() })
+	return "tcp://" + ln.Addr().String()
+}
+
+// connect returns a connector ready on addr
+func connect(t *testing.T, addr string) *client.Connector {
+	t.Helper()
+
+	c := client.NewConnector()
+	if err := c.InitReqHandshake("", "test", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InitHandshakeACK(1); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Run(addr)
+	}()
+	select {
+	case <-c.Ready():
+	case err := <-errCh:
+		t.Fatalf("run: %v", err)
+	case <-time.After(testTimeout):
+		t.Fatal("handshake timeout")
+	}
+	return c
+}
+
+// request sends a request and returns its response
+func request(t *testing.T, c *client.Connector, route, data string) string {
+	t.Helper()
+
+	ch := make(chan string, 1)
+	if err := c.Request(route, []byte(data), func(resp []byte) {
+		ch <- string(resp)
+	}, client.WithTimeout(testTimeout)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case resp := <-ch:
+		return resp
+	case <-time.After(testTimeout):
+		t.Fatalf("no response to %s", route)
+		return ""
+	}
+}
+
+func TestServerAnswersRecordedRequests(t *testing.T) {
+	s, err := mockserver.New(session(t,
+		mockserver.Record{Route: "area.get", Request: `{"id":1}`, RequestSize: 8, Response: `{"name":"a"}`, ResponseSize: 12},
+		mockserver.Record{Route: "area.get", Request: `{"id":2}`, RequestSize: 8, Response: `{"name":"b"}`, ResponseSize: 12},
+		mockserver.Record{Route: "tick", Request: `{}`, RequestSize: 2, Response: `{"n":1}`, ResponseSize: 7},
+		mockserver.Record{Route: "tick", Request: `{}`, RequestSize: 2, Response: `{"n":2}`, ResponseSize: 7},
+		mockserver.Record{Route: "blob", RequestSize: 0, Response: "base64:/wA=", ResponseSize: 2},
+		mockserver.Record{Route: "failed", Error: "request timeout"},
+		mockserver.Record{Route: "cut", Response: `{"na`, ResponseSize: 12},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Routes(); n != 3 {
+		t.Fatalf("%d routes, want 3", n)
+	}
+	s.SetMiss([]byte(`{"code":404}`))
+	c := connect(t, serve(t, s))
+
+	for _, tc := range []struct {
+		route, req, want string
+	}{
+		{"area.get", `{"id":2}`, `{"name":"b"}`},
+		{"area.get", `{"id":1}`, `{"name":"a"}`},
+		// no recorded body, answered on the route
+		{"area.get", `{"id":3}`, `{"name":"a"}`},
+		// in recorded order, then the last one again
+		{"tick", `{}`, `{"n":1}`},
+		{"tick", `{}`, `{"n":2}`},
+		{"tick", `{}`, `{"n":2}`},
+		{"blob", ``, "\xff\x00"},
+		{"failed", `{}`, `{"code":404}`},
+		{"cut", `{}`, `{"code":404}`},
+	} {
+		if got := request(t, c, tc.route, tc.req); got != tc.want {
+			t.Errorf("%s %s: response %q, want %q", tc.route, tc.req, got, tc.want)
+		}
+	}
+}
+
+func TestServerReplaysRecorder(t *testing.T) {
+	recorded, err := mockserver.New(session(t,
+		mockserver.Record{Route: "user.login", Request: `{"name":"a"}`, RequestSize: 12, Response: `{"code":200,"uid":7}`, ResponseSize: 20},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log bytes.Buffer
+	c := client.NewConnector()
+	c.AddMiddleware(mockserver.NewRecorder(&log))
+	if err := c.InitReqHandshake("", "test", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InitHandshakeACK(1); err != nil {
+		t.Fatal(err)
+	}
+	go c.Run(serve(t, recorded))
+	select {
+	case <-c.Ready():
+	case <-time.After(testTimeout):
+		t.Fatal("handshake timeout")
+	}
+	want := request(t, c, "user.login", `{"name":"a"}`)
+	c.Close()
+
+	// the session recorded by the client serves the same response
+	s, err := mockserver.New(strings.NewReader(log.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := request(t, connect(t, serve(t, s)), "user.login", `{"name":"a"}`); got != want {
+		t.Fatalf("response %q, want %q", got, want)
+	}
+}
+
+func TestRecorderEncodesBinaryBodies(t *testing.T) {
+	var log bytes.Buffer
+	r := mockserver.NewRecorder(&log)
+	if err := r.Outgoing(&message.Message{Type: message.Request, ID: 3, Route: "blob", Data: []byte{0xff, 0x00}}); err != nil {
+		t.Fatal(err)
+	}
+	// notifies and unknown responses are not recorded
+	r.Outgoing(&message.Message{Type: message.Notify, Route: "chat.send", Data: []byte(`{}`)})
+	r.Incoming(&message.Message{Type: message.Response, ID: 4, Data: []byte(`{}`)})
+	if err := r.Incoming(&message.Message{Type: message.Response, ID: 3, Route: "blob", Data: []byte(`{"ok":true}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var rec mockserver.Record
+	if err := json.Unmarshal(log.Bytes(), &rec); err != nil {
+		t.Fatalf("%v: %q", err, log.String())
+	}
+	want := mockserver.Record{Route: "blob", Mid: 3, Request: "base64:/wA=", RequestSize: 2, Response: `{"ok":true}`, ResponseSize: 11}
+	if rec != want {
+		t.Fatalf("record %+v, want %+v", rec, want)
+	}
+}
+
+func TestServerReplaysExchangeLog(t *testing.T) {
+	recorded, err := mockserver.New(session(t,
+		mockserver.Record{Route: "area.get", Request: `{"id":1}`, RequestSize: 8, Response: `{"name":"a"}`, ResponseSize: 12},
+		mockserver.Record{Route: "area.get", Request: `{"id":2}`, RequestSize: 8, Response: `{"name":"bb"}`, ResponseSize: 13},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log bytes.Buffer
+	c := client.NewConnector()
+	c.SetExchangeLog(&log, 1<<10)
+	if err := c.InitReqHandshake("", "test", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InitHandshakeACK(1); err != nil {
+		t.Fatal(err)
+	}
+	go c.Run(serve(t, recorded))
+	select {
+	case <-c.Ready():
+	case <-time.After(testTimeout):
+		t.Fatal("handshake timeout")
+	}
+	request(t, c, "area.get", `{"id":1}`)
+	want := request(t, c, "area.get", `{"id":2}`)
+	c.Close()
+
+	// the exchange log matches on the body too
+	s, err := mockserver.New(strings.NewReader(log.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := request(t, connect(t, serve(t, s)), "area.get", `{"id":2}`); got != want {
+		t.Fatalf("response %q, want %q", got, want)
+	}
+}
+
+func TestServerRejectsMalformedLog(t *testing.T) {
+	_, err := mockserver.New(strings.NewReader("{\"route\":\"a\"}\nnot json\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("error %v", err)
+	}
+}
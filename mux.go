@@ -0,0 +1,232 @@
+package client
+
+import (
+	"sync"
+)
+
+type (
+	// Mux shares one connector between independent logical clients, each
+	// Channel has its own push handlers, pending requests and lifecycle.
+	// The mux owns the connector handlers of the routes its channels
+	// listen to, On must not be called on the connector for them.
+	Mux struct {
+		c *Connector
+
+		mu       sync.RWMutex
+		channels map[*Channel]struct{}
+
+		// muRoutes covers the count and the connector On/Off together, it
+		// is not taken by dispatch. The pushes buffered for replay are
+		// dispatched within On, under it.
+		muRoutes sync.Mutex
+		routes   map[string]int // channels listening to a route
+	}
+
+	// Channel is a logical client of a Mux
+	Channel struct {
+		mux  *Mux
+		name string
+
+		mu      sync.Mutex
+		events  map[string]Callback
+		pending map[uint64]func(err error) // error handlers of the in-flight requests
+		nextID  uint64
+		closed  bool
+	}
+)
+
+// NewMux returns a mux over c
+func NewMux(c *Connector) *Mux {
+	return &Mux{c: c, channels: map[*Channel]struct{}{}, routes: map[string]int{}}
+}
+
+// Connector returns the shared connector
+func (m *Mux) Connector() *Connector {
+	return m.c
+}
+
+// Channel opens a new logical channel, name is only used in logs
+func (m *Mux) Channel(name string) *Channel {
+	ch := &Channel{
+		mux:     m,
+		name:    name,
+		events:  map[string]Callback{},
+		pending: map[uint64]func(err error){},
+	}
+
+	m.mu.Lock()
+	m.channels[ch] = struct{}{}
+	m.mu.Unlock()
+	return ch
+}
+
+// listen registers the connector handler of route for its first channel
+func (m *Mux) listen(route string) {
+	m.muRoutes.Lock()
+	defer m.muRoutes.Unlock()
+
+	m.routes[route]++
+	if m.routes[route] == 1 {
+		m.c.On(route, func(data []byte) {
+			m.dispatch(route, data)
+		})
+	}
+}
+
+// release removes the connector handler of route with its last channel
+func (m *Mux) release(route string) {
+	m.muRoutes.Lock()
+	defer m.muRoutes.Unlock()
+
+	m.routes[route]--
+	if m.routes[route] <= 0 {
+		delete(m.routes, route)
+		m.c.Off(route)
+	}
+}
+
+// dispatch delivers a push to every channel listening to route
+func (m *Mux) dispatch(route string, data []byte) {
+	m.mu.RLock()
+	var cbs []Callback
+	for ch := range m.channels {
+		if cb, ok := ch.handler(route); ok {
+			cbs = append(cbs, cb)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, cb := range cbs {
+		cb(data)
+	}
+}
+
+// Name --
+func (ch *Channel) Name() string {
+	return ch.name
+}
+
+// On adds the callback of the channel for the event, other channels
+// listening to the same event keep receiving it. Pushes buffered for
+// replay are delivered before On returns, their handlers must not call
+// On or Off.
+func (ch *Channel) On(event string, callback Callback) {
+	ch.mu.Lock()
+	if ch.closed {
+		ch.mu.Unlock()
+		return
+	}
+	_, replaced := ch.events[event]
+	ch.events[event] = callback
+	ch.mu.Unlock()
+
+	if !replaced {
+		ch.mux.listen(event)
+	}
+}
+
+// Off removes the callback of the channel for the event
+func (ch *Channel) Off(event string) {
+	ch.mu.Lock()
+	_, ok := ch.events[event]
+	delete(ch.events, event)
+	ch.mu.Unlock()
+
+	if ok {
+		ch.mux.release(event)
+	}
+}
+
+func (ch *Channel) handler(event string) (Callback, bool) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	cb, ok := ch.events[event]
+	return cb, ok
+}
+
+// Request sends a request on the shared connector, the request fails
+// with ErrChannelClosed if the channel is closed before the response.
+func (ch *Channel) Request(route string, data []byte, callback Callback, opts ...RequestOption) error {
+	var o requestOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ch.mu.Lock()
+	if ch.closed {
+		ch.mu.Unlock()
+		return ErrChannelClosed
+	}
+	id := ch.nextID
+	ch.nextID++
+	ch.pending[id] = o.onError
+	ch.mu.Unlock()
+
+	err := ch.mux.c.Request(route, data, func(data []byte) {
+		if ch.complete(id) {
+			callback(data)
+		}
+	}, append(opts, WithErrorHandler(func(err error) {
+		if ch.complete(id) && o.onError != nil {
+			o.onError(err)
+		}
+	}))...)
+	if err != nil {
+		ch.complete(id)
+	}
+	return err
+}
+
+// complete reports whether the request id was still pending
+func (ch *Channel) complete(id uint64) bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	_, ok := ch.pending[id]
+	delete(ch.pending, id)
+	return ok
+}
+
+// Notify sends a notification on the shared connector
+func (ch *Channel) Notify(route string, data []byte) error {
+	ch.mu.Lock()
+	closed := ch.closed
+	ch.mu.Unlock()
+	if closed {
+		return ErrChannelClosed
+	}
+	return ch.mux.c.Notify(route, data)
+}
+
+// Close removes the channel handlers and fails its pending requests with
+// ErrChannelClosed, the shared connector and other channels are left
+// untouched. It is safe to call Close multiple times.
+func (ch *Channel) Close() error {
+	ch.mu.Lock()
+	if ch.closed {
+		ch.mu.Unlock()
+		return nil
+	}
+	ch.closed = true
+	events := ch.events
+	pending := ch.pending
+	ch.events = map[string]Callback{}
+	ch.pending = map[uint64]func(err error){}
+	ch.mu.Unlock()
+
+	m := ch.mux
+	m.mu.Lock()
+	delete(m.channels, ch)
+	m.mu.Unlock()
+	for event := range events {
+		m.release(event)
+	}
+
+	for _, onError := range pending {
+		if onError != nil {
+			onError(ErrChannelClosed)
+		}
+	}
+	return nil
+}
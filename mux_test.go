@@ -0,0 +1,38 @@
+package client
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMuxListenRelease(t *testing.T) {
+	c := NewConnector()
+	m := NewMux(c)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		ch := m.Channel("worker")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				ch.On("chat", func([]byte) {})
+				ch.Off("chat")
+			}
+		}()
+	}
+	wg.Wait()
+	if _, ok := c.eventHandler("chat"); ok {
+		t.Fatal("handler left without listening channel")
+	}
+
+	ch := m.Channel("last")
+	ch.On("chat", func([]byte) {})
+	if _, ok := c.eventHandler("chat"); !ok {
+		t.Fatal("no handler for the listening channel")
+	}
+	ch.Close()
+	if _, ok := c.eventHandler("chat"); ok {
+		t.Fatal("handler left after the channel closed")
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+// outbound is a frame waiting in the send queue
+type outbound struct {
+	data []byte
+	done chan error // receives the write result, may be nil
+}
+
+// NotifySync sends a notification and waits until it was written to the
+// connection, returning the write error. Unlike Notify a failed write is
+// never silently lost.
+func (c *Connector) NotifySync(route string, data []byte) error {
+	if c.isDraining() {
+		return ErrDraining
+	}
+	if err := c.checkOutbound(route); err != nil {
+		return err
+	}
+	if c.duplicateNotify(route, data) {
+		return nil
+	}
+
+	msg := &message.Message{
+		Type:  message.Notify,
+		Route: route,
+		Data:  data,
+	}
+	done := make(chan error, 1)
+	if err := c.sendMessageCtx(nil, msg, done); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+	case <-c.done():
+		select {
+		case err := <-done:
+			// written right before the connector closed
+			if err != nil {
+				return err
+			}
+		default:
+			return ErrConnectorClosed
+		}
+	}
+
+	c.metrics.IncrCounter(MetricNotifies, 1)
+	c.routeTable.notify(route, c.clock.Now())
+	return nil
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"sync"
+)
+
+type (
+	// orderedResponses holds the in-flight requests of every ordered
+	// sequence in request order
+	orderedResponses struct {
+		mu     sync.Mutex
+		queues map[string]*orderedQueue
+	}
+
+	orderedQueue struct {
+		slots    []*orderedSlot
+		draining bool // a goroutine is invoking the ready callbacks
+	}
+
+	// orderedSlot is the place of a request in its sequence
+	orderedSlot struct {
+		key  string
+		run  func() // completion, nil while unanswered
+		done bool
+	}
+)
+
+func newOrderedResponses() *orderedResponses {
+	return &orderedResponses{queues: map[string]*orderedQueue{}}
+}
+
+// SetOrderedResponses makes the response and error callbacks of route
+// run in request order even when the server answers out of order, an
+// early response is buffered until the responses of every older request
+// of the route are delivered.
+func (c *Connector) SetOrderedResponses(route string, ordered bool) {
+	c.muResponses.Lock()
+	defer c.muResponses.Unlock()
+
+	if !ordered {
+		delete(c.orderedRoutes, route)
+		return
+	}
+	c.orderedRoutes[route] = true
+}
+
+// WithSequence orders the callbacks of the request with every request
+// sharing key, across routes, like SetOrderedResponses does per route
+func WithSequence(key string) RequestOption {
+	return func(o *requestOptions) {
+		o.sequence = key
+	}
+}
+
+// sequenceKey returns the ordered sequence of a request, empty if none
+func (c *Connector) sequenceKey(route string, o requestOptions) string {
+	if o.sequence != "" {
+		return o.sequence
+	}
+	c.muResponses.RLock()
+	defer c.muResponses.RUnlock()
+
+	if c.orderedRoutes[route] {
+		// route keys can't clash with WithSequence keys
+		return "route:" + route
+	}
+	return ""
+}
+
+// reserve appends a slot to the sequence of key
+func (o *orderedResponses) reserve(key string) *orderedSlot {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	q, ok := o.queues[key]
+	if !ok {
+		q = &orderedQueue{}
+		o.queues[key] = q
+	}
+	slot := &orderedSlot{key: key}
+	q.slots = append(q.slots, slot)
+	return slot
+}
+
+// complete records the completion of slot and runs the completions
+// ready in order, run may be invoked later by another goroutine
+func (o *orderedResponses) complete(slot *orderedSlot, run func()) {
+	o.mu.Lock()
+	if slot.done {
+		o.mu.Unlock()
+		return
+	}
+	slot.done, slot.run = true, run
+	q := o.queues[slot.key]
+	if q.draining {
+		o.mu.Unlock()
+		return
+	}
+	q.draining = true
+
+	for {
+		if len(q.slots) == 0 || !q.slots[0].done {
+			q.draining = false
+			if len(q.slots) == 0 {
+				delete(o.queues, slot.key)
+			}
+			o.mu.Unlock()
+			return
+		}
+		head := q.slots[0]
+		q.slots = q.slots[1:]
+		o.mu.Unlock()
+
+		head.run()
+		o.mu.Lock()
+	}
+}
+
+// deliver runs the completion of pr, in order if it belongs to a sequence
+func (c *Connector) deliver(pr *pendingRequest, run func()) {
+	if !pr.direct {
+		fn := run
+		run = func() { c.dispatch(fn) }
+	}
+	if pr.slot == nil {
+		run()
+		return
+	}
+	c.ordered.complete(pr.slot, run)
+}
+
+// abortPending removes pr after a failed send, the error is returned to
+// the caller so only its place in the sequence is released
+func (c *Connector) abortPending(pr *pendingRequest) {
+	if _, ok := c.takePending(pr.mid); ok {
+		c.deliver(pr, func() {})
+	}
+}
@@ -0,0 +1,92 @@
+package client
+
+import "sync"
+
+// DefaultPauseBuffer is the number of pushes held while paused by default
+const DefaultPauseBuffer = 256
+
+// pushGate holds the pushes received while push dispatch is paused
+type pushGate struct {
+	mu     sync.Mutex
+	paused bool
+	size   int
+	held   []heldPush
+}
+
+type heldPush struct {
+	route string
+	data  []byte
+}
+
+// SetPauseBuffer sets the number of pushes held while paused,
+// DefaultPauseBuffer by default, n <= 0 restores it. Once full the oldest
+// held push is dropped with a warning.
+func (c *Connector) SetPauseBuffer(n int) {
+	c.pushGate.mu.Lock()
+	defer c.pushGate.mu.Unlock()
+
+	if n <= 0 {
+		n = DefaultPauseBuffer
+	}
+	c.pushGate.size = n
+}
+
+// PausePushes holds the incoming pushes instead of running their
+// handlers, e.g. during a scene transition, until ResumePushes. Responses
+// are still delivered.
+func (c *Connector) PausePushes() {
+	c.pushGate.mu.Lock()
+	defer c.pushGate.mu.Unlock()
+
+	c.pushGate.paused = true
+}
+
+// ResumePushes dispatches the held pushes in arrival order, on the
+// goroutine calling it, then resumes the push dispatch. Pushes received
+// meanwhile are dispatched after the held ones.
+func (c *Connector) ResumePushes() {
+	for {
+		g := &c.pushGate
+		g.mu.Lock()
+		held := g.held
+		g.held = nil
+		if len(held) == 0 {
+			g.paused = false
+			g.mu.Unlock()
+			return
+		}
+		g.mu.Unlock()
+
+		for _, p := range held {
+			if !c.dispatchPush(p.route, p.data) {
+				c.settlePush()
+			}
+		}
+	}
+}
+
+// holdPush reports whether the push was held by a pause
+func (c *Connector) holdPush(route string, data []byte) bool {
+	g := &c.pushGate
+	g.mu.Lock()
+	if !g.paused {
+		g.mu.Unlock()
+		return false
+	}
+	size := g.size
+	if size <= 0 {
+		size = DefaultPauseBuffer
+	}
+	dropped := len(g.held) >= size
+	if dropped {
+		c.logWarn("paused push dropped, buffer full", Field{"route", g.held[0].route}, Field{"bytes", len(g.held[0].data)})
+		g.held = g.held[1:]
+	}
+	g.held = append(g.held, heldPush{route: route, data: cloneBytes(data)})
+	g.mu.Unlock()
+
+	if dropped {
+		c.settlePush()
+	}
+	return true
+}
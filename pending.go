@@ -0,0 +1,196 @@
+package client
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// pendingShards is the number of pending request shards, a power of
+	// two so the shard of a mid is a mask. Sequential mids spread round
+	// robin over the shards.
+	pendingShards = 256
+
+	// pendingSlots is the initial slot table size of a shard
+	pendingSlots = 16
+)
+
+type (
+	// pendingMap holds the pending requests by mid, sharded so the many
+	// goroutines sending requests and the read loop completing them do
+	// not contend on a single lock.
+	pendingMap struct {
+		shards [pendingShards]pendingShard
+		n      int64 // pending requests, atomic, read without the shards
+	}
+
+	// pendingShard indexes its requests in a ring of slots by mid, mids
+	// being allocated sequentially a window of in-flight requests maps to
+	// distinct slots without hashing. The ring doubles when it is half
+	// full, a request colliding below that, e.g. behind a straggler one
+	// ring older than the others, goes to the overflow map.
+	pendingShard struct {
+		sync.Mutex
+		slots    []*pendingRequest
+		overflow map[uint]*pendingRequest
+		n        int
+		_        [16]byte // one cache line per shard, no false sharing
+	}
+)
+
+func newPendingMap() *pendingMap {
+	p := &pendingMap{}
+	for i := range p.shards {
+		p.shards[i].slots = make([]*pendingRequest, pendingSlots)
+	}
+	return p
+}
+
+func (p *pendingMap) shard(mid uint) *pendingShard {
+	return &p.shards[mid&(pendingShards-1)]
+}
+
+func (s *pendingShard) slot(mid uint) **pendingRequest {
+	return &s.slots[(mid/pendingShards)&uint(len(s.slots)-1)]
+}
+
+// lookup must be called with the shard locked
+func (s *pendingShard) lookup(mid uint) (*pendingRequest, bool) {
+	if pr := *s.slot(mid); pr != nil && pr.mid == mid {
+		return pr, true
+	}
+	pr, ok := s.overflow[mid]
+	return pr, ok
+}
+
+// insert must be called with the shard locked, the mid of pr must not
+// be pending
+func (s *pendingShard) insert(pr *pendingRequest) {
+	slot := s.slot(pr.mid)
+	switch {
+	case *slot == nil:
+		*slot = pr
+	case 2*s.n >= len(s.slots):
+		s.resize(2 * len(s.slots))
+		s.insert(pr)
+		return
+	default:
+		if s.overflow == nil {
+			s.overflow = map[uint]*pendingRequest{}
+		}
+		s.overflow[pr.mid] = pr
+	}
+	s.n++
+}
+
+// delete must be called with the shard locked, pr must be pending. The
+// ring shrinks back once mostly empty, e.g. after a load burst.
+func (s *pendingShard) delete(pr *pendingRequest) {
+	if slot := s.slot(pr.mid); *slot == pr {
+		*slot = nil
+	} else {
+		delete(s.overflow, pr.mid)
+	}
+	s.n--
+	if len(s.slots) > pendingSlots && 8*s.n < len(s.slots) {
+		s.resize(len(s.slots) / 2)
+	}
+}
+
+// resize moves the requests to a ring of size slots
+func (s *pendingShard) resize(size int) {
+	old, overflow := s.slots, s.overflow
+	s.slots = make([]*pendingRequest, size)
+	s.overflow = nil
+	s.n = 0
+	for _, pr := range old {
+		if pr != nil {
+			s.insert(pr)
+		}
+	}
+	for _, pr := range overflow {
+		s.insert(pr)
+	}
+}
+
+func (s *pendingShard) each(fn func(pr *pendingRequest)) {
+	for _, pr := range s.slots {
+		if pr != nil {
+			fn(pr)
+		}
+	}
+	for _, pr := range s.overflow {
+		fn(pr)
+	}
+}
+
+// add adds pr unless its mid is already pending, e.g. after the mids
+// wrapped, it reports whether it was added
+func (p *pendingMap) add(pr *pendingRequest) bool {
+	s := p.shard(pr.mid)
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.lookup(pr.mid); ok {
+		return false
+	}
+	s.insert(pr)
+	atomic.AddInt64(&p.n, 1)
+	return true
+}
+
+func (p *pendingMap) get(mid uint) (*pendingRequest, bool) {
+	s := p.shard(mid)
+	s.Lock()
+	defer s.Unlock()
+
+	return s.lookup(mid)
+}
+
+// take removes the request of mid and returns it
+func (p *pendingMap) take(mid uint) (*pendingRequest, bool) {
+	s := p.shard(mid)
+	s.Lock()
+	defer s.Unlock()
+
+	pr, ok := s.lookup(mid)
+	if ok {
+		s.delete(pr)
+		atomic.AddInt64(&p.n, -1)
+	}
+	return pr, ok
+}
+
+// remove removes pr if it is still pending, it reports whether it was
+func (p *pendingMap) remove(pr *pendingRequest) bool {
+	s := p.shard(pr.mid)
+	s.Lock()
+	defer s.Unlock()
+
+	if cur, ok := s.lookup(pr.mid); !ok || cur != pr {
+		return false
+	}
+	s.delete(pr)
+	atomic.AddInt64(&p.n, -1)
+	return true
+}
+
+// len returns the number of pending requests, it runs on every request
+// and response so it reads the counter rather than the shards
+func (p *pendingMap) len() int {
+	return int(atomic.LoadInt64(&p.n))
+}
+
+// all returns a snapshot of the pending requests
+func (p *pendingMap) all() []*pendingRequest {
+	var all []*pendingRequest
+	for i := range p.shards {
+		s := &p.shards[i]
+		s.Lock()
+		s.each(func(pr *pendingRequest) {
+			all = append(all, pr)
+		})
+		s.Unlock()
+	}
+	return all
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPendingMap(t *testing.T) {
+	p := newPendingMap()
+	a := &pendingRequest{mid: 7}
+	if !p.add(a) {
+		t.Fatal("add refused")
+	}
+	if p.add(&pendingRequest{mid: 7}) {
+		t.Fatal("pending mid added twice")
+	}
+	if pr, ok := p.get(7); !ok || pr != a {
+		t.Fatalf("get 7 = %v, %v", pr, ok)
+	}
+	if p.remove(&pendingRequest{mid: 7}) {
+		t.Fatal("removed another request of the mid")
+	}
+	if pr, ok := p.take(7); !ok || pr != a {
+		t.Fatalf("take 7 = %v, %v", pr, ok)
+	}
+	if _, ok := p.take(7); ok {
+		t.Fatal("taken twice")
+	}
+	if p.remove(a) {
+		t.Fatal("removed a completed request")
+	}
+	if n := p.len(); n != 0 {
+		t.Fatalf("len %d", n)
+	}
+}
+
+// TestPendingMapConcurrent adds and completes requests from many
+// goroutines, as senders and the read loop do, run it with -race
+func TestPendingMapConcurrent(t *testing.T) {
+	p := newPendingMap()
+	var (
+		mid uint64
+		wg  sync.WaitGroup
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var kept []*pendingRequest
+			for i := 0; i < 2000; i++ {
+				pr := &pendingRequest{mid: uint(atomic.AddUint64(&mid, 1))}
+				if !p.add(pr) {
+					t.Error("fresh mid refused")
+					return
+				}
+				if i%4 == 0 {
+					kept = append(kept, pr)
+					continue
+				}
+				if got, ok := p.take(pr.mid); !ok || got != pr {
+					t.Error("request lost")
+					return
+				}
+			}
+			for _, pr := range kept {
+				if !p.remove(pr) {
+					t.Error("kept request lost")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n, all := p.len(), len(p.all()); n != 0 || all != 0 {
+		t.Fatalf("len %d, all %d after completing everything", n, all)
+	}
+}
+
+func TestPendingRingResize(t *testing.T) {
+	p := newPendingMap()
+	s := p.shard(0)
+	// mids of shard 0, more than the initial ring
+	var prs []*pendingRequest
+	for i := 0; i < 4*pendingSlots; i++ {
+		pr := &pendingRequest{mid: uint(i * pendingShards)}
+		if !p.add(pr) {
+			t.Fatalf("mid %d refused", pr.mid)
+		}
+		prs = append(prs, pr)
+	}
+	if len(s.slots) < 4*pendingSlots || len(s.overflow) != 0 {
+		t.Fatalf("ring of %d slots, %d overflowing", len(s.slots), len(s.overflow))
+	}
+
+	for _, pr := range prs[1:] {
+		if got, ok := p.take(pr.mid); !ok || got != pr {
+			t.Fatalf("mid %d lost", pr.mid)
+		}
+	}
+	if len(s.slots) != pendingSlots {
+		t.Fatalf("ring of %d slots once empty, want %d", len(s.slots), pendingSlots)
+	}
+	if got, ok := p.get(prs[0].mid); !ok || got != prs[0] {
+		t.Fatal("request lost by the shrink")
+	}
+}
+
+func TestPendingRingStraggler(t *testing.T) {
+	p := newPendingMap()
+	s := p.shard(0)
+	straggler := &pendingRequest{mid: 0}
+	p.add(straggler)
+
+	// one ring later the slot of the straggler is taken again
+	next := &pendingRequest{mid: uint(pendingSlots * pendingShards)}
+	if !p.add(next) {
+		t.Fatal("colliding mid refused")
+	}
+	if len(s.overflow) != 1 {
+		t.Fatalf("%d overflowing, want 1", len(s.overflow))
+	}
+	for _, pr := range []*pendingRequest{straggler, next} {
+		if got, ok := p.take(pr.mid); !ok || got != pr {
+			t.Fatalf("mid %d lost", pr.mid)
+		}
+	}
+}
+
+func TestMidWrap(t *testing.T) {
+	c := NewConnector()
+	c.mid = math.MaxUint64
+	if mid := c.nextMid(); mid != math.MaxUint {
+		t.Fatalf("mid %d, want the largest", mid)
+	}
+	// 0 is skipped, and 1 is still pending
+	c.responses.add(&pendingRequest{mid: 1})
+	pr := &pendingRequest{mid: c.nextMid()}
+	c.register(pr)
+	if pr.mid != 2 {
+		t.Fatalf("mid %d after the wrap, want 2", pr.mid)
+	}
+}
+
+// Measured with go test -bench Pending on a 1 core x86-64 VM, against
+// the previous 32 shards of Go maps:
+//
+//	outstanding=1024      115 ns/op   (was 157 ns/op)
+//	outstanding=65536     172 ns/op   (was 318 ns/op)
+//	outstanding=1048576   183 ns/op   (was 624 ns/op)
+
+// BenchmarkPending measures a request round trip through the pending
+// map, add then take, with outstanding requests already waiting, as in a
+// load generator keeping a large window in flight.
+func BenchmarkPending(b *testing.B) {
+	for _, outstanding := range []int{1 << 10, 1 << 16, 1 << 20} {
+		b.Run(fmt.Sprintf("outstanding=%d", outstanding), func(b *testing.B) {
+			p := newPendingMap()
+			var mid uint64
+			for i := 0; i < outstanding; i++ {
+				p.add(&pendingRequest{mid: uint(mid)})
+				mid++
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					pr := &pendingRequest{mid: uint(atomic.AddUint64(&mid, 1))}
+					if !p.add(pr) {
+						b.Fatal("mid already pending")
+					}
+					if _, ok := p.take(pr.mid); !ok {
+						b.Fatal("request not pending")
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkRequest measures the whole request path on a loopback echo
+// server: send, pending map, pending gauge, payload stats, response
+// dispatch and latency stats, from parallel senders.
+func BenchmarkRequest(b *testing.B) {
+	s := newTestServer(b, nil)
+	c := newTestConnector(b)
+	runConnector(b, c, s.addr())
+	s.next()
+	payload := []byte(`{"n":1}`)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := call(b, c, "echo", payload); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"sort"
+	"time"
+)
+
+// PendingInfo describes a request waiting for its response
+type PendingInfo struct {
+	Mid      uint
+	Route    string
+	Age      time.Duration // since the request was sent
+	Deadline time.Time     // zero without timeout
+	Tags     []string
+	Queued   bool // waiting to be replayed after a reconnect
+}
+
+// PendingRequests returns the requests waiting for a response, or to be
+// replayed, by ascending mid
+func (c *Connector) PendingRequests() []PendingInfo {
+	now := c.clock.Now()
+	info := func(pr *pendingRequest, queued bool) PendingInfo {
+		return PendingInfo{
+			Mid:      pr.mid,
+			Route:    pr.route,
+			Age:      now.Sub(pr.sentAt),
+			Deadline: pr.deadline,
+			Tags:     append([]string(nil), pr.tags...),
+			Queued:   queued,
+		}
+	}
+
+	var infos []PendingInfo
+	for _, pr := range c.responses.all() {
+		infos = append(infos, info(pr, false))
+	}
+	c.muResponses.RLock()
+	for _, pr := range c.replayQueue {
+		infos = append(infos, info(pr, true))
+	}
+	c.muResponses.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Mid < infos[j].Mid })
+	return infos
+}
+
+// PruneOlderThan fails the requests sent more than d ago with
+// ErrRequestPruned, e.g. requests without timeout the server will never
+// answer, and returns how many were pruned. A late response is then
+// reported as an orphan.
+func (c *Connector) PruneOlderThan(d time.Duration) int {
+	now := c.clock.Now()
+	var stale []*pendingRequest
+	for _, pr := range c.responses.all() {
+		if now.Sub(pr.sentAt) > d {
+			stale = append(stale, pr)
+		}
+	}
+	c.muResponses.RLock()
+	for _, pr := range c.replayQueue {
+		if now.Sub(pr.sentAt) > d {
+			stale = append(stale, pr)
+		}
+	}
+	c.muResponses.RUnlock()
+
+	n := 0
+	for _, pr := range stale {
+		if c.responses.remove(pr) || c.unqueue(pr) {
+			c.completeFailed(pr, ErrRequestPruned)
+			n++
+		}
+	}
+	if n > 0 {
+		c.logInfo("stale requests pruned", Field{"age", d}, Field{"count", n})
+	}
+	return n
+}
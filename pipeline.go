@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+
+	"github.com/revzim/go-pomelo-client/codec"
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+// PipelineOrder orders the payload compression and the packet encryption
+// when both are used
+type PipelineOrder int
+
+const (
+	// CompressThenEncrypt compresses the message payload, flagged in the
+	// message header, then encrypts the packet body. It is the default.
+	CompressThenEncrypt PipelineOrder = iota
+	// EncryptThenCompress encrypts the packet body then compresses it as
+	// a whole, the message header compression flag is not used.
+	EncryptThenCompress
+)
+
+// SetPipelineOrder sets the order of compression and encryption of the
+// data packets sent and received, to match the server. It only matters
+// when a compressor is negotiated and a packet cipher is set. It must be
+// called before Run.
+func (c *Connector) SetPipelineOrder(send, receive PipelineOrder) {
+	c.sendOrder, c.recvOrder = send, receive
+	c.codec.SetCipher(c.decoderCipher())
+}
+
+// decoderCipher returns the cipher of the decoder, the data packets are
+// left encrypted when they must be decompressed first
+func (c *Connector) decoderCipher() codec.PacketCipher {
+	if c.cipher == nil || c.recvOrder != EncryptThenCompress {
+		return c.cipher
+	}
+	return dataPassthrough{c.cipher}
+}
+
+// dataPassthrough is a cipher leaving the data packets alone
+type dataPassthrough struct {
+	codec.PacketCipher
+}
+
+func (d dataPassthrough) Decrypt(typ byte, body []byte) ([]byte, error) {
+	if typ == packet.Data {
+		return body, nil
+	}
+	return d.PacketCipher.Decrypt(typ, body)
+}
+
+// sealsPackets reports whether sent data packets are encrypted then
+// compressed as a whole
+func (c *Connector) sealsPackets() bool {
+	return c.sendOrder == EncryptThenCompress && c.activeCompressor() != nil
+}
+
+// sendSealedCtx encrypts then compresses the encoded message and queues
+// its data packet
+func (c *Connector) sendSealedCtx(ctx context.Context, body []byte, done chan error) error {
+	var err error
+	if c.cipher != nil {
+		if body, err = c.cipher.Encrypt(packet.Data, body); err != nil {
+			return err
+		}
+	}
+	if body, err = c.activeCompressor().Compress(body); err != nil {
+		return err
+	}
+	payload, err := codec.Encode(packet.Data, body)
+	if err != nil {
+		return err
+	}
+	return c.enqueue(ctx, outbound{data: payload, done: done})
+}
+
+// openPacket decompresses then decrypts a data packet body received in
+// the EncryptThenCompress order
+func (c *Connector) openPacket(body []byte) ([]byte, error) {
+	if c.recvOrder != EncryptThenCompress {
+		return body, nil
+	}
+	var err error
+	if comp := c.activeCompressor(); comp != nil {
+		if body, err = comp.Decompress(body); err != nil {
+			return nil, err
+		}
+	}
+	if c.cipher != nil {
+		if body, err = c.cipher.Decrypt(packet.Data, body); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"github.com/revzim/go-pomelo-client/message"
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+// Plugin extends a connector with hooks on its lifecycle, e.g. metrics,
+// tracing or recording distributed as a separate module. A plugin also
+// implementing Middleware or ConnMiddleware is added as such by Use.
+// The hooks run on the goroutine raising them, the read loop for most,
+// and must not block. Embed PluginBase to implement only some of them.
+type Plugin interface {
+	// OnInit is called once by Use, and again with every clone made by
+	// CloneConfig, an error rejects the plugin
+	OnInit(c *Connector) error
+	// OnConnect is called when a connection is ready, after the
+	// handshake, as an EventHandshakeComplete subscriber
+	OnConnect(c *Connector)
+	// OnPacket is called for every inbound packet before it is processed
+	OnPacket(p *packet.Packet)
+	// OnMessage is called for every inbound message once decoded and
+	// decompressed, before the middlewares and handlers
+	OnMessage(msg *message.Message)
+	// OnClose is called when a connection is closed, as an
+	// EventDisconnected subscriber, err is the error which closed it, if
+	// any
+	OnClose(reason DisconnectReason, err error)
+}
+
+// PluginBase implements Plugin with no-op hooks
+type PluginBase struct{}
+
+// OnInit --
+func (PluginBase) OnInit(c *Connector) error { return nil }
+
+// OnConnect --
+func (PluginBase) OnConnect(c *Connector) {}
+
+// OnPacket --
+func (PluginBase) OnPacket(p *packet.Packet) {}
+
+// OnMessage --
+func (PluginBase) OnMessage(msg *message.Message) {}
+
+// OnClose --
+func (PluginBase) OnClose(reason DisconnectReason, err error) {}
+
+// Use initializes and registers plugins in order, it stops at the first
+// OnInit error. Plugins must be registered before Run, CloneConfig
+// initializes them again on the clone.
+func (c *Connector) Use(plugins ...Plugin) error {
+	for _, p := range plugins {
+		if err := p.OnInit(c); err != nil {
+			return err
+		}
+		if mw, ok := p.(Middleware); ok {
+			c.AddMiddleware(mw)
+		}
+		if mw, ok := p.(ConnMiddleware); ok {
+			c.UseConnMiddleware(mw)
+		}
+		c.subscribePlugin(p)
+		c.plugins = append(c.plugins, p)
+	}
+	return nil
+}
+
+// subscribePlugin routes the connection events of the bus to the hooks
+// of p
+func (c *Connector) subscribePlugin(p Plugin) {
+	c.Subscribe(func(ev LifecycleEvent) {
+		if ev.Kind == EventHandshakeComplete {
+			p.OnConnect(c)
+			return
+		}
+		p.OnClose(ev.Reason, ev.Err)
+	}, EventHandshakeComplete, EventDisconnected)
+}
+
+func (c *Connector) pluginsPacket(pkt *packet.Packet) {
+	for _, p := range c.plugins {
+		p.OnPacket(pkt)
+	}
+}
+
+func (c *Connector) pluginsMessage(msg *message.Message) {
+	for _, p := range c.plugins {
+		p.OnMessage(msg)
+	}
+}
@@ -0,0 +1,70 @@
+package client
+
+import "sync"
+
+// pollQueue holds the callbacks waiting for Poll
+type pollQueue struct {
+	mu    sync.Mutex
+	queue []func()
+}
+
+// SetPollDispatch switches to polled dispatch: push handlers, response
+// callbacks and request error handlers are queued instead of running on
+// the read loop, and run when the application calls Poll, e.g. once per
+// frame from a single threaded game loop. Blocking requests (Call,
+// RequestProto, RequestStream) are still completed by the read loop. It
+// must be set before Run.
+func (c *Connector) SetPollDispatch(on bool) {
+	c.pollDispatch = on
+}
+
+// Poll runs at most maxN queued callbacks on the calling goroutine, all
+// of them when maxN <= 0, in arrival order, and returns how many ran.
+// Callbacks queued while polling wait for the next call.
+func (c *Connector) Poll(maxN int) int {
+	q := &c.polled
+	q.mu.Lock()
+	n := len(q.queue)
+	if maxN > 0 && maxN < n {
+		n = maxN
+	}
+	batch := make([]func(), n)
+	copy(batch, q.queue)
+	for i := 0; i < n; i++ {
+		q.queue[i] = nil
+	}
+	q.queue = q.queue[n:]
+	q.mu.Unlock()
+
+	for _, fn := range batch {
+		fn()
+	}
+	return n
+}
+
+// Polled returns the number of callbacks waiting for Poll
+func (c *Connector) Polled() int {
+	c.polled.mu.Lock()
+	defer c.polled.mu.Unlock()
+
+	return len(c.polled.queue)
+}
+
+// withDirect delivers the response on the read loop, for the callers
+// waiting for it, e.g. Call, which would otherwise wait for Poll
+func withDirect() RequestOption {
+	return func(o *requestOptions) {
+		o.direct = true
+	}
+}
+
+// dispatch runs fn now, or queues it for Poll in polled dispatch
+func (c *Connector) dispatch(fn func()) {
+	if !c.pollDispatch {
+		fn()
+		return
+	}
+	c.polled.mu.Lock()
+	c.polled.queue = append(c.polled.queue, fn)
+	c.polled.mu.Unlock()
+}
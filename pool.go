@@ -0,0 +1,130 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// Pool is a set of connectors used together, e.g. one per region or
+	// one per test account. The pool does not run the connectors.
+	Pool struct {
+		mu         sync.RWMutex
+		conns      []*Connector
+		identities IdentityProvider // given to the added connectors
+	}
+
+	// Result is the outcome of a fanned out request on one connector
+	Result struct {
+		Conn    *Connector
+		Data    []byte        // response body, nil on error
+		Err     error         // send or response error
+		Latency time.Duration // time to the response or the error
+	}
+)
+
+// NewPool returns a pool of conns
+func NewPool(conns ...*Connector) *Pool {
+	return &Pool{conns: append([]*Connector(nil), conns...)}
+}
+
+// Add adds c, it is given the identity provider of the pool if any
+func (p *Pool) Add(c *Connector) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.identities != nil {
+		c.SetIdentityProvider(p.identities)
+	}
+	p.conns = append(p.conns, c)
+}
+
+// SetIdentityProvider shares ip between the pooled connectors and the
+// ones added later, so each connection authenticates as a distinct
+// account, e.g. with an IdentityRotation of test accounts. It must be
+// set before the connectors are run.
+func (p *Pool) SetIdentityProvider(ip IdentityProvider) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.identities = ip
+	for _, c := range p.conns {
+		c.SetIdentityProvider(ip)
+	}
+}
+
+// Remove removes c from the pool without closing it
+func (p *Pool) Remove(c *Connector) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, conn := range p.conns {
+		if conn == c {
+			p.conns = append(p.conns[:i:i], p.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+// Conns returns a copy of the pooled connectors
+func (p *Pool) Conns() []*Connector {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return append([]*Connector(nil), p.conns...)
+}
+
+// Len --
+func (p *Pool) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return len(p.conns)
+}
+
+// Close closes every pooled connector, it returns the first close error
+func (p *Pool) Close() error {
+	var first error
+	for _, c := range p.Conns() {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Broadcast notifies route on every pooled connector, the results are
+// in pool order and carry the write error of each connector.
+func (p *Pool) Broadcast(route string, data []byte) []Result {
+	return p.fanOut(func(c *Connector) ([]byte, error) {
+		return nil, c.NotifySync(route, data)
+	})
+}
+
+// RequestAll sends the same request on every pooled connector and waits
+// for all the responses or errors, the results are in pool order. Use
+// WithTimeout to bound the wait of unanswered requests.
+func (p *Pool) RequestAll(route string, data []byte, opts ...RequestOption) []Result {
+	return p.fanOut(func(c *Connector) ([]byte, error) {
+		return c.requestSync(route, data, opts...)
+	})
+}
+
+func (p *Pool) fanOut(fn func(c *Connector) ([]byte, error)) []Result {
+	conns := p.Conns()
+	results := make([]Result, len(conns))
+
+	var wg sync.WaitGroup
+	wg.Add(len(conns))
+	for i, c := range conns {
+		go func(i int, c *Connector) {
+			defer wg.Done()
+
+			start := time.Now()
+			data, err := fn(c)
+			results[i] = Result{Conn: c, Data: data, Err: err, Latency: time.Since(start)}
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,85 @@
+package client
+
+import "net"
+
+// preconnect is a connection dialed ahead of Run
+type preconnect struct {
+	addr   string
+	done   chan struct{} // closed once dialed
+	conn   net.Conn
+	err    error
+	phases ConnectReport
+}
+
+// Preconnect dials addr in the background, TLS handshake included, so the
+// next Run(addr) starts from a warm connection, e.g. while the player is
+// still on the title screen. Run falls back to a fresh dial if the warm
+// dial failed, a warm connection to another address is closed. The server
+// may drop a connection left idle too long before Run.
+func (c *Connector) Preconnect(addr string) {
+	p := &preconnect{addr: addr, done: make(chan struct{})}
+
+	c.muConn.Lock()
+	if c.isDeadLocked() {
+		c.muConn.Unlock()
+		return
+	}
+	old := c.preconn
+	c.preconn = p
+	c.muConn.Unlock()
+	old.discard()
+
+	go func() {
+		c.labelGoroutine()
+		p.conn, p.err = c.dial(addr, &p.phases)
+		close(p.done)
+	}()
+}
+
+// preconnected returns the warm connection to addr, nil when there is
+// none or its dial failed
+func (c *Connector) preconnected(addr string, phases *ConnectReport) net.Conn {
+	c.muConn.Lock()
+	p := c.preconn
+	c.preconn = nil
+	c.muConn.Unlock()
+
+	if p == nil {
+		return nil
+	}
+	if p.addr != addr {
+		p.discard()
+		return nil
+	}
+
+	<-p.done
+	if p.err != nil {
+		c.logWarn("preconnect failed, dialing again", Field{"addr", addr}, Field{"error", p.err})
+		return nil
+	}
+	*phases = p.phases
+	return p.conn
+}
+
+// dropPreconnect closes the warm connection, if any
+func (c *Connector) dropPreconnect() {
+	c.muConn.Lock()
+	p := c.preconn
+	c.preconn = nil
+	c.muConn.Unlock()
+
+	p.discard()
+}
+
+// discard closes the connection once dialed, without waiting for it
+func (p *preconnect) discard() {
+	if p == nil {
+		return
+	}
+	go func() {
+		<-p.done
+		if p.conn != nil {
+			p.conn.Close()
+		}
+	}()
+}
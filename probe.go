@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ProbeResult is the outcome of probing one endpoint
+type ProbeResult struct {
+	Addr    string
+	Latency time.Duration // handshake round trip, zero on error
+	Connect time.Duration // Run to ready, dial and TLS included
+	Report  ConnectReport
+	Err     error
+}
+
+// ProbeEndpoints concurrently connects to every address with the
+// transport and handshake configuration of c, measures the handshake and
+// closes the connection, so the best region can be picked before the real
+// connect. Handlers, connect script and Connected callback of c are not
+// used. The results are in addrs order, an endpoint not ready once ctx is
+// done fails with the context error.
+func (c *Connector) ProbeEndpoints(ctx context.Context, addrs []string) []ProbeResult {
+	results := make([]ProbeResult, len(addrs))
+
+	var wg sync.WaitGroup
+	wg.Add(len(addrs))
+	for i, addr := range addrs {
+		go func(i int, addr string) {
+			defer wg.Done()
+			results[i] = c.probe(ctx, addr)
+		}(i, addr)
+	}
+	wg.Wait()
+	return results
+}
+
+// FastestEndpoint returns the successful result with the lowest latency,
+// false if every probe failed
+func FastestEndpoint(results []ProbeResult) (ProbeResult, bool) {
+	var best ProbeResult
+	found := false
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		if !found || r.Latency < best.Latency {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+func (c *Connector) probe(ctx context.Context, addr string) ProbeResult {
+	p := c.probeConnector()
+	defer p.Close()
+
+	start := c.clock.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.Run(addr)
+	}()
+
+	r := ProbeResult{Addr: addr}
+	select {
+	case <-p.Ready():
+		r.Connect = c.clock.Since(start)
+	case err := <-errCh:
+		r.Err = err
+	case <-ctx.Done():
+		r.Err = ctx.Err()
+	}
+	r.Report = p.ConnectReport()
+	if r.Err == nil {
+		r.Latency = r.Report.Handshake
+	}
+	return r
+}
+
+// probeConnector returns a connector with the transport and handshake
+// configuration of c only
+func (c *Connector) probeConnector() *Connector {
+	n := NewConnector()
+
+	n.handshakeData = c.handshakeData
+	n.handshakeVersion = c.handshakeVersion
+	n.handshakeAckData = c.handshakeAckData
+	n.handshakeAckBuilder = c.handshakeAckBuilder
+	n.handshakeGzip = c.handshakeGzip
+	n.heartbeatData = c.heartbeatData
+	n.SetPipelineOrder(c.sendOrder, c.recvOrder)
+	n.SetPacketCipher(c.cipher)
+	n.SetClock(c.clock)
+	n.logger = c.logger
+	n.msgCompat = c.msgCompat
+	n.wsOrigin = c.wsOrigin
+	n.wsProtocols = c.wsProtocols
+	n.tlsConfig = c.tlsConfig
+	n.tlsSessions = c.tlsSessions
+	n.compressors = c.compressors
+	n.connMiddlewares = c.connMiddlewares
+	n.name = c.name
+
+	c.RLock()
+	for scheme, t := range c.transports {
+		n.transports[scheme] = t
+	}
+	c.RUnlock()
+	return n
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/codec"
+	"github.com/revzim/go-pomelo-client/message"
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+func TestReadResyncsOnMalformedFrame(t *testing.T) {
+	s := newTestServer(t, nil)
+	c := newTestConnector(t)
+	pushes := make(chan string, 4)
+	c.On("chat", func(data []byte) { pushes <- string(data) })
+	runConnector(t, c, s.addr())
+	sc := s.next()
+
+	data, _ := message.Encode(&message.Message{Type: message.Push, Route: "chat", Data: []byte(`{"n":2}`)})
+	push, _ := codec.Encode(packet.Data, data)
+	sc.push("chat", []byte(`{"n":1}`))
+	sc.write(append([]byte{0x09, 0xFF, 0xFF, 0xFF, 0x09}, push...))
+
+	for _, want := range []string{`{"n":1}`, `{"n":2}`} {
+		select {
+		case got := <-pushes:
+			if got != want {
+				t.Fatalf("push %s, want %s", got, want)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("push %s lost", want)
+		}
+	}
+	if c.IsClosed() {
+		t.Fatal("connection closed after a resync")
+	}
+}
+
+func TestReadDesyncCloses(t *testing.T) {
+	s := newTestServer(t, nil)
+	c := newTestConnector(t)
+	errCh := runConnector(t, c, s.addr())
+	sc := s.next()
+
+	sc.write(bytes.Repeat([]byte{0x09}, 16))
+	select {
+	case err := <-errCh:
+		if err != ErrProtocolDesync {
+			t.Fatalf("run: %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("desynchronized connection kept")
+	}
+	if n := c.Stats().Disconnects[DisconnectProtocolError]; n != 1 {
+		t.Fatalf("protocol error disconnects = %d", n)
+	}
+}
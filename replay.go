@@ -0,0 +1,52 @@
+package client
+
+// replayBuffer keeps the last pushes of every route which arrived before
+// a handler was registered.
+type replayBuffer struct {
+	size   int
+	pushes map[string][][]byte
+}
+
+func (r *replayBuffer) add(route string, data []byte) {
+	q := append(r.pushes[route], cloneBytes(data))
+	if len(q) > r.size {
+		q = q[len(q)-r.size:]
+	}
+	r.pushes[route] = q
+}
+
+func (r *replayBuffer) take(route string) [][]byte {
+	q := r.pushes[route]
+	delete(r.pushes, route)
+	return q
+}
+
+// SetPushReplay buffers the last n pushes of every route without handler,
+// they are replayed to the callback registered later via On. Replayed
+// pushes are delivered on the goroutine calling On. n <= 0 disables it.
+func (c *Connector) SetPushReplay(n int) {
+	c.Lock()
+	defer c.Unlock()
+
+	if n <= 0 {
+		c.replay = nil
+		return
+	}
+	c.replay = &replayBuffer{size: n, pushes: map[string][][]byte{}}
+}
+
+// bufferPush stores an unhandled push for later replay, the handler is
+// looked up again under the write lock so a concurrent On never misses it.
+func (c *Connector) bufferPush(route string, data []byte) (cb Callback, buffered bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if cb, ok := c.events[route]; ok {
+		return cb, false
+	}
+	if c.replay == nil {
+		return nil, false
+	}
+	c.replay.add(route, data)
+	return nil, true
+}
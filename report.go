@@ -0,0 +1,138 @@
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ConnectReport describes the last connection attempt of Run, phases not
+// reached or not measurable for the transport are zero. Custom transports
+// and websockets only report their whole Dial.
+type ConnectReport struct {
+	Addr      string
+	Transport string    // address scheme
+	StartedAt time.Time // Run call
+	DNS       time.Duration
+	Dial      time.Duration // connect, DNS excluded when measured
+	TLS       time.Duration
+	Resumed   bool          // the TLS session was resumed
+	Handshake time.Duration // handshake sent to handshake response
+	Total     time.Duration // Run call to Ready
+	Err       error         // reason the attempt failed, nil on success
+
+	// negotiated features
+	ServerVersion string
+	Heartbeat     time.Duration
+	Compression   string
+	Dictionary    int  // routes of the handshake dictionary
+	Protos        bool // the server announced protobuf routes
+
+	sentAt time.Time // handshake sent
+}
+
+// ConnectReport returns the report of the last connection attempt, the
+// zero report before the first Run.
+func (c *Connector) ConnectReport() ConnectReport {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	if c.report == nil {
+		return ConnectReport{}
+	}
+	return *c.report
+}
+
+// beginReport starts the report of a new connection attempt
+func (c *Connector) beginReport(addr string) {
+	scheme, _ := splitScheme(addr)
+	r := &ConnectReport{Addr: addr, Transport: scheme, StartedAt: c.clock.Now()}
+
+	c.muConn.Lock()
+	c.report = r
+	c.muConn.Unlock()
+}
+
+// updateReport applies fn to the report of the current attempt
+func (c *Connector) updateReport(fn func(r *ConnectReport)) {
+	c.muConn.Lock()
+	defer c.muConn.Unlock()
+
+	if c.report != nil {
+		fn(c.report)
+	}
+}
+
+// failReport records the error of the current attempt unless it is done
+func (c *Connector) failReport(err error) {
+	c.updateReport(func(r *ConnectReport) {
+		if r.Err == nil && r.Total == 0 {
+			r.Err = err
+		}
+	})
+}
+
+// DefaultDialTimeout bounds the resolution and connection of a tcp dial
+const DefaultDialTimeout = 10 * time.Second
+
+// SetDialTimeout bounds the resolution and connection of the tcp dials,
+// TLS and websocket included, DefaultDialTimeout by default, zero is no
+// timeout. It must be set before Run.
+func (c *Connector) SetDialTimeout(d time.Duration) {
+	c.dialTimeout = d
+}
+
+// dialTCP connects host with a net.Dialer, racing the IPv4 and IPv6
+// addresses as it does. The resolution ends when the first address is
+// dialed, the Control hook splits the phases in r.
+func (c *Connector) dialTCP(host string, r *ConnectReport) (net.Conn, error) {
+	var (
+		once     sync.Once
+		resolved time.Time
+	)
+	d := net.Dialer{
+		Timeout: c.dialTimeout,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			once.Do(func() { resolved = c.clock.Now() })
+			return nil
+		},
+	}
+
+	start := c.clock.Now()
+	conn, err := d.Dial("tcp", host)
+	end := c.clock.Now()
+	// no address dialed when the resolution failed
+	once.Do(func() { resolved = end })
+	r.DNS = resolved.Sub(start)
+	r.Dial = end.Sub(resolved)
+	return conn, err
+}
+
+// dialTLS is dialTCP followed by a timed TLS handshake
+func (c *Connector) dialTLS(host string, r *ConnectReport) (net.Conn, error) {
+	conn, err := c.dialTCP(host, r)
+	if err != nil {
+		return nil, err
+	}
+
+	config := c.clientTLSConfig()
+	if config.ServerName == "" {
+		// like tls.Dial
+		name, _, _ := net.SplitHostPort(host)
+		config = config.Clone()
+		config.ServerName = name
+	}
+
+	start := c.clock.Now()
+	tc := tls.Client(conn, config)
+	err = tc.Handshake()
+	r.TLS = c.clock.Since(start)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r.Resumed = tc.ConnectionState().DidResume
+	return tc, nil
+}
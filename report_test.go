@@ -0,0 +1,40 @@
+package client
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialTCPTimeout(t *testing.T) {
+	c := NewConnector()
+	c.SetDialTimeout(50 * time.Millisecond)
+
+	// a blackholed address, the SYN is never answered
+	start := time.Now()
+	var r ConnectReport
+	conn, err := c.dialTCP("10.255.255.1:9", &r)
+	if err == nil {
+		conn.Close()
+		t.Skip("no blackhole in this network")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("dial took %v past its timeout", elapsed)
+	}
+}
+
+func TestDialTCPReport(t *testing.T) {
+	s := newTestServer(t, nil)
+	c := NewConnector()
+
+	var r ConnectReport
+	_, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	conn, err := c.dialTCP(net.JoinHostPort("localhost", port), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if r.DNS < 0 || r.Dial < 0 {
+		t.Fatalf("phases %v %v", r.DNS, r.Dial)
+	}
+}
@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/clock"
+)
+
+type (
+	// RequestOption customizes a single Request
+	RequestOption func(*requestOptions)
+
+	requestOptions struct {
+		timeout    time.Duration
+		onError    func(err error)
+		ctx        context.Context
+		idempotent bool
+		sequence   string
+		tags       []string
+		stream     *ResponseStream
+		direct     bool // delivered on the read loop even with polled dispatch
+		probe      bool // let through a half-open circuit breaker
+	}
+
+	// pendingRequest is a request waiting for its response
+	pendingRequest struct {
+		mid        uint
+		route      string
+		data       []byte // kept for the replay policy only
+		cb         Callback
+		onError    func(err error)
+		sentAt     time.Time
+		deadline   time.Time // zero without timeout
+		timer      clock.Timer
+		limited    bool // counted in routeInflight
+		idempotent bool
+		slot       *orderedSlot // place in its ordered sequence
+		tags       []string
+		size       int    // payload bytes, kept for the exchange log
+		body       []byte // truncated payload, kept for the exchange log
+		stream     *ResponseStream
+		direct     bool
+		probe      bool // the half-open circuit breaker probe
+	}
+)
+
+// WithTimeout overrides the connector and route timeout for the request,
+// a negative value disables the timeout.
+func WithTimeout(d time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.timeout = d
+	}
+}
+
+// WithErrorHandler sets the callback invoked instead of the response
+// callback when the request fails, e.g. with ErrRequestTimeout or a
+// *ResponseError for registered error codes.
+func WithErrorHandler(fn func(err error)) RequestOption {
+	return func(o *requestOptions) {
+		o.onError = fn
+	}
+}
+
+// SetRequestTimeout sets the default response timeout of every request,
+// zero means requests wait forever.
+func (c *Connector) SetRequestTimeout(d time.Duration) {
+	c.muResponses.Lock()
+	defer c.muResponses.Unlock()
+
+	c.requestTimeout = d
+}
+
+// SetRouteTimeout overrides the default response timeout for route, zero
+// removes the override.
+func (c *Connector) SetRouteTimeout(route string, d time.Duration) {
+	c.muResponses.Lock()
+	defer c.muResponses.Unlock()
+
+	if d == 0 {
+		delete(c.routeTimeouts, route)
+		return
+	}
+	c.routeTimeouts[route] = d
+}
+
+// SetRouteMaxInflight limits the concurrent in-flight requests of route,
+// requests over the limit fail with ErrBulkheadFull. n <= 0 removes it.
+func (c *Connector) SetRouteMaxInflight(route string, n int) {
+	c.muResponses.Lock()
+	defer c.muResponses.Unlock()
+
+	if n <= 0 {
+		delete(c.routeLimits, route)
+		return
+	}
+	c.routeLimits[route] = n
+}
+
+// SetSlowRequestThreshold reports every response arriving after d, hook
+// is called with the route, mid and elapsed time, a nil hook logs a
+// warning instead. d <= 0 disables it.
+func (c *Connector) SetSlowRequestThreshold(d time.Duration, hook func(route string, mid uint, elapsed time.Duration)) {
+	c.slowThreshold = d
+	c.slowHook = hook
+}
+
+func (c *Connector) checkSlow(pr *pendingRequest) {
+	if c.slowThreshold <= 0 {
+		return
+	}
+	elapsed := c.clock.Since(pr.sentAt)
+	if elapsed < c.slowThreshold {
+		return
+	}
+	if c.slowHook != nil {
+		c.slowHook(pr.route, pr.mid, elapsed)
+		return
+	}
+	c.logWarn("slow request", Field{"route", pr.route}, Field{"mid", pr.mid}, Field{"elapsed", elapsed})
+}
+
+// OnOrphanResponse sets the hook called for responses whose request was
+// already completed, timed out or never sent, e.g. duplicated deliveries.
+// A nil hook logs a warning instead.
+func (c *Connector) OnOrphanResponse(hook func(mid uint, data []byte)) {
+	c.orphanHook = hook
+}
+
+func (c *Connector) orphanResponse(mid uint, data []byte) {
+	atomic.AddUint64(&c.orphans, 1)
+	c.metrics.IncrCounter(MetricOrphanResponses, 1)
+	if c.orphanHook != nil {
+		c.orphanHook(mid, data)
+		return
+	}
+	c.logWarn("response handler not found", Field{"mid", mid}, Field{"bytes", len(data)})
+}
+
+// timeoutFor must be called with muResponses held
+func (c *Connector) timeoutFor(route string, explicit time.Duration) time.Duration {
+	if explicit != 0 {
+		return explicit
+	}
+	if d, ok := c.routeTimeouts[route]; ok {
+		return d
+	}
+	return c.requestTimeout
+}
+
+// addPending allocates a message id and registers the pending request
+func (c *Connector) addPending(route string, data []byte, cb Callback, opts requestOptions) (*pendingRequest, error) {
+	c.muResponses.RLock()
+	limit, limited := c.routeLimits[route]
+	d := c.timeoutFor(route, opts.timeout)
+	c.muResponses.RUnlock()
+
+	if limited {
+		// only the bulkheaded routes take the exclusive lock
+		c.muResponses.Lock()
+		if c.routeInflight[route] >= limit {
+			c.muResponses.Unlock()
+			return nil, ErrBulkheadFull
+		}
+		c.routeInflight[route]++
+		c.muResponses.Unlock()
+	}
+
+	pr := &pendingRequest{
+		mid:        c.nextMid(),
+		route:      route,
+		cb:         cb,
+		onError:    opts.onError,
+		sentAt:     c.clock.Now(),
+		limited:    limited,
+		idempotent: opts.idempotent,
+		tags:       opts.tags,
+		stream:     opts.stream,
+		direct:     opts.direct,
+		probe:      opts.probe,
+	}
+	if c.keepsRequests() {
+		pr.data = data
+	}
+	c.keepExchange(pr, data)
+	if key := c.sequenceKey(route, opts); key != "" {
+		pr.slot = c.ordered.reserve(key)
+	}
+	c.register(pr)
+	if pr.stream != nil {
+		pr.stream.bind(pr)
+	}
+
+	if d > 0 {
+		pr.deadline = pr.sentAt.Add(d)
+		pr.timer = c.clock.AfterFunc(d, func() {
+			c.failPending(pr, ErrRequestTimeout)
+		})
+	}
+	return pr, nil
+}
+
+// nextMid allocates a message id, 0 is skipped when the ids wrap
+func (c *Connector) nextMid() uint {
+	for {
+		if mid := uint(atomic.AddUint64(&c.mid, 1) - 1); mid != 0 {
+			return mid
+		}
+	}
+}
+
+// register adds pr to the pending requests, a mid still pending after the
+// ids wrapped is skipped
+func (c *Connector) register(pr *pendingRequest) {
+	for !c.responses.add(pr) {
+		pr.mid = c.nextMid()
+	}
+}
+
+// released must be called once pr is removed from the pending requests
+func (c *Connector) released(pr *pendingRequest) {
+	if pr.timer != nil {
+		pr.timer.Stop()
+	}
+	c.leave()
+	if !pr.limited {
+		return
+	}
+
+	c.muResponses.Lock()
+	defer c.muResponses.Unlock()
+
+	if n := c.routeInflight[pr.route]; n > 1 {
+		c.routeInflight[pr.route] = n - 1
+	} else {
+		delete(c.routeInflight, pr.route)
+	}
+}
+
+// takePending removes and returns the pending request of mid
+func (c *Connector) takePending(mid uint) (*pendingRequest, bool) {
+	pr, ok := c.responses.take(mid)
+	if !ok {
+		return nil, false
+	}
+	c.released(pr)
+	return pr, true
+}
+
+// failPending completes pr with err if it is still pending or waiting to
+// be replayed
+func (c *Connector) failPending(pr *pendingRequest, err error) {
+	if !c.responses.remove(pr) && !c.unqueue(pr) {
+		return
+	}
+	c.completeFailed(pr, err)
+}
+
+// completeFailed completes pr, already removed, with err
+func (c *Connector) completeFailed(pr *pendingRequest, err error) {
+	c.released(pr)
+
+	c.logWarn("request failed", Field{"route", pr.route}, Field{"mid", pr.mid}, Field{"error", err})
+	c.logExchange(pr, nil, err)
+	if err == ErrRequestTimeout {
+		c.breaker.failure(pr.route)
+	} else {
+		c.releaseProbe(pr)
+	}
+	c.reportPending()
+	c.deliver(pr, func() {
+		if pr.onError != nil {
+			pr.onError(err)
+		}
+	})
+}
+
+// failAllPending completes every pending request with err
+func (c *Connector) failAllPending(err error) {
+	for _, pr := range c.responses.all() {
+		c.failPending(pr, err)
+	}
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+// ReplayPolicy decides what happens to the requests still unanswered
+// when the connection drops
+type ReplayPolicy int
+
+const (
+	// ReplayFailFast fails them with ErrConnectorClosed, the default
+	ReplayFailFast ReplayPolicy = iota
+	// ReplayIdempotent re-sends the requests marked idempotent with
+	// SetRouteIdempotent or WithIdempotent after the next handshake and
+	// fails the others.
+	ReplayIdempotent
+	// ReplayAll re-sends every unanswered request after the next handshake
+	ReplayAll
+)
+
+// SetReplayPolicy sets the policy applied to the unanswered requests when
+// the connection drops. Requeued requests keep their callbacks and
+// timeouts, they are re-sent with a new mid once the connector, after
+// Reset, is ready again. Closing the connector with Close always fails
+// them.
+func (c *Connector) SetReplayPolicy(policy ReplayPolicy) {
+	c.muResponses.Lock()
+	defer c.muResponses.Unlock()
+
+	c.replayPolicy = policy
+}
+
+// SetRouteIdempotent marks route as safe to re-send for ReplayIdempotent
+func (c *Connector) SetRouteIdempotent(route string, idempotent bool) {
+	c.muResponses.Lock()
+	defer c.muResponses.Unlock()
+
+	if !idempotent {
+		delete(c.idempotentRoutes, route)
+		return
+	}
+	c.idempotentRoutes[route] = true
+}
+
+// WithIdempotent marks the request as safe to re-send for ReplayIdempotent
+func WithIdempotent() RequestOption {
+	return func(o *requestOptions) {
+		o.idempotent = true
+	}
+}
+
+// CancelReplay fails the requests waiting to be re-sent with
+// ErrConnectorClosed, e.g. when giving up reconnecting.
+func (c *Connector) CancelReplay() {
+	c.muResponses.Lock()
+	queued := c.replayQueue
+	c.replayQueue = nil
+	c.muResponses.Unlock()
+
+	for _, pr := range queued {
+		c.completeFailed(pr, ErrConnectorClosed)
+	}
+}
+
+// keepsRequests reports whether the policy may requeue requests, they
+// keep their payload only then.
+func (c *Connector) keepsRequests() bool {
+	c.muResponses.RLock()
+	defer c.muResponses.RUnlock()
+
+	return c.replayPolicy != ReplayFailFast
+}
+
+// retainPending moves the pending requests the policy requeues to the
+// replay queue and fails the others with err.
+func (c *Connector) retainPending(err error) {
+	for _, pr := range c.responses.all() {
+		c.muResponses.Lock()
+		keep := c.replayPolicy == ReplayAll ||
+			c.replayPolicy == ReplayIdempotent && (pr.idempotent || c.idempotentRoutes[pr.route])
+		if keep && c.responses.remove(pr) {
+			c.replayQueue = append(c.replayQueue, pr)
+			c.muResponses.Unlock()
+			continue
+		}
+		c.muResponses.Unlock()
+		c.failPending(pr, err)
+	}
+}
+
+// unqueue removes pr from the replay queue, it reports whether it was
+// queued
+func (c *Connector) unqueue(pr *pendingRequest) bool {
+	c.muResponses.Lock()
+	defer c.muResponses.Unlock()
+
+	for i, cur := range c.replayQueue {
+		if cur == pr {
+			c.replayQueue = append(c.replayQueue[:i], c.replayQueue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// replayPending re-sends the queued requests in their original order
+func (c *Connector) replayPending() {
+	c.muResponses.Lock()
+	queued := c.replayQueue
+	c.replayQueue = nil
+	c.muResponses.Unlock()
+	if len(queued) == 0 {
+		return
+	}
+
+	c.logInfo("replaying requests", Field{"count", len(queued)})
+	for _, old := range queued {
+		if old.timer != nil && !old.timer.Stop() {
+			// the timeout fired while queued
+			c.completeFailed(old, ErrRequestTimeout)
+			continue
+		}
+
+		pr := c.remap(old)
+		msg := &message.Message{
+			Type:  message.Request,
+			Route: pr.route,
+			ID:    pr.mid,
+			Data:  pr.data,
+		}
+		if err := c.sendMessage(msg); err != nil {
+			c.failPending(pr, err)
+			continue
+		}
+		c.metrics.IncrCounter(MetricRequests, 1)
+		c.routeTable.request(pr.route, c.clock.Now())
+	}
+	c.reportPending()
+}
+
+// remap registers a copy of old under a new mid, the timeout keeps
+// counting from the original send
+func (c *Connector) remap(old *pendingRequest) *pendingRequest {
+	pr := *old
+	pr.mid = c.nextMid()
+	pr.timer = nil
+	c.register(&pr)
+	if pr.stream != nil {
+		pr.stream.bind(&pr)
+	}
+
+	if !pr.deadline.IsZero() {
+		d := pr.deadline.Sub(c.clock.Now())
+		if d < 0 {
+			d = 0
+		}
+		pr.timer = c.clock.AfterFunc(d, func() {
+			c.failPending(&pr, ErrRequestTimeout)
+		})
+	}
+	return &pr
+}
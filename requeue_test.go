@@ -0,0 +1,54 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+func TestCloseFailsRequeuedAfterDrop(t *testing.T) {
+	requests := make(chan struct{}, 1)
+	s := newTestServer(t, func(sc *serverConn, msg *message.Message) {
+		if msg.Type == message.Request {
+			requests <- struct{}{}
+		}
+	})
+	c := newTestConnector(t)
+	c.SetReplayPolicy(ReplayAll)
+	runConnector(t, c, s.addr())
+	sc := s.next()
+
+	failed := make(chan error, 1)
+	if err := c.Request("area.get", nil, func([]byte) {
+		t.Error("requeued request answered")
+	}, WithErrorHandler(func(err error) {
+		failed <- err
+	})); err != nil {
+		t.Fatal(err)
+	}
+	<-requests
+
+	// the drop requeues the request for the next connection
+	sc.conn.Close()
+	waitFor(t, "requeued request", func() bool {
+		c.muResponses.RLock()
+		defer c.muResponses.RUnlock()
+		return len(c.replayQueue) == 1
+	})
+	select {
+	case err := <-failed:
+		t.Fatalf("requeued request failed on the drop: %v", err)
+	default:
+	}
+
+	c.Close()
+	select {
+	case err := <-failed:
+		if err != ErrConnectorClosed {
+			t.Fatalf("error %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("requeued request still waiting after Close")
+	}
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// RouteInfo is the traffic seen on one route
+	RouteInfo struct {
+		Route    string
+		Requests uint64
+		Notifies uint64
+		Pushes   uint64
+		LastSeen time.Time // last request, notify or push
+	}
+
+	// routeTable tracks every route used by the connector
+	routeTable struct {
+		mu     sync.Mutex
+		routes map[string]*RouteInfo
+	}
+)
+
+func newRouteTable() *routeTable {
+	return &routeTable{routes: map[string]*RouteInfo{}}
+}
+
+func (t *routeTable) info(route string, at time.Time) *RouteInfo {
+	ri, ok := t.routes[route]
+	if !ok {
+		ri = &RouteInfo{Route: route}
+		t.routes[route] = ri
+	}
+	ri.LastSeen = at
+	return ri
+}
+
+func (t *routeTable) request(route string, at time.Time) {
+	t.mu.Lock()
+	t.info(route, at).Requests++
+	t.mu.Unlock()
+}
+
+func (t *routeTable) notify(route string, at time.Time) {
+	t.mu.Lock()
+	t.info(route, at).Notifies++
+	t.mu.Unlock()
+}
+
+func (t *routeTable) push(route string, at time.Time) {
+	t.mu.Lock()
+	t.info(route, at).Pushes++
+	t.mu.Unlock()
+}
+
+// Routes returns every route requested, notified or pushed since the
+// connector was created, sorted by name. Pushes are counted whether or
+// not a handler is registered.
+func (c *Connector) Routes() []RouteInfo {
+	c.routeTable.mu.Lock()
+	routes := make([]RouteInfo, 0, len(c.routeTable.routes))
+	for _, ri := range c.routeTable.routes {
+		routes = append(routes, *ri)
+	}
+	c.routeTable.mu.Unlock()
+
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Route < routes[j].Route })
+	return routes
+}
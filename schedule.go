@@ -0,0 +1,101 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// scheduledTask is a callback run periodically while connected
+type scheduledTask struct {
+	interval time.Duration
+	fn       func()
+
+	mu       sync.Mutex
+	running  <-chan byte // die channel of the connection it runs for
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Schedule runs fn every interval while the connector is connected, e.g.
+// to refresh an auth token or send presence updates. The first run is one
+// interval after Connected fires, the task pauses when the connection is
+// closed and resumes after the next handshake once the connector is Reset
+// and Run again. Runs of a task never overlap. cancel stops it for good.
+func (c *Connector) Schedule(interval time.Duration, fn func()) (cancel func()) {
+	t := &scheduledTask{interval: interval, fn: fn, stop: make(chan struct{})}
+
+	c.Lock()
+	if c.schedules == nil {
+		c.schedules = map[*scheduledTask]struct{}{}
+	}
+	c.schedules[t] = struct{}{}
+	c.Unlock()
+
+	if c.isReady() {
+		c.startTask(t)
+	}
+
+	return func() {
+		c.Lock()
+		delete(c.schedules, t)
+		c.Unlock()
+		t.stopOnce.Do(func() { close(t.stop) })
+	}
+}
+
+// isReady reports whether Connected fired and the connector is not closed
+func (c *Connector) isReady() bool {
+	select {
+	case <-c.Ready():
+		return !c.isDead()
+	default:
+		return false
+	}
+}
+
+// startSchedules starts the scheduled tasks for the new connection
+func (c *Connector) startSchedules() {
+	c.RLock()
+	tasks := make([]*scheduledTask, 0, len(c.schedules))
+	for t := range c.schedules {
+		tasks = append(tasks, t)
+	}
+	c.RUnlock()
+
+	for _, t := range tasks {
+		c.startTask(t)
+	}
+}
+
+// startTask runs t until the connection or t is stopped, it is a no-op if
+// t already runs for the current connection
+func (c *Connector) startTask(t *scheduledTask) {
+	if t.interval <= 0 {
+		return
+	}
+
+	die := c.done()
+	t.mu.Lock()
+	if t.running == die {
+		t.mu.Unlock()
+		return
+	}
+	t.running = die
+	t.mu.Unlock()
+
+	ticker := c.clock.NewTicker(t.interval)
+	go func() {
+		c.labelGoroutine()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-die:
+				return
+			case <-t.stop:
+				return
+			case <-ticker.C():
+			}
+			t.fn()
+		}
+	}()
+}
@@ -0,0 +1,502 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+// SchemaError is a payload failing the schema of its route, it matches
+// ErrSchemaViolation with errors.Is.
+type SchemaError struct {
+	Route   string
+	Path    string // dotted path of the value, e.g. "players.0.name", empty for the payload
+	Problem string
+}
+
+func (e *SchemaError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(payload)"
+	}
+	return fmt.Sprintf("%s: route %s: %s: %s", ErrSchemaViolation, e.Route, path, e.Problem)
+}
+
+// Is --
+func (e *SchemaError) Is(target error) bool {
+	return target == ErrSchemaViolation
+}
+
+// SchemaMiddleware validates the payloads of the routes given a schema:
+// requests and notifies failing it are rejected before being sent, and
+// pushes failing it are reported to the OnInvalidPush hook, catching a
+// contract drift between client and server early. It validates the
+// payloads as encoded by the serializer, before compression.
+type SchemaMiddleware struct {
+	mu      sync.RWMutex
+	routes  map[string]func(data []byte) error
+	onPush  func(route string, data []byte, err error)
+	dropBad bool
+}
+
+// NewSchemaMiddleware returns a middleware without schemas, it is added
+// with AddMiddleware or Use.
+func NewSchemaMiddleware() *SchemaMiddleware {
+	return &SchemaMiddleware{routes: map[string]func([]byte) error{}}
+}
+
+// Route sets the JSON Schema of the payloads of route. The supported
+// keywords are type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf,
+// anyOf and oneOf, and the annotations title, description, default,
+// examples, deprecated, readOnly, writeOnly, $schema, $id and $comment
+// are ignored. A schema using another keyword, e.g. $ref, format or
+// patternProperties, is rejected rather than half enforced.
+func (s *SchemaMiddleware) Route(route string, schema []byte) error {
+	compiled, err := compileSchema(schema)
+	if err != nil {
+		return fmt.Errorf("schema of %s: %w", route, err)
+	}
+	s.RouteFunc(route, func(data []byte) error {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return &SchemaError{Problem: "payload is not JSON"}
+		}
+		return compiled.validate(v, "")
+	})
+	return nil
+}
+
+// RouteProto validates the payloads of route as protobuf messages of
+// newMsg: they must unmarshal with their required fields set, and pass
+// check when not nil, e.g. a protovalidate validator.
+func (s *SchemaMiddleware) RouteProto(route string, newMsg func() proto.Message, check func(proto.Message) error) {
+	s.RouteFunc(route, func(data []byte) error {
+		msg := newMsg()
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return &SchemaError{Problem: err.Error()}
+		}
+		if check == nil {
+			return nil
+		}
+		if err := check(msg); err != nil {
+			return &SchemaError{Problem: err.Error()}
+		}
+		return nil
+	})
+}
+
+// RouteFunc validates the payloads of route with fn, a nil fn removes
+// the validation of route
+func (s *SchemaMiddleware) RouteFunc(route string, fn func(data []byte) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if fn == nil {
+		delete(s.routes, route)
+		return
+	}
+	s.routes[route] = fn
+}
+
+// OnInvalidPush sets the hook called with the pushes failing their
+// schema, they are still dispatched unless drop is set.
+func (s *SchemaMiddleware) OnInvalidPush(hook func(route string, data []byte, err error), drop bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onPush = hook
+	s.dropBad = drop
+}
+
+// Outgoing --
+func (s *SchemaMiddleware) Outgoing(msg *message.Message) error {
+	if msg.Type != message.Request && msg.Type != message.Notify {
+		return nil
+	}
+	return s.check(msg.Route, msg.Data)
+}
+
+// Incoming --
+func (s *SchemaMiddleware) Incoming(msg *message.Message) error {
+	if msg.Type != message.Push {
+		return nil
+	}
+	err := s.check(msg.Route, msg.Data)
+	if err == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	hook, drop := s.onPush, s.dropBad
+	s.mu.RUnlock()
+	if hook != nil {
+		hook(msg.Route, msg.Data, err)
+	}
+	if drop {
+		return err
+	}
+	return nil
+}
+
+func (s *SchemaMiddleware) check(route string, data []byte) error {
+	s.mu.RLock()
+	fn, ok := s.routes[route]
+	s.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	err := fn(data)
+	if se, ok := err.(*SchemaError); ok {
+		se.Route = route
+	}
+	return err
+}
+
+// jsonSchema is a compiled JSON Schema
+type jsonSchema struct {
+	types      []string
+	enum       []interface{}
+	constant   interface{}
+	hasConst   bool
+	properties map[string]*jsonSchema
+	required   []string
+	additional *jsonSchema // nil allows any
+	noExtra    bool        // additionalProperties false
+	items      *jsonSchema
+	minItems   *int
+	maxItems   *int
+	minLength  *int
+	maxLength  *int
+	pattern    *regexp.Regexp
+	minimum    *float64
+	maximum    *float64
+	exclMin    *float64
+	exclMax    *float64
+	allOf      []*jsonSchema
+	anyOf      []*jsonSchema
+	oneOf      []*jsonSchema
+}
+
+// schemaKeywords are the keywords compileSchema understands
+var schemaKeywords = map[string]bool{
+	"type":                 true,
+	"enum":                 true,
+	"const":                true,
+	"properties":           true,
+	"required":             true,
+	"additionalProperties": true,
+	"items":                true,
+	"minItems":             true,
+	"maxItems":             true,
+	"minLength":            true,
+	"maxLength":            true,
+	"pattern":              true,
+	"minimum":              true,
+	"maximum":              true,
+	"exclusiveMinimum":     true,
+	"exclusiveMaximum":     true,
+	"allOf":                true,
+	"anyOf":                true,
+	"oneOf":                true,
+	// annotations, ignored
+	"title":       true,
+	"description": true,
+	"default":     true,
+	"examples":    true,
+	"deprecated":  true,
+	"readOnly":    true,
+	"writeOnly":   true,
+	"$schema":     true,
+	"$id":         true,
+	"$comment":    true,
+}
+
+// schemaDoc is the JSON form of a schema
+type schemaDoc struct {
+	Type             json.RawMessage            `json:"type"`
+	Enum             []interface{}              `json:"enum"`
+	Const            json.RawMessage            `json:"const"`
+	Properties       map[string]json.RawMessage `json:"properties"`
+	Required         []string                   `json:"required"`
+	Additional       json.RawMessage            `json:"additionalProperties"`
+	Items            json.RawMessage            `json:"items"`
+	MinItems         *int                       `json:"minItems"`
+	MaxItems         *int                       `json:"maxItems"`
+	MinLength        *int                       `json:"minLength"`
+	MaxLength        *int                       `json:"maxLength"`
+	Pattern          string                     `json:"pattern"`
+	Minimum          *float64                   `json:"minimum"`
+	Maximum          *float64                   `json:"maximum"`
+	ExclusiveMinimum *float64                   `json:"exclusiveMinimum"`
+	ExclusiveMaximum *float64                   `json:"exclusiveMaximum"`
+	AllOf            []json.RawMessage          `json:"allOf"`
+	AnyOf            []json.RawMessage          `json:"anyOf"`
+	OneOf            []json.RawMessage          `json:"oneOf"`
+}
+
+func compileSchema(data []byte) (*jsonSchema, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	var unknown []string
+	for key := range keys {
+		if !schemaKeywords[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unsupported keyword %q", unknown[0])
+	}
+
+	var doc schemaDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	s := &jsonSchema{
+		enum:      doc.Enum,
+		required:  doc.Required,
+		minItems:  doc.MinItems,
+		maxItems:  doc.MaxItems,
+		minLength: doc.MinLength,
+		maxLength: doc.MaxLength,
+		minimum:   doc.Minimum,
+		maximum:   doc.Maximum,
+		exclMin:   doc.ExclusiveMinimum,
+		exclMax:   doc.ExclusiveMaximum,
+	}
+	if len(doc.Type) > 0 {
+		var one string
+		if err := json.Unmarshal(doc.Type, &one); err == nil {
+			s.types = []string{one}
+		} else if err := json.Unmarshal(doc.Type, &s.types); err != nil {
+			return nil, fmt.Errorf("bad type: %w", err)
+		}
+	}
+	if len(doc.Const) > 0 {
+		s.hasConst = true
+		if err := json.Unmarshal(doc.Const, &s.constant); err != nil {
+			return nil, err
+		}
+	}
+	if doc.Pattern != "" {
+		re, err := regexp.Compile(doc.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		s.pattern = re
+	}
+
+	var err error
+	if len(doc.Properties) > 0 {
+		s.properties = make(map[string]*jsonSchema, len(doc.Properties))
+		for name, raw := range doc.Properties {
+			if s.properties[name], err = compileSchema(raw); err != nil {
+				return nil, fmt.Errorf("property %s: %w", name, err)
+			}
+		}
+	}
+	switch string(doc.Additional) {
+	case "", "true":
+	case "false":
+		s.noExtra = true
+	default:
+		if s.additional, err = compileSchema(doc.Additional); err != nil {
+			return nil, fmt.Errorf("additionalProperties: %w", err)
+		}
+	}
+	if len(doc.Items) > 0 {
+		if s.items, err = compileSchema(doc.Items); err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+	}
+	for _, list := range []struct {
+		raw []json.RawMessage
+		out *[]*jsonSchema
+	}{{doc.AllOf, &s.allOf}, {doc.AnyOf, &s.anyOf}, {doc.OneOf, &s.oneOf}} {
+		for _, raw := range list.raw {
+			sub, err := compileSchema(raw)
+			if err != nil {
+				return nil, err
+			}
+			*list.out = append(*list.out, sub)
+		}
+	}
+	return s, nil
+}
+
+// validate checks v, decoded by encoding/json, found at path
+func (s *jsonSchema) validate(v interface{}, path string) error {
+	fail := func(format string, args ...interface{}) error {
+		return &SchemaError{Path: path, Problem: fmt.Sprintf(format, args...)}
+	}
+
+	if len(s.types) > 0 && !s.typed(v) {
+		return fail("%s, want %v", jsonType(v), s.types)
+	}
+	if len(s.enum) > 0 {
+		found := false
+		for _, e := range s.enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("%v not in enum", v)
+		}
+	}
+	if s.hasConst && !reflect.DeepEqual(s.constant, v) {
+		return fail("%v, want %v", v, s.constant)
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				return fail("missing required %s", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		// stable errors when several fields fail
+		sort.Strings(names)
+		for _, name := range names {
+			sub, ok := s.properties[name]
+			if !ok {
+				if s.noExtra {
+					return fail("unexpected property %s", name)
+				}
+				sub = s.additional
+			}
+			if sub == nil {
+				continue
+			}
+			if err := sub.validate(v[name], joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			return fail("%d items, want at least %d", len(v), *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			return fail("%d items, want at most %d", len(v), *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				if err := s.items.validate(item, joinPath(path, strconv.Itoa(i))); err != nil {
+					return err
+				}
+			}
+		}
+
+	case string:
+		n := len([]rune(v))
+		if s.minLength != nil && n < *s.minLength {
+			return fail("length %d, want at least %d", n, *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			return fail("length %d, want at most %d", n, *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return fail("%q does not match %s", v, s.pattern)
+		}
+
+	case float64:
+		switch {
+		case s.minimum != nil && v < *s.minimum:
+			return fail("%v below minimum %v", v, *s.minimum)
+		case s.maximum != nil && v > *s.maximum:
+			return fail("%v above maximum %v", v, *s.maximum)
+		case s.exclMin != nil && v <= *s.exclMin:
+			return fail("%v not above %v", v, *s.exclMin)
+		case s.exclMax != nil && v >= *s.exclMax:
+			return fail("%v not below %v", v, *s.exclMax)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		if err := sub.validate(v, path); err != nil {
+			return err
+		}
+	}
+	if len(s.anyOf) > 0 {
+		matched := false
+		for _, sub := range s.anyOf {
+			if sub.validate(v, path) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fail("matches none of anyOf")
+		}
+	}
+	if len(s.oneOf) > 0 {
+		matched := 0
+		for _, sub := range s.oneOf {
+			if sub.validate(v, path) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fail("matches %d of oneOf, want 1", matched)
+		}
+	}
+	return nil
+}
+
+func (s *jsonSchema) typed(v interface{}) bool {
+	got := jsonType(v)
+	for _, t := range s.types {
+		if t == got {
+			return true
+		}
+		if f, ok := v.(float64); ok && t == "integer" && f == math.Trunc(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type of v, integers are "number"
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+func TestSchemaKeywords(t *testing.T) {
+	for _, tc := range []struct {
+		keyword string
+		schema  string
+		valid   []string
+		invalid []string
+	}{
+		{"type", `{"type":"string"}`, []string{`"a"`}, []string{`1`, `null`}},
+		{"type list", `{"type":["integer","null"]}`, []string{`1`, `null`}, []string{`1.5`, `"1"`}},
+		{"enum", `{"enum":["red",1]}`, []string{`"red"`, `1`}, []string{`"blue"`}},
+		{"const", `{"const":{"v":1}}`, []string{`{"v":1}`}, []string{`{"v":2}`}},
+		{"properties", `{"properties":{"n":{"type":"number"}}}`, []string{`{"n":1}`, `{}`}, []string{`{"n":"1"}`}},
+		{"required", `{"required":["id"]}`, []string{`{"id":1}`}, []string{`{}`}},
+		{"additionalProperties false", `{"properties":{"a":{}},"additionalProperties":false}`, []string{`{"a":1}`}, []string{`{"a":1,"b":2}`}},
+		{"additionalProperties schema", `{"additionalProperties":{"type":"boolean"}}`, []string{`{"x":true}`}, []string{`{"x":1}`}},
+		{"items", `{"items":{"type":"integer"}}`, []string{`[1,2]`}, []string{`[1,"2"]`}},
+		{"minItems", `{"minItems":2}`, []string{`[1,2]`}, []string{`[1]`}},
+		{"maxItems", `{"maxItems":1}`, []string{`[1]`}, []string{`[1,2]`}},
+		{"minLength", `{"minLength":2}`, []string{`"éé"`}, []string{`"é"`}},
+		{"maxLength", `{"maxLength":2}`, []string{`"éé"`}, []string{`"ééé"`}},
+		{"pattern", `{"pattern":"^[a-z]+$"}`, []string{`"abc"`}, []string{`"ab1"`}},
+		{"minimum", `{"minimum":1}`, []string{`1`}, []string{`0.5`}},
+		{"maximum", `{"maximum":1}`, []string{`1`}, []string{`1.5`}},
+		{"exclusiveMinimum", `{"exclusiveMinimum":1}`, []string{`1.5`}, []string{`1`}},
+		{"exclusiveMaximum", `{"exclusiveMaximum":1}`, []string{`0.5`}, []string{`1`}},
+		{"allOf", `{"allOf":[{"minimum":1},{"maximum":2}]}`, []string{`1.5`}, []string{`3`}},
+		{"anyOf", `{"anyOf":[{"type":"string"},{"type":"null"}]}`, []string{`"a"`, `null`}, []string{`1`}},
+		{"oneOf", `{"oneOf":[{"minimum":1},{"maximum":2}]}`, []string{`0`, `3`}, []string{`1.5`}},
+		{"annotations", `{"title":"t","description":"d","default":1,"examples":[1],"$comment":"c","type":"number"}`, []string{`1`}, []string{`"1"`}},
+	} {
+		t.Run(tc.keyword, func(t *testing.T) {
+			s := NewSchemaMiddleware()
+			if err := s.Route("r", []byte(tc.schema)); err != nil {
+				t.Fatal(err)
+			}
+			for _, payload := range tc.valid {
+				if err := s.check("r", []byte(payload)); err != nil {
+					t.Errorf("%s rejected: %v", payload, err)
+				}
+			}
+			for _, payload := range tc.invalid {
+				if err := s.check("r", []byte(payload)); !errors.Is(err, ErrSchemaViolation) {
+					t.Errorf("%s accepted: %v", payload, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaUnsupportedKeywords(t *testing.T) {
+	for _, schema := range []string{
+		`{"$ref":"#/definitions/a"}`,
+		`{"type":"string","format":"email"}`,
+		`{"uniqueItems":true}`,
+		`{"patternProperties":{"^x":{}}}`,
+		`{"minProperties":1}`,
+		`{"properties":{"a":{"multipleOf":2}}}`,
+		`{"items":{"contains":{}}}`,
+		`{"anyOf":[{"not":{}}]}`,
+	} {
+		if err := NewSchemaMiddleware().Route("r", []byte(schema)); err == nil || !strings.Contains(err.Error(), "unsupported keyword") {
+			t.Errorf("%s: %v", schema, err)
+		}
+	}
+}
+
+func TestSchemaErrorPath(t *testing.T) {
+	s := NewSchemaMiddleware()
+	if err := s.Route("r", []byte(`{"properties":{"players":{"items":{"required":["name"]}}}}`)); err != nil {
+		t.Fatal(err)
+	}
+	err := s.Outgoing(&message.Message{Type: message.Notify, Route: "r", Data: []byte(`{"players":[{"name":"a"},{}]}`)})
+	var se *SchemaError
+	if !errors.As(err, &se) {
+		t.Fatalf("error %v", err)
+	}
+	if se.Route != "r" || se.Path != "players.1" {
+		t.Fatalf("route %q path %q", se.Route, se.Path)
+	}
+}
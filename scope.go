@@ -0,0 +1,86 @@
+package client
+
+import "sync"
+
+// Scope is a group of push handlers registered on a connector, they are
+// all removed at once by Close, e.g. when the player leaves a scene.
+type Scope struct {
+	c    *Connector
+	name string
+
+	mu     sync.Mutex
+	routes map[string]struct{}
+	closed bool
+}
+
+// Scope returns a new, empty handler scope, name is only used in logs
+func (c *Connector) Scope(name string) *Scope {
+	return &Scope{c: c, name: name, routes: map[string]struct{}{}}
+}
+
+// Name --
+func (s *Scope) Name() string {
+	return s.name
+}
+
+// On adds the callback for the event, it replaces any handler of the
+// event, scoped or not. On is a no-op once the scope is closed.
+func (s *Scope) On(event string, callback Callback) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		s.c.logWarn("handler registered on closed scope", Field{"scope", s.name}, Field{"route", event})
+		return
+	}
+	s.routes[event] = struct{}{}
+	s.c.on(event, callback, s)
+}
+
+// Off removes the callback of the event if it still belongs to the scope
+func (s *Scope) Off(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.routes, event)
+	s.c.offOwned(event, s)
+}
+
+// Request sends a request through the scope connector
+func (s *Scope) Request(route string, data []byte, callback Callback, opts ...RequestOption) error {
+	return s.c.Request(route, data, callback, opts...)
+}
+
+// Notify sends a notification through the scope connector
+func (s *Scope) Notify(route string, data []byte) error {
+	return s.c.Notify(route, data)
+}
+
+// Close removes every handler registered through the scope, handlers
+// replaced since by another On are kept. It is safe to call Close
+// multiple times.
+func (s *Scope) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for event := range s.routes {
+		s.c.offOwned(event, s)
+	}
+	s.routes = nil
+}
+
+// offOwned removes the handler of event if it was registered by owner
+func (c *Connector) offOwned(event string, owner *Scope) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.owners[event] != owner {
+		return
+	}
+	delete(c.events, event)
+	delete(c.owners, event)
+}
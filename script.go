@@ -0,0 +1,59 @@
+package client
+
+import "fmt"
+
+// ConnectStep is one request of the connect script
+type ConnectStep struct {
+	Route string
+	Data  []byte
+	// Expect validates the response, a non-nil error aborts the script
+	Expect func(data []byte) error
+}
+
+// SetConnectScript sets the requests executed in order after every
+// successful handshake, the Connected callback only fires once all of
+// them succeeded. A failing step closes the connector.
+func (c *Connector) SetConnectScript(steps ...ConnectStep) {
+	c.connectScript = steps
+}
+
+func (c *Connector) runConnectScript() error {
+	for i, step := range c.scriptSteps() {
+		data, err := c.requestSync(step.Route, step.Data)
+		if err != nil {
+			return fmt.Errorf("connect script step %d (%s): %w", i, step.Route, err)
+		}
+		if step.Expect == nil {
+			continue
+		}
+		if err := step.Expect(data); err != nil {
+			return fmt.Errorf("connect script step %d (%s): %w", i, step.Route, err)
+		}
+	}
+	return nil
+}
+
+// requestSync sends a request and waits for its response, it must not be
+// called from the read loop.
+func (c *Connector) requestSync(route string, data []byte, opts ...RequestOption) ([]byte, error) {
+	ch := make(chan []byte, 1)
+	errCh := make(chan error, 1)
+	opts = append(opts, WithErrorHandler(func(err error) {
+		errCh <- err
+	}), withDirect())
+	err := c.Request(route, data, func(data []byte) {
+		ch <- cloneBytes(data)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case data := <-ch:
+		return data, nil
+	case err := <-errCh:
+		return nil, err
+	case <-c.done():
+		return nil, ErrConnectorClosed
+	}
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// SequenceGapHandler is called when pushes of route were missed, from is
+// the first missing and to the last missing sequence number. It runs on
+// the read goroutine, a resync request should be sent asynchronously.
+type SequenceGapHandler func(route string, from, to uint64)
+
+// pushSequencer tracks the last sequence number seen on every route, the
+// state survives Reset so gaps across reconnects are detected.
+type pushSequencer struct {
+	mu     sync.Mutex
+	fields map[string][]string // route => path of the sequence field
+	last   map[string]uint64
+	onGap  SequenceGapHandler
+}
+
+// SetPushSequence reads the sequence number of the pushes of route from
+// the JSON field at path, nested fields are separated by dots (e.g.
+// "meta.seq"). Duplicated or out of order pushes are dropped, missed
+// pushes are reported to the gap handler. An empty path disables it.
+func (c *Connector) SetPushSequence(route, path string) {
+	c.Lock()
+	defer c.Unlock()
+
+	s := c.sequencerLocked()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if path == "" {
+		delete(s.fields, route)
+		delete(s.last, route)
+		return
+	}
+	s.fields[route] = strings.Split(path, ".")
+}
+
+// OnSequenceGap sets the handler called when sequenced pushes were missed
+func (c *Connector) OnSequenceGap(handler SequenceGapHandler) {
+	c.Lock()
+	defer c.Unlock()
+
+	s := c.sequencerLocked()
+	s.mu.Lock()
+	s.onGap = handler
+	s.mu.Unlock()
+}
+
+// sequencerLocked must be called with the connector lock held
+func (c *Connector) sequencerLocked() *pushSequencer {
+	if c.sequencer == nil {
+		c.sequencer = &pushSequencer{fields: map[string][]string{}, last: map[string]uint64{}}
+	}
+	return c.sequencer
+}
+
+// LastSequence returns the last sequence number delivered on route, use
+// it to ask the server for the missed state.
+func (c *Connector) LastSequence(route string) (uint64, bool) {
+	s := c.pushSequencer()
+	if s == nil {
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq, ok := s.last[route]
+	return seq, ok
+}
+
+// ResetSequence forgets the sequence state of route, the next push is
+// accepted whatever its number, e.g. after a full state resync.
+func (c *Connector) ResetSequence(route string) {
+	s := c.pushSequencer()
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	delete(s.last, route)
+	s.mu.Unlock()
+}
+
+func (c *Connector) pushSequencer() *pushSequencer {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.sequencer
+}
+
+// checkSequence reports whether the push must be delivered
+func (c *Connector) checkSequence(route string, data []byte) bool {
+	s := c.pushSequencer()
+	if s == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	path, ok := s.fields[route]
+	if !ok {
+		s.mu.Unlock()
+		return true
+	}
+	seq, ok := readSequence(data, path)
+	if !ok {
+		s.mu.Unlock()
+		c.logWarn("push sequence missing", Field{"route", route}, Field{"bytes", len(data)})
+		return true
+	}
+
+	last, seen := s.last[route]
+	if seen && seq <= last {
+		s.mu.Unlock()
+		c.logDebug("push sequence duplicated", Field{"route", route}, Field{"seq", seq}, Field{"last", last})
+		return false
+	}
+	s.last[route] = seq
+	onGap := s.onGap
+	s.mu.Unlock()
+
+	if seen && seq > last+1 {
+		c.logWarn("push sequence gap", Field{"route", route}, Field{"from", last + 1}, Field{"to", seq - 1})
+		if onGap != nil {
+			onGap(route, last+1, seq-1)
+		}
+	}
+	return true
+}
+
+func readSequence(data []byte, path []string) (uint64, bool) {
+	raw, ok := jsonPath(data, path)
+	if !ok {
+		return 0, false
+	}
+	var seq uint64
+	if err := json.Unmarshal(raw, &seq); err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+// jsonPath returns the value at path of the JSON object data
+func jsonPath(data []byte, path []string) (json.RawMessage, bool) {
+	raw := json.RawMessage(data)
+	for _, key := range path {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, false
+		}
+		next, ok := obj[key]
+		if !ok {
+			return nil, false
+		}
+		raw = next
+	}
+	return raw, true
+}
@@ -0,0 +1,24 @@
+package cbor
+
+import (
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Serializer implements the serialize.Serializer interface
+type Serializer struct{}
+
+// NewSerializer returns a new Serializer.
+func NewSerializer() *Serializer {
+	return &Serializer{}
+}
+
+// Marshal returns the CBOR encoding of v.
+func (s *Serializer) Marshal(v interface{}) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+// Unmarshal parses the CBOR-encoded data and stores the result
+// in the value pointed to by v.
+func (s *Serializer) Unmarshal(data []byte, v interface{}) error {
+	return cbor.Unmarshal(data, v)
+}
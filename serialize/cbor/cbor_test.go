@@ -0,0 +1,45 @@
+package cbor
+
+import (
+	"reflect"
+	"testing"
+)
+
+type player struct {
+	Name  string   `cbor:"name"`
+	Level int      `cbor:"level"`
+	Tags  []string `cbor:"tags"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	s := NewSerializer()
+	in := player{Name: "a", Level: 7, Tags: []string{"x", "y"}}
+	data, err := s.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out player
+	if err := s.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip %+v, want %+v", out, in)
+	}
+
+	// the keys are the cbor tags
+	var m map[string]interface{}
+	if err := s.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["name"] != "a" {
+		t.Fatalf("decoded map %v", m)
+	}
+}
+
+func TestUnmarshalMalformed(t *testing.T) {
+	var out player
+	if err := NewSerializer().Unmarshal([]byte{0xff}, &out); err == nil {
+		t.Fatal("malformed data accepted")
+	}
+}
@@ -0,0 +1,39 @@
+package raw
+
+import (
+	"errors"
+)
+
+// ErrWrongValueType is the error used for marshal the value with raw encoding.
+var ErrWrongValueType = errors.New("raw: value must be []byte or *[]byte")
+
+// Serializer implements the serialize.Serializer interface for prebuilt
+// payloads (e.g. FlatBuffers), bytes are passed through untouched.
+type Serializer struct{}
+
+// NewSerializer returns a new Serializer.
+func NewSerializer() *Serializer {
+	return &Serializer{}
+}
+
+// Marshal returns v itself, v must be a []byte or *[]byte.
+func (s *Serializer) Marshal(v interface{}) ([]byte, error) {
+	switch b := v.(type) {
+	case []byte:
+		return b, nil
+	case *[]byte:
+		return *b, nil
+	}
+	return nil, ErrWrongValueType
+}
+
+// Unmarshal stores a copy of data in the *[]byte pointed to by v, data
+// may be a read buffer reused once the call returns.
+func (s *Serializer) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return ErrWrongValueType
+	}
+	*b = append([]byte(nil), data...)
+	return nil
+}
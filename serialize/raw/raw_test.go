@@ -0,0 +1,41 @@
+package raw
+
+import (
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	s := NewSerializer()
+	payload := []byte{1, 2, 3}
+	for _, v := range []interface{}{payload, &payload} {
+		data, err := s.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(payload) {
+			t.Fatalf("marshaled %v", data)
+		}
+	}
+	if _, err := s.Marshal("text"); err != ErrWrongValueType {
+		t.Fatalf("error %v", err)
+	}
+}
+
+func TestUnmarshalCopies(t *testing.T) {
+	buf := []byte{1, 2, 3}
+	var out []byte
+	if err := NewSerializer().Unmarshal(buf, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// the read buffer is reused for the next packet
+	buf[0] = 9
+	if out[0] != 1 {
+		t.Fatalf("unmarshaled slice aliases the buffer: %v", out)
+	}
+
+	var wrong string
+	if err := NewSerializer().Unmarshal(buf, &wrong); err != ErrWrongValueType {
+		t.Fatalf("error %v", err)
+	}
+}
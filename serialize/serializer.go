@@ -0,0 +1,19 @@
+package serialize
+
+type (
+	// Marshaler represents a marshal interface
+	Marshaler interface {
+		Marshal(interface{}) ([]byte, error)
+	}
+
+	// Unmarshaler represents a Unmarshal interface
+	Unmarshaler interface {
+		Unmarshal([]byte, interface{}) error
+	}
+
+	// Serializer is the interface that groups the basic Marshal and Unmarshal methods.
+	Serializer interface {
+		Marshaler
+		Unmarshaler
+	}
+)
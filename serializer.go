@@ -0,0 +1,96 @@
+package client
+
+import (
+	"google.golang.org/protobuf/proto"
+
+	"github.com/revzim/go-pomelo-client/serialize"
+	"github.com/revzim/go-pomelo-client/serialize/protobuf"
+	"github.com/revzim/go-pomelo-client/serialize/raw"
+)
+
+var (
+	protoSerializer = protobuf.NewSerializer()
+	rawSerializer   = raw.NewSerializer()
+)
+
+// SetSerializer sets the serializer used by Call, JSON by default
+func (c *Connector) SetSerializer(s serialize.Serializer) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.serializer = s
+}
+
+// SetRouteSerializer overrides the connector serializer for one route,
+// nil removes the override.
+func (c *Connector) SetRouteSerializer(route string, s serialize.Serializer) {
+	c.Lock()
+	defer c.Unlock()
+
+	if s == nil {
+		delete(c.routeSerializers, route)
+		return
+	}
+	c.routeSerializers[route] = s
+}
+
+// SetRouteRaw switches route to raw mode, Call takes a prebuilt []byte
+// and fills a *[]byte with the response without any (de)serialization.
+func (c *Connector) SetRouteRaw(route string) {
+	c.SetRouteSerializer(route, rawSerializer)
+}
+
+func (c *Connector) serializerFor(route string) serialize.Serializer {
+	c.RLock()
+	defer c.RUnlock()
+
+	if s, ok := c.routeSerializers[route]; ok {
+		return s
+	}
+	return c.serializer
+}
+
+// Call marshals req with the route serializer, sends it to route and
+// waits for the response which is unmarshaled into resp. resp may be nil
+// when the response body is not needed.
+func (c *Connector) Call(route string, req, resp interface{}) error {
+	return c.call(c.serializerFor(route), route, req, resp)
+}
+
+// RequestProto sends req to route and waits for the response which is
+// unmarshaled into resp.
+func (c *Connector) RequestProto(route string, req, resp proto.Message) error {
+	c.markProtoApp(route)
+	return c.call(protoSerializer, route, req, resp)
+}
+
+// OnProto adds a callback for the event, every push is unmarshaled into a
+// new message returned by newMsg.
+func (c *Connector) OnProto(route string, newMsg func() proto.Message, cb func(proto.Message)) {
+	c.markProtoApp(route)
+	c.On(route, func(data []byte) {
+		msg := newMsg()
+		if err := protoSerializer.Unmarshal(data, msg); err != nil {
+			c.logError("push unmarshal failed", Field{"route", route}, Field{"bytes", len(data)}, Field{"error", err})
+			return
+		}
+		cb(msg)
+	})
+}
+
+func (c *Connector) call(s serialize.Serializer, route string, req, resp interface{}) error {
+	data, err := s.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	data, err = c.requestSync(route, data)
+	if err != nil {
+		return err
+	}
+
+	if resp == nil {
+		return nil
+	}
+	return s.Unmarshal(data, resp)
+}
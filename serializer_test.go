@@ -0,0 +1,81 @@
+package client
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/revzim/go-pomelo-client/message"
+	"github.com/revzim/go-pomelo-client/serialize/cbor"
+	"github.com/revzim/go-pomelo-client/serialize/json"
+)
+
+type serializerPayload struct {
+	Name string `json:"name" cbor:"name"`
+}
+
+func TestRouteSerializer(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received = map[string][]byte{}
+	)
+	s := newTestServer(t, func(sc *serverConn, msg *message.Message) {
+		if msg.Type == message.Request {
+			mu.Lock()
+			received[msg.Route] = msg.Data
+			mu.Unlock()
+			sc.respond(msg.ID, msg.Data)
+		}
+	})
+	c := newTestConnector(t)
+	c.SetSerializer(cbor.NewSerializer())
+	c.SetRouteSerializer("legacy.get", json.NewSerializer())
+	runConnector(t, c, s.addr())
+	s.next()
+
+	for _, route := range []string{"area.get", "legacy.get"} {
+		var resp serializerPayload
+		if err := c.Call(route, serializerPayload{Name: "a"}, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Name != "a" {
+			t.Fatalf("%s: response %+v", route, resp)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var got serializerPayload
+	if err := cbor.NewSerializer().Unmarshal(received["area.get"], &got); err != nil || got.Name != "a" {
+		t.Fatalf("area.get payload %x is not CBOR: %v", received["area.get"], err)
+	}
+	if string(received["legacy.get"]) != `{"name":"a"}` {
+		t.Fatalf("legacy.get payload %q", received["legacy.get"])
+	}
+
+	// removing the override falls back to the connector serializer
+	c.SetRouteSerializer("legacy.get", nil)
+	if _, ok := c.serializerFor("legacy.get").(*cbor.Serializer); !ok {
+		t.Fatal("override not removed")
+	}
+}
+
+func TestSetSerializerWhileRunning(t *testing.T) {
+	s := newTestServer(t, nil)
+	c := newTestConnector(t)
+	runConnector(t, c, s.addr())
+	s.next()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			c.SetSerializer(json.NewSerializer())
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if err := c.Call("echo", serializerPayload{Name: "a"}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/revzim/go-pomelo-client/codec"
+	"github.com/revzim/go-pomelo-client/message"
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+// testTimeout bounds the waits of the tests on a real connection
+const testTimeout = 5 * time.Second
+
+// testServer is a minimal pomelo server: it answers the handshake,
+// echoes the heartbeats and hands the requests and notifies to handle,
+// requests are echoed by default.
+type testServer struct {
+	t         testing.TB
+	ln        net.Listener
+	heartbeat int // handshake sys.heartbeat, seconds
+	handle    func(sc *serverConn, msg *message.Message)
+	conns     chan *serverConn // connections past the handshake ack
+}
+
+// serverConn is a connection accepted by a testServer
+type serverConn struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+func newTestServer(t testing.TB, handle func(sc *serverConn, msg *message.Message)) *testServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{t: t, ln: ln, handle: handle, conns: make(chan *serverConn, 16)}
+	if s.handle == nil {
+		s.handle = func(sc *serverConn, msg *message.Message) {
+			if msg.Type == message.Request {
+				sc.respond(msg.ID, msg.Data)
+			}
+		}
+	}
+	t.Cleanup(func() { ln.Close() })
+	go s.accept()
+	return s
+}
+
+func (s *testServer) addr() string {
+	return "tcp://" + s.ln.Addr().String()
+}
+
+func (s *testServer) accept() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.serve(&serverConn{conn: conn})
+	}
+}
+
+func (s *testServer) serve(sc *serverConn) {
+	defer sc.conn.Close()
+
+	dec := codec.NewDecoder()
+	buf := make([]byte, 4096)
+	for {
+		n, err := sc.conn.Read(buf)
+		if err != nil {
+			return
+		}
+		packets, err := dec.Decode(buf[:n])
+		if err != nil {
+			return
+		}
+		for _, p := range packets {
+			switch p.Type {
+			case packet.Handshake:
+				resp, _ := json.Marshal(map[string]interface{}{
+					"code": 200,
+					"sys":  map[string]interface{}{"heartbeat": s.heartbeat},
+				})
+				sc.send(packet.Handshake, resp)
+			case packet.HandshakeAck:
+				s.conns <- sc
+			case packet.Heartbeat:
+				sc.send(packet.Heartbeat, nil)
+			case packet.Data:
+				msg, err := message.Decode(p.Data)
+				if err != nil {
+					return
+				}
+				// the decoder reuses its buffer
+				msg.Data = append([]byte(nil), msg.Data...)
+				s.handle(sc, msg)
+			}
+		}
+	}
+}
+
+// next returns the next connection past its handshake
+func (s *testServer) next() *serverConn {
+	s.t.Helper()
+
+	select {
+	case sc := <-s.conns:
+		return sc
+	case <-time.After(testTimeout):
+		s.t.Fatal("no connection")
+		return nil
+	}
+}
+
+func (sc *serverConn) send(typ byte, data []byte) {
+	frame, _ := codec.Encode(typ, data)
+	sc.write(frame)
+}
+
+// write sends raw bytes, e.g. a malformed frame
+func (sc *serverConn) write(b []byte) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.conn.Write(b)
+}
+
+func (sc *serverConn) message(msg *message.Message) {
+	data, err := message.Encode(msg)
+	if err != nil {
+		panic(err)
+	}
+	sc.send(packet.Data, data)
+}
+
+func (sc *serverConn) respond(mid uint, data []byte) {
+	sc.message(&message.Message{Type: message.Response, ID: mid, Data: data})
+}
+
+func (sc *serverConn) push(route string, data []byte) {
+	sc.message(&message.Message{Type: message.Push, Route: route, Data: data})
+}
+
+// nopLogger drops the entries, the goroutines of a connection may log
+// after the end of the test
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...Field) {}
+func (nopLogger) Info(string, ...Field)  {}
+func (nopLogger) Warn(string, ...Field)  {}
+func (nopLogger) Error(string, ...Field) {}
+
+// newTestConnector returns a connector with a handshake
+func newTestConnector(t testing.TB) *Connector {
+	t.Helper()
+
+	c := NewConnector()
+	c.SetLogger(nopLogger{})
+	if err := c.InitReqHandshake("", "test", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InitHandshakeACK(1); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// runConnector runs c to addr and waits until it is ready, the channel
+// receives the result of Run
+func runConnector(t testing.TB, c *Connector, addr string) <-chan error {
+	t.Helper()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Run(addr)
+	}()
+	select {
+	case <-c.Ready():
+	case err := <-errCh:
+		t.Fatalf("run: %v", err)
+	case <-time.After(testTimeout):
+		t.Fatal("handshake timeout")
+	}
+	return errCh
+}
+
+// call sends a request and waits for its response
+func call(t testing.TB, c *Connector, route string, data []byte) ([]byte, error) {
+	t.Helper()
+
+	return c.requestSync(route, data, WithTimeout(testTimeout))
+}
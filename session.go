@@ -0,0 +1,137 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// Well known session keys
+const (
+	SessionUID   = "uid"
+	SessionRID   = "rid"
+	SessionToken = "token"
+)
+
+// HandshakeUserSession is the handshake user data key carrying the
+// session values on reconnection.
+const HandshakeUserSession = "session"
+
+// Session holds the identifiers assigned by the server, it survives Reset
+// and its values are sent in the user data of every later handshake.
+type Session struct {
+	mu     sync.RWMutex
+	values map[string]interface{}
+}
+
+func newSession() *Session {
+	return &Session{values: map[string]interface{}{}}
+}
+
+// Get --
+func (s *Session) Get(key string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// String returns the value of key formatted as a string, numbers such as
+// uid are returned in their JSON form.
+func (s *Session) String(key string) string {
+	v, ok := s.Get(key)
+	if !ok {
+		return ""
+	}
+	if str, ok := v.(string); ok {
+		return str
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// Set --
+func (s *Session) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = value
+}
+
+// Delete --
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.values, key)
+}
+
+// Clear removes every value, e.g. on logout
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values = map[string]interface{}{}
+}
+
+// Values returns a copy of the session values
+func (s *Session) Values() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := make(map[string]interface{}, len(s.values))
+	for k, v := range s.values {
+		values[k] = v
+	}
+	return values
+}
+
+// SessionAware is implemented by middlewares needing the session, it is
+// given to them by AddMiddleware.
+type SessionAware interface {
+	SetSession(s *Session)
+}
+
+// Session returns the connector session
+func (c *Connector) Session() *Session {
+	return c.session
+}
+
+// SetSessionRoute copies the JSON fields of the responses and pushes of
+// route into the session, keyed by the last element of their path (e.g.
+// "user.uid" is stored as "uid"). Nested fields are separated by dots.
+func (c *Connector) SetSessionRoute(route string, paths ...string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(paths) == 0 {
+		delete(c.sessionRoutes, route)
+		return
+	}
+	c.sessionRoutes[route] = paths
+}
+
+// captureSession stores the session fields of a response or push
+func (c *Connector) captureSession(route string, data []byte) {
+	c.RLock()
+	paths, ok := c.sessionRoutes[route]
+	c.RUnlock()
+	if !ok {
+		return
+	}
+
+	for _, path := range paths {
+		keys := strings.Split(path, ".")
+		raw, ok := jsonPath(data, keys)
+		if !ok {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			continue
+		}
+		c.session.Set(keys[len(keys)-1], v)
+		c.logDebug("session updated", Field{"route", route}, Field{"key", keys[len(keys)-1]})
+	}
+}
@@ -0,0 +1,53 @@
+//go:build go1.21
+// +build go1.21
+
+// Package slogadapter wires the connector Logger interface to log/slog
+package slogadapter
+
+import (
+	"context"
+	"log/slog"
+
+	client "github.com/revzim/go-pomelo-client"
+)
+
+// Logger adapts a *slog.Logger to client.Logger
+type Logger struct {
+	l *slog.Logger
+}
+
+// New returns a client.Logger writing to l, nil uses slog.Default()
+func New(l *slog.Logger) *Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &Logger{l: l}
+}
+
+// Debug --
+func (s *Logger) Debug(msg string, fields ...client.Field) {
+	s.log(slog.LevelDebug, msg, fields)
+}
+
+// Info --
+func (s *Logger) Info(msg string, fields ...client.Field) {
+	s.log(slog.LevelInfo, msg, fields)
+}
+
+// Warn --
+func (s *Logger) Warn(msg string, fields ...client.Field) {
+	s.log(slog.LevelWarn, msg, fields)
+}
+
+// Error --
+func (s *Logger) Error(msg string, fields ...client.Field) {
+	s.log(slog.LevelError, msg, fields)
+}
+
+func (s *Logger) log(level slog.Level, msg string, fields []client.Field) {
+	attrs := make([]slog.Attr, len(fields))
+	for i, f := range fields {
+		attrs[i] = slog.Any(f.Key, f.Value)
+	}
+	s.l.LogAttrs(context.Background(), level, msg, attrs...)
+}
@@ -0,0 +1,224 @@
+// Package soak runs connectors for a long time with scripted traffic and
+// periodically checks the client invariants: no goroutine growth, bounded
+// memory and no stuck requests.
+package soak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	client "github.com/revzim/go-pomelo-client"
+)
+
+type (
+	// Config describes a soak run
+	Config struct {
+		Connectors int           // number of connectors
+		Duration   time.Duration // run length, zero runs until ctx is done
+
+		// New returns a configured, unconnected connector
+		New func(i int) *client.Connector
+		// Connect runs the connector until it is closed, e.g. c.Run(addr)
+		Connect func(c *client.Connector) error
+		// ReconnectDelay is the pause before a disconnected connector is reset and connected again
+		ReconnectDelay time.Duration
+
+		// Traffic is called every TrafficInterval on every connector
+		Traffic         func(c *client.Connector) error
+		TrafficInterval time.Duration
+
+		// CheckInterval is how often the invariants are asserted
+		CheckInterval      time.Duration
+		MaxGoroutineGrowth int           // allowed goroutines over the baseline, zero disables the check
+		MaxHeapBytes       uint64        // allowed heap in use, zero disables the check
+		MaxPendingAge      time.Duration // allowed age of a pending request, zero disables the check
+	}
+
+	// Violation is a failed invariant check
+	Violation struct {
+		At     time.Time
+		Check  string
+		Detail string
+	}
+
+	// Report summarizes a soak run
+	Report struct {
+		Started  time.Time
+		Finished time.Time
+
+		Checks        int
+		Traffic       int64
+		TrafficErrors int64
+		Disconnects   int64
+
+		BaselineGoroutines int
+		PeakGoroutines     int
+		PeakHeapBytes      uint64
+		Violations         []Violation
+	}
+)
+
+// ErrInvalidConfig is returned when New or Connect is missing
+var ErrInvalidConfig = errors.New("soak: New and Connect are required")
+
+// Passed reports whether no invariant was violated
+func (r *Report) Passed() bool {
+	return len(r.Violations) == 0
+}
+
+// String returns a human readable summary
+func (r *Report) String() string {
+	var sb strings.Builder
+	status := "PASS"
+	if !r.Passed() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(&sb, "soak %s after %s\n", status, r.Finished.Sub(r.Started).Round(time.Second))
+	fmt.Fprintf(&sb, "  checks: %d, traffic: %d (errors %d), disconnects: %d\n", r.Checks, r.Traffic, r.TrafficErrors, r.Disconnects)
+	fmt.Fprintf(&sb, "  goroutines: baseline %d, peak %d\n", r.BaselineGoroutines, r.PeakGoroutines)
+	fmt.Fprintf(&sb, "  heap: peak %d bytes\n", r.PeakHeapBytes)
+	for _, v := range r.Violations {
+		fmt.Fprintf(&sb, "  %s %s: %s\n", v.At.Format(time.RFC3339), v.Check, v.Detail)
+	}
+	return sb.String()
+}
+
+// Run executes the soak run and returns its report once Duration elapsed
+// or ctx is done.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.New == nil || cfg.Connect == nil {
+		return nil, ErrInvalidConfig
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = time.Minute
+	}
+	if cfg.ReconnectDelay <= 0 {
+		cfg.ReconnectDelay = time.Second
+	}
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	r := &Report{Started: time.Now()}
+	connectors := make([]*client.Connector, cfg.Connectors)
+	var wg sync.WaitGroup
+	for i := range connectors {
+		c := cfg.New(i)
+		connectors[i] = c
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runConnector(ctx, cfg, c, r)
+		}()
+		if cfg.Traffic != nil && cfg.TrafficInterval > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				runTraffic(ctx, cfg, c, r)
+			}()
+		}
+	}
+
+	ticker := time.NewTicker(cfg.CheckInterval)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+			check(cfg, connectors, r)
+		}
+	}
+
+	for _, c := range connectors {
+		c.Close()
+	}
+	wg.Wait()
+	r.Finished = time.Now()
+	return r, nil
+}
+
+func runConnector(ctx context.Context, cfg Config, c *client.Connector, r *Report) {
+	for {
+		cfg.Connect(c)
+		if ctx.Err() != nil {
+			return
+		}
+		atomic.AddInt64(&r.Disconnects, 1)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.ReconnectDelay):
+		}
+		c.Reset()
+	}
+}
+
+func runTraffic(ctx context.Context, cfg Config, c *client.Connector, r *Report) {
+	ticker := time.NewTicker(cfg.TrafficInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if c.IsClosed() {
+			continue
+		}
+		atomic.AddInt64(&r.Traffic, 1)
+		if err := cfg.Traffic(c); err != nil {
+			atomic.AddInt64(&r.TrafficErrors, 1)
+		}
+	}
+}
+
+// check asserts the invariants, it is only called from the Run goroutine
+func check(cfg Config, connectors []*client.Connector, r *Report) {
+	now := time.Now()
+	r.Checks++
+
+	goroutines := runtime.NumGoroutine()
+	if r.Checks == 1 {
+		// the first check happens once every connector had time to connect
+		r.BaselineGoroutines = goroutines
+	}
+	if goroutines > r.PeakGoroutines {
+		r.PeakGoroutines = goroutines
+	}
+	if cfg.MaxGoroutineGrowth > 0 && goroutines-r.BaselineGoroutines > cfg.MaxGoroutineGrowth {
+		r.Violations = append(r.Violations, Violation{now, "goroutines",
+			fmt.Sprintf("%d goroutines, baseline %d", goroutines, r.BaselineGoroutines)})
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if ms.HeapInuse > r.PeakHeapBytes {
+		r.PeakHeapBytes = ms.HeapInuse
+	}
+	if cfg.MaxHeapBytes > 0 && ms.HeapInuse > cfg.MaxHeapBytes {
+		r.Violations = append(r.Violations, Violation{now, "heap",
+			fmt.Sprintf("%d bytes in use, limit %d", ms.HeapInuse, cfg.MaxHeapBytes)})
+	}
+
+	if cfg.MaxPendingAge <= 0 {
+		return
+	}
+	for i, c := range connectors {
+		if age := c.Stats().OldestPending; age > cfg.MaxPendingAge {
+			r.Violations = append(r.Violations, Violation{now, "stuck request",
+				fmt.Sprintf("connector %d has a request pending for %s", i, age.Round(time.Millisecond))})
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ResolveSRV looks up the SRV records of name, e.g.
+// _pomelo._tcp.example.com, and returns their host:port targets in
+// connection order: by ascending priority, weighted at random within a
+// priority as in RFC 2782.
+func ResolveSRV(ctx context.Context, name string) ([]string, error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records) == 1 && records[0].Target == "." {
+		return nil, fmt.Errorf("srv %s: service not available", name)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Priority < records[j].Priority
+	})
+	targets := make([]string, 0, len(records))
+	for i := 0; i < len(records); {
+		j := i
+		for j < len(records) && records[j].Priority == records[i].Priority {
+			j++
+		}
+		for _, rec := range weightedOrder(records[i:j]) {
+			host := strings.TrimSuffix(rec.Target, ".")
+			targets = append(targets, net.JoinHostPort(host, strconv.Itoa(int(rec.Port))))
+		}
+		i = j
+	}
+	return targets, nil
+}
+
+// weightedOrder orders the records of a priority, each pick is random
+// with a chance proportional to the weight
+func weightedOrder(records []*net.SRV) []*net.SRV {
+	left := append([]*net.SRV(nil), records...)
+	ordered := make([]*net.SRV, 0, len(left))
+	for len(left) > 0 {
+		total := 0
+		for _, rec := range left {
+			total += int(rec.Weight)
+		}
+		pick := 0
+		if total > 0 {
+			n := rand.Intn(total + 1)
+			for sum := 0; pick < len(left)-1; pick++ {
+				if sum += int(left[pick].Weight); sum >= n {
+					break
+				}
+			}
+		}
+		ordered = append(ordered, left[pick])
+		left = append(left[:pick], left[pick+1:]...)
+	}
+	return ordered
+}
+
+// dialSRV resolves the srv:// or srv+tls:// addr on every dial, so
+// reconnects follow the records, and connects the first target
+// answering.
+func (c *Connector) dialSRV(addr string, r *ConnectReport) (net.Conn, error) {
+	scheme, name := splitScheme(addr)
+
+	start := c.clock.Now()
+	targets, err := ResolveSRV(context.Background(), name)
+	srvTime := c.clock.Since(start)
+	r.DNS = srvTime
+	if err != nil {
+		return nil, err
+	}
+
+	for _, target := range targets {
+		var conn net.Conn
+		if scheme == "srv+tls" {
+			conn, err = c.dialTLS(target, r)
+		} else {
+			conn, err = c.dialTCP(target, r)
+		}
+		r.DNS += srvTime
+		if err == nil {
+			c.logDebug("srv target connected", Field{"srv", name}, Field{"target", target})
+			return conn, nil
+		}
+		c.logWarn("srv target failed", Field{"srv", name}, Field{"target", target}, Field{"error", err})
+	}
+	return nil, err
+}
@@ -0,0 +1,324 @@
+package client
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// histogramSubBits splits every power of two range in 8 linear sub
+	// buckets, a bucket spans at most 1/8 of its lower bound
+	histogramSubBits = 3
+	histogramSub     = 1 << histogramSubBits
+
+	// histogramRangeBits bounds the distinct values to 2^40, about 12
+	// days for latencies in microseconds and 1 TiB for sizes, larger
+	// values are counted in the last bucket
+	histogramRangeBits = 40
+
+	histogramBuckets = (histogramRangeBits - histogramSubBits + 1) * histogramSub
+)
+
+type (
+	// Histogram is a distribution of non negative values with log-linear
+	// buckets, as HDR histograms: the values below 8 have a bucket each,
+	// and every power of two range above is split in 8 buckets of equal
+	// width, so a quantile is within 12.5% of the exact value, and
+	// interpolated within its bucket. Values from 2^40 share the last
+	// bucket, Max still reports them exactly.
+	Histogram struct {
+		Count   int64
+		Sum     int64
+		Min     int64
+		Max     int64
+		Buckets [histogramBuckets]int64
+	}
+
+	// TrafficStats is the payload size distribution of one direction
+	TrafficStats struct {
+		Sizes  Histogram            // every payload
+		Routes map[string]Histogram // payloads per route
+	}
+
+	// LatencyStats is the request latency distribution, send to response
+	// callback, in microseconds
+	LatencyStats struct {
+		Durations Histogram            // every request
+		Routes    map[string]Histogram // requests per route
+	}
+
+	// ReconnectStats describes the recoveries from connection drops, an
+	// outage starts when a connection is lost for another reason than
+	// Close and ends at the next Connected
+	ReconnectStats struct {
+		Durations Histogram // outage durations, in milliseconds
+		Attempts  Histogram // Run calls per outage
+		Outage    bool      // an outage is in progress
+	}
+
+	// Stats is a snapshot of the connector statistics
+	Stats struct {
+		Pending       int           // requests waiting for a response
+		OldestPending time.Duration // age of the oldest pending request
+		Orphans       uint64        // responses without pending request
+		Disconnects   map[DisconnectReason]uint64
+		Sent          TrafficStats
+		Received      TrafficStats
+		Latency       LatencyStats
+		Reconnects    ReconnectStats
+	}
+
+	// stats collects the connector statistics. The distributions observed
+	// on every message have a lock each, so the senders, the read loop and
+	// the response callbacks don't serialize on mu.
+	stats struct {
+		mu          sync.Mutex // guards the disconnects and reconnects
+		sent        trafficStats
+		received    trafficStats
+		latency     trafficStats // durations in microseconds
+		disconnects map[DisconnectReason]uint64
+		reconnects  ReconnectStats
+		outageAt    time.Time // start of the outage in progress
+		attempts    int64     // Run calls of the outage in progress
+	}
+
+	trafficStats struct {
+		mu     sync.Mutex
+		sizes  Histogram
+		routes map[string]*Histogram
+	}
+)
+
+func newStats() *stats {
+	return &stats{
+		sent:        trafficStats{routes: map[string]*Histogram{}},
+		received:    trafficStats{routes: map[string]*Histogram{}},
+		latency:     trafficStats{routes: map[string]*Histogram{}},
+		disconnects: map[DisconnectReason]uint64{},
+	}
+}
+
+// Observe adds v to the histogram
+func (h *Histogram) Observe(v int64) {
+	if h.Count == 0 || v < h.Min {
+		h.Min = v
+	}
+	if v > h.Max {
+		h.Max = v
+	}
+	h.Count++
+	h.Sum += v
+
+	h.Buckets[histogramIndex(v)]++
+}
+
+// histogramIndex returns the bucket of v
+func histogramIndex(v int64) int {
+	if v < histogramSub {
+		if v < 0 {
+			return 0
+		}
+		return int(v)
+	}
+	exp := bits.Len64(uint64(v)) - 1
+	if exp >= histogramRangeBits {
+		return histogramBuckets - 1
+	}
+	shift := uint(exp - histogramSubBits)
+	sub := int(v>>shift) - histogramSub
+	return (exp-histogramSubBits+1)*histogramSub + sub
+}
+
+// histogramBucket returns the lower bound and the width of bucket i
+func histogramBucket(i int) (lower, width int64) {
+	k := i >> histogramSubBits
+	if k == 0 {
+		return int64(i), 1
+	}
+	shift := uint(k - 1)
+	return int64(histogramSub+i&(histogramSub-1)) << shift, 1 << shift
+}
+
+// Mean returns the average of the observed values
+func (h Histogram) Mean() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return float64(h.Sum) / float64(h.Count)
+}
+
+// Quantile returns the q-quantile, 0 < q <= 1, interpolated linearly
+// within its bucket and clamped to [Min, Max].
+func (h Histogram) Quantile(q float64) int64 {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(h.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, n := range h.Buckets {
+		if n == 0 || seen+n < rank {
+			seen += n
+			continue
+		}
+		lower, width := histogramBucket(i)
+		// the bucket values are assumed evenly spread, each at the middle
+		// of its share of the width
+		v := lower + int64(float64(width)*(float64(rank-seen)-0.5)/float64(n))
+		if v < h.Min {
+			v = h.Min
+		}
+		if v > h.Max {
+			v = h.Max
+		}
+		return v
+	}
+	return h.Max
+}
+
+func (t *trafficStats) observe(route string, size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sizes.Observe(int64(size))
+	if route == "" {
+		return
+	}
+	h, ok := t.routes[route]
+	if !ok {
+		h = &Histogram{}
+		t.routes[route] = h
+	}
+	h.Observe(int64(size))
+}
+
+func (t *trafficStats) snapshot() TrafficStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ts := TrafficStats{
+		Sizes:  t.sizes,
+		Routes: make(map[string]Histogram, len(t.routes)),
+	}
+	for route, h := range t.routes {
+		ts.Routes[route] = *h
+	}
+	return ts
+}
+
+// Stats returns a snapshot of the connector statistics
+func (c *Connector) Stats() Stats {
+	latency := c.stats.latency.snapshot()
+	s := Stats{
+		Sent:     c.stats.sent.snapshot(),
+		Received: c.stats.received.snapshot(),
+		Latency:  LatencyStats{Durations: latency.Sizes, Routes: latency.Routes},
+	}
+
+	c.stats.mu.Lock()
+	s.Disconnects = make(map[DisconnectReason]uint64, len(c.stats.disconnects))
+	for reason, n := range c.stats.disconnects {
+		s.Disconnects[reason] = n
+	}
+	s.Reconnects = c.stats.reconnects
+	s.Reconnects.Outage = !c.stats.outageAt.IsZero()
+	c.stats.mu.Unlock()
+
+	pending := c.responses.all()
+	s.Pending = len(pending)
+	now := c.clock.Now()
+	for _, pr := range pending {
+		if age := now.Sub(pr.sentAt); age > s.OldestPending {
+			s.OldestPending = age
+		}
+	}
+	s.Orphans = atomic.LoadUint64(&c.orphans)
+	return s
+}
+
+func (c *Connector) observeSent(route string, size int) {
+	c.stats.sent.observe(route, size)
+
+	c.metrics.Observe(MetricPayloadSent, float64(size))
+	if route != "" {
+		c.metrics.Observe(MetricPayloadSent+"."+route, float64(size))
+	}
+}
+
+func (c *Connector) observeReceived(route string, size int) {
+	c.stats.received.observe(route, size)
+
+	c.metrics.Observe(MetricPayloadReceived, float64(size))
+	if route != "" {
+		c.metrics.Observe(MetricPayloadReceived+"."+route, float64(size))
+	}
+}
+
+// observeLatency records the latency of a request of route answered now
+func (c *Connector) observeLatency(route string, sentAt time.Time) {
+	elapsed := c.clock.Since(sentAt)
+	c.stats.latency.observe(route, int(elapsed/time.Microsecond))
+
+	ms := float64(elapsed) / float64(time.Millisecond)
+	c.metrics.Observe(MetricLatency, ms)
+	if route != "" {
+		c.metrics.Observe(MetricLatency+"."+route, ms)
+	}
+}
+
+// beginOutage starts an outage unless one is in progress
+func (c *Connector) beginOutage() {
+	c.stats.mu.Lock()
+	defer c.stats.mu.Unlock()
+
+	if c.stats.outageAt.IsZero() {
+		c.stats.outageAt = c.clock.Now()
+		c.stats.attempts = 0
+	}
+}
+
+// countAttempt counts a Run call of the outage in progress
+func (c *Connector) countAttempt() {
+	c.stats.mu.Lock()
+	if c.stats.outageAt.IsZero() {
+		c.stats.mu.Unlock()
+		return
+	}
+	c.stats.attempts++
+	attempt := int(c.stats.attempts)
+	c.stats.mu.Unlock()
+
+	c.publish(LifecycleEvent{Kind: EventReconnectAttempt, Attempt: attempt})
+}
+
+// endOutage records the outage in progress as recovered
+func (c *Connector) endOutage() {
+	c.stats.mu.Lock()
+	if c.stats.outageAt.IsZero() {
+		c.stats.mu.Unlock()
+		return
+	}
+	elapsed := c.clock.Since(c.stats.outageAt)
+	attempts := c.stats.attempts
+	c.stats.reconnects.Durations.Observe(int64(elapsed / time.Millisecond))
+	c.stats.reconnects.Attempts.Observe(attempts)
+	c.stats.outageAt = time.Time{}
+	c.stats.mu.Unlock()
+
+	ms := float64(elapsed) / float64(time.Millisecond)
+	c.metrics.Observe(MetricReconnectTime, ms)
+	c.metrics.Observe(MetricReconnectAttempts, float64(attempts))
+}
+
+// abandonOutage forgets the outage in progress, the application closed a
+// reconnection before it was ready
+func (c *Connector) abandonOutage() {
+	c.stats.mu.Lock()
+	c.stats.outageAt = time.Time{}
+	c.stats.mu.Unlock()
+}
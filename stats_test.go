@@ -0,0 +1,76 @@
+package client
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	prev := -1
+	for _, v := range []int64{0, 1, 7, 8, 9, 15, 16, 17, 100, 1000, 1 << 20, 1<<20 + 1, 1<<40 - 1} {
+		i := histogramIndex(v)
+		lower, width := histogramBucket(i)
+		if v < lower || v >= lower+width {
+			t.Fatalf("%d in bucket %d [%d, %d)", v, i, lower, lower+width)
+		}
+		if i < prev {
+			t.Fatalf("bucket of %d before the previous value", v)
+		}
+		prev = i
+		if v >= histogramSub && float64(width) > float64(lower)/histogramSub {
+			t.Fatalf("bucket %d of width %d at %d", i, width, lower)
+		}
+	}
+	if i := histogramIndex(1 << 40); i != histogramBuckets-1 {
+		t.Fatalf("2^40 in bucket %d", i)
+	}
+	if i := histogramIndex(math.MaxInt64); i != histogramBuckets-1 {
+		t.Fatalf("max int64 in bucket %d", i)
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	var h Histogram
+	for v := int64(1); v <= 100000; v++ {
+		h.Observe(v)
+	}
+	for _, q := range []float64{0.01, 0.5, 0.9, 0.95, 0.99, 0.999} {
+		want := q * 100000
+		got := float64(h.Quantile(q))
+		if math.Abs(got-want) > want/histogramSub {
+			t.Errorf("q%v = %v, want %v", q, got, want)
+		}
+	}
+	if got := h.Quantile(1); got != 100000 {
+		t.Errorf("q1 = %d, want the max", got)
+	}
+}
+
+func TestHistogramQuantileExact(t *testing.T) {
+	var h Histogram
+	for _, v := range []int64{3, 3, 5} {
+		h.Observe(v)
+	}
+	for q, want := range map[float64]int64{0.3: 3, 0.6: 3, 1: 5} {
+		if got := h.Quantile(q); got != want {
+			t.Errorf("q%v = %d, want %d", q, got, want)
+		}
+	}
+}
+
+func TestHistogramLongLatencies(t *testing.T) {
+	// latencies in microseconds, far above the 2^24 packet size
+	var h Histogram
+	for i := 0; i < 100; i++ {
+		h.Observe(int64(time.Minute / time.Microsecond))
+	}
+	h.Observe(int64(2 * time.Hour / time.Microsecond))
+	p50 := time.Duration(h.Quantile(0.5)) * time.Microsecond
+	if p50 < 55*time.Second || p50 > 65*time.Second {
+		t.Fatalf("p50 %v, want about 1m", p50)
+	}
+	if max := time.Duration(h.Quantile(1)) * time.Microsecond; max != 2*time.Hour {
+		t.Fatalf("max %v", max)
+	}
+}
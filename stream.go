@@ -0,0 +1,179 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+// ResponseStream iterates the chunks of a streamed response, see
+// RequestStream
+type ResponseStream struct {
+	c   *Connector
+	pr  *pendingRequest
+	end func(chunk []byte) bool
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	chunks [][]byte
+	done   bool
+	err    error
+}
+
+// StreamEndField returns an end marker matching the chunks whose JSON
+// boolean at path is true, e.g. {"items":[...],"end":true}
+func StreamEndField(path string) func(chunk []byte) bool {
+	return func(chunk []byte) bool {
+		end, _ := NewLazyPush(chunk).Bool(path)
+		return end
+	}
+}
+
+// SetStreamRoute sets the follow-up push route of streamed responses:
+// after the first response, the server sends the next chunks as pushes
+// on route carrying the request mid in the JSON field at path. The
+// pushes of route without a streaming mid are dispatched as usual. It
+// must be set before Run.
+func (c *Connector) SetStreamRoute(route, path string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if path == "" {
+		delete(c.streamRoutes, route)
+		return
+	}
+	c.streamRoutes[route] = path
+}
+
+// RequestStream sends a request answered by several chunks, responses
+// with the request mid or pushes on a SetStreamRoute route, until the
+// chunk matching end, StreamEndField("end") when nil. The chunks are
+// queued until read with Next, the end marker chunk included. The
+// request timeout bounds the whole stream, see WithTimeout.
+func (c *Connector) RequestStream(route string, data []byte, end func(chunk []byte) bool, opts ...RequestOption) (*ResponseStream, error) {
+	if end == nil {
+		end = StreamEndField("end")
+	}
+	s := &ResponseStream{c: c, end: end}
+	s.cond = sync.NewCond(&s.mu)
+
+	opts = append(opts, WithErrorHandler(s.finish), withStream(s), withDirect())
+	err := c.Request(route, data, func(data []byte) {
+		s.push(data)
+		s.finish(nil)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func withStream(s *ResponseStream) RequestOption {
+	return func(o *requestOptions) {
+		o.stream = s
+	}
+}
+
+// Next returns the next chunk, it blocks until one arrives and returns
+// false once the stream ended, see Err.
+func (s *ResponseStream) Next() ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.chunks) == 0 && !s.done {
+		s.cond.Wait()
+	}
+	if len(s.chunks) == 0 {
+		return nil, false
+	}
+	chunk := s.chunks[0]
+	s.chunks[0] = nil
+	s.chunks = s.chunks[1:]
+	return chunk, true
+}
+
+// Err returns the error which ended the stream, nil after the end marker
+func (s *ResponseStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Cancel stops the stream with ErrStreamCanceled, the chunks already
+// queued can still be read
+func (s *ResponseStream) Cancel() {
+	s.mu.Lock()
+	pr := s.pr
+	s.mu.Unlock()
+	if pr != nil {
+		s.c.failPending(pr, ErrStreamCanceled)
+	}
+}
+
+// bind records the pending request of the stream, replaced when the
+// request is replayed under a new mid
+func (s *ResponseStream) bind(pr *pendingRequest) {
+	s.mu.Lock()
+	s.pr = pr
+	s.mu.Unlock()
+}
+
+func (s *ResponseStream) push(data []byte) {
+	chunk := cloneBytes(data)
+
+	s.mu.Lock()
+	if !s.done {
+		s.chunks = append(s.chunks, chunk)
+	}
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+func (s *ResponseStream) finish(err error) {
+	s.mu.Lock()
+	if !s.done {
+		s.done, s.err = true, err
+	}
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+// streamChunk queues a response chunk of a streaming request, it reports
+// false for the other responses and for the last chunk, completed as a
+// plain response.
+func (c *Connector) streamChunk(mid uint, data []byte) bool {
+	pr, ok := c.responses.get(mid)
+	if !ok || pr.stream == nil || pr.stream.end(data) {
+		return false
+	}
+	if c.responseError(pr.route, data) != nil {
+		// an error chunk fails the stream
+		return false
+	}
+	c.observeReceived(pr.route, len(data))
+	pr.stream.push(data)
+	return true
+}
+
+// streamPush handles a push of a stream route, it reports whether the
+// push was a chunk
+func (c *Connector) streamPush(route string, data []byte) bool {
+	c.RLock()
+	path, ok := c.streamRoutes[route]
+	c.RUnlock()
+	if !ok {
+		return false
+	}
+
+	mid, ok := NewLazyPush(data).Int(path)
+	if !ok || mid < 0 {
+		return false
+	}
+	if pr, ok := c.responses.get(uint(mid)); !ok || pr.stream == nil {
+		return false
+	}
+	// handled as a response with the mid of the request
+	c.processMessage(&message.Message{Type: message.Response, ID: uint(mid), Data: data})
+	return true
+}
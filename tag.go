@@ -0,0 +1,56 @@
+package client
+
+// WithTag tags the request, e.g. with the scene it was sent from, so
+// CancelTag can drop it when the scene is destroyed. It may be repeated.
+func WithTag(tag string) RequestOption {
+	return func(o *requestOptions) {
+		o.tags = append(o.tags, tag)
+	}
+}
+
+// CancelTag drops the pending requests tagged with tag, including the
+// ones waiting to be replayed, without running their callbacks or error
+// handlers. A late response is then reported as an orphan. It returns the
+// number of dropped requests.
+func (c *Connector) CancelTag(tag string) int {
+	var tagged []*pendingRequest
+	for _, pr := range c.responses.all() {
+		if pr.hasTag(tag) {
+			tagged = append(tagged, pr)
+		}
+	}
+	c.muResponses.RLock()
+	for _, pr := range c.replayQueue {
+		if pr.hasTag(tag) {
+			tagged = append(tagged, pr)
+		}
+	}
+	c.muResponses.RUnlock()
+
+	n := 0
+	for _, pr := range tagged {
+		if !c.responses.remove(pr) && !c.unqueue(pr) {
+			// completed meanwhile
+			continue
+		}
+		c.released(pr)
+		c.releaseProbe(pr)
+		// an ordered sequence must not wait for it
+		c.deliver(pr, func() {})
+		n++
+	}
+	if n > 0 {
+		c.logDebug("requests canceled", Field{"tag", tag}, Field{"count", n})
+		c.reportPending()
+	}
+	return n
+}
+
+func (pr *pendingRequest) hasTag(tag string) bool {
+	for _, t := range pr.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
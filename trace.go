@@ -0,0 +1,111 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+// TraceMiddleware stamps a trace id into a JSON field of every outgoing
+// request and logs the trace id echoed in the responses, correlating the
+// client logs with the server logs.
+type TraceMiddleware struct {
+	field    string
+	logger   Logger
+	generate func() string
+}
+
+// NewTraceMiddleware returns a middleware writing a random trace id into
+// field of the JSON object requests, requests already carrying the field
+// keep their id. Every trace id is logged to logger at debug level, a
+// nil logger is StdLogger(LogDebug).
+func NewTraceMiddleware(field string, logger Logger) *TraceMiddleware {
+	if logger == nil {
+		logger = StdLogger(LogDebug)
+	}
+	return &TraceMiddleware{field: field, logger: logger, generate: newTraceID}
+}
+
+// SetGenerator replaces the random trace id generator
+func (t *TraceMiddleware) SetGenerator(fn func() string) {
+	if fn == nil {
+		fn = newTraceID
+	}
+	t.generate = fn
+}
+
+// Outgoing --
+func (t *TraceMiddleware) Outgoing(msg *message.Message) error {
+	if msg.Type != message.Request {
+		return nil
+	}
+
+	obj, ok := jsonObject(msg.Data)
+	if !ok {
+		// not a JSON object, e.g. protobuf payload
+		return nil
+	}
+
+	var id string
+	if raw, ok := obj[t.field]; ok {
+		if err := json.Unmarshal(raw, &id); err != nil {
+			id = string(raw)
+		}
+	} else {
+		id = t.generate()
+		raw, err := json.Marshal(id)
+		if err != nil {
+			return err
+		}
+		obj[t.field] = raw
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		msg.Data = data
+	}
+
+	t.logger.Debug("request traced", Field{"route", msg.Route}, Field{"mid", msg.ID}, Field{"trace", id})
+	return nil
+}
+
+// Incoming --
+func (t *TraceMiddleware) Incoming(msg *message.Message) error {
+	if msg.Type != message.Response {
+		return nil
+	}
+
+	fields := []Field{{"route", msg.Route}, {"mid", msg.ID}}
+	if obj, ok := jsonObject(msg.Data); ok {
+		if raw, ok := obj[t.field]; ok {
+			var id string
+			if err := json.Unmarshal(raw, &id); err != nil {
+				id = string(raw)
+			}
+			fields = append(fields, Field{"trace", id})
+		}
+	}
+	t.logger.Debug("response traced", fields...)
+	return nil
+}
+
+func jsonObject(data []byte) (map[string]json.RawMessage, bool) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil, false
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, false
+	}
+	return obj, true
+}
+
+func newTraceID() string {
+	var b [16]byte
+	// crypto/rand only fails when the system has no entropy source
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
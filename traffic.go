@@ -0,0 +1,16 @@
+package client
+
+// TrafficObserver is told about every byte written to or read from the
+// connection, framing included, e.g. to enforce a data cap on metered
+// connections. It is called from the read and write loops and must not
+// block, it may Close the connector.
+type TrafficObserver interface {
+	BytesSent(n int)
+	BytesReceived(n int)
+}
+
+// SetTrafficObserver sets the traffic observer, nil removes it. It must
+// be called before Run.
+func (c *Connector) SetTrafficObserver(o TrafficObserver) {
+	c.traffic = o
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/revzim/go-pomelo-client/message"
+)
+
+// Transform rewrites an outgoing request or notify for the version of
+// the connected server, e.g. to rename routes or convert payloads during
+// a rolling server upgrade. version is the sys.version of the handshake
+// response, empty if the server did not send one.
+type Transform func(version string, msg *message.Message) error
+
+// AddTransform appends transforms, they run in order after the
+// middlewares, just before compression. Responses keep being reported on
+// the original route. Transforms must be added before Run.
+func (c *Connector) AddTransform(ts ...Transform) {
+	c.transforms = append(c.transforms, ts...)
+}
+
+// ServerVersion returns the sys.version of the last handshake response
+func (c *Connector) ServerVersion() string {
+	c.muConn.RLock()
+	defer c.muConn.RUnlock()
+
+	return c.serverVersion
+}
+
+func (c *Connector) transform(msg *message.Message) error {
+	if len(c.transforms) == 0 {
+		return nil
+	}
+
+	version := c.ServerVersion()
+	for _, t := range c.transforms {
+		if err := t(version, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenameRoutes returns a transform renaming the routes of the map for
+// the server versions accepted by match.
+func RenameRoutes(match func(version string) bool, routes map[string]string) Transform {
+	return func(version string, msg *message.Message) error {
+		if !match(version) {
+			return nil
+		}
+		if to, ok := routes[msg.Route]; ok {
+			msg.Route = to
+		}
+		return nil
+	}
+}
+
+// VersionPrefix returns a matcher accepting the versions starting with
+// one of the prefixes, e.g. VersionPrefix("2.") for every 2.x server.
+func VersionPrefix(prefixes ...string) func(version string) bool {
+	return func(version string) bool {
+		for _, p := range prefixes {
+			if strings.HasPrefix(version, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
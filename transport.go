@@ -0,0 +1,173 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Transport dials the connection of an address scheme, addr is the full
+// address passed to Run, scheme included.
+type Transport interface {
+	Dial(addr string) (net.Conn, error)
+}
+
+// TransportFunc adapts a dial function to a Transport
+type TransportFunc func(addr string) (net.Conn, error)
+
+// Dial --
+func (f TransportFunc) Dial(addr string) (net.Conn, error) {
+	return f(addr)
+}
+
+// ConnMiddleware wraps a transport, e.g. to throttle, record or measure
+// its connections. Middlewares are stacked around the transport of every
+// scheme, built-in or registered.
+type ConnMiddleware interface {
+	WrapTransport(next Transport) Transport
+}
+
+// ConnMiddlewareFunc adapts a function to a ConnMiddleware
+type ConnMiddlewareFunc func(next Transport) Transport
+
+// WrapTransport --
+func (f ConnMiddlewareFunc) WrapTransport(next Transport) Transport {
+	return f(next)
+}
+
+// ConnWrapper returns a middleware wrapping every dialed connection with
+// wrap, e.g. in a net.Conn counting or logging its reads and writes
+func ConnWrapper(wrap func(conn net.Conn) net.Conn) ConnMiddleware {
+	return ConnMiddlewareFunc(func(next Transport) Transport {
+		return TransportFunc(func(addr string) (net.Conn, error) {
+			conn, err := next.Dial(addr)
+			if err != nil {
+				return nil, err
+			}
+			return wrap(conn), nil
+		})
+	})
+}
+
+// UseConnMiddleware appends connection middlewares, the first one added
+// is the outermost. They must be added before Run.
+func (c *Connector) UseConnMiddleware(mws ...ConnMiddleware) {
+	c.connMiddlewares = append(c.connMiddlewares, mws...)
+}
+
+// RegisterTransport sets the transport dialing the addresses of scheme
+// (e.g. "kcp" for kcp://host:port), it takes precedence over the built-in
+// tcp, tls, ws and wss transports. A nil transport removes it.
+func (c *Connector) RegisterTransport(scheme string, t Transport) {
+	c.Lock()
+	defer c.Unlock()
+
+	if t == nil {
+		delete(c.transports, scheme)
+		return
+	}
+	c.transports[scheme] = t
+}
+
+// SetTLSConfig sets the configuration of the tls and wss transports
+func (c *Connector) SetTLSConfig(config *tls.Config) {
+	c.tlsConfig = config
+}
+
+// SetTLSSessionCache sets the cache of the TLS sessions resumed across
+// reconnects by the tls and wss transports, unless the TLS configuration
+// has its own. Every connector has a private cache by default, one can be
+// shared by connectors to the same servers, nil disables the resumption.
+func (c *Connector) SetTLSSessionCache(cache tls.ClientSessionCache) {
+	c.tlsSessions = cache
+}
+
+// clientTLSConfig returns the TLS configuration of the tls and wss
+// transports, with the connector session cache
+func (c *Connector) clientTLSConfig() *tls.Config {
+	config := c.tlsConfig
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ClientSessionCache == nil && c.tlsSessions != nil {
+		config = config.Clone()
+		config.ClientSessionCache = c.tlsSessions
+	}
+	return config
+}
+
+// SetTickrate limits the read loop to tickrate reads per second, zero
+// (the default) reads as fast as packets arrive.
+func (c *Connector) SetTickrate(tickrate int64) {
+	c.tickrate = tickrate
+}
+
+// SetMaxPacketSize sets the largest packet body accepted from the server,
+// codec.MaxPacketSize by default and up to codec.MaxFrameSize, a larger
+// packet closes the connection with a protocol error. It must be set
+// before Run.
+func (c *Connector) SetMaxPacketSize(n int) {
+	c.maxPacketSize = n
+	c.codec.SetMaxPacketSize(n)
+}
+
+// splitScheme returns the scheme of addr, tcp when it has none
+func splitScheme(addr string) (scheme, rest string) {
+	if i := strings.Index(addr, "://"); i >= 0 {
+		return addr[:i], addr[i+len("://"):]
+	}
+	return "tcp", addr
+}
+
+// dial connects addr, the phase durations are recorded in r
+func (c *Connector) dial(addr string, r *ConnectReport) (net.Conn, error) {
+	scheme, _ := splitScheme(addr)
+	t, err := c.transport(scheme, r)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(c.connMiddlewares) - 1; i >= 0; i-- {
+		t = c.connMiddlewares[i].WrapTransport(t)
+	}
+	return t.Dial(addr)
+}
+
+// transport returns the transport of scheme, the phase durations are
+// recorded in r
+func (c *Connector) transport(scheme string, r *ConnectReport) (Transport, error) {
+	c.RLock()
+	t, ok := c.transports[scheme]
+	c.RUnlock()
+
+	timed := func(dial func(addr string) (net.Conn, error)) Transport {
+		return TransportFunc(func(addr string) (net.Conn, error) {
+			start := c.clock.Now()
+			defer func() { r.Dial = c.clock.Since(start) }()
+			return dial(addr)
+		})
+	}
+	switch {
+	case ok:
+		return timed(t.Dial), nil
+	case scheme == "tcp":
+		return TransportFunc(func(addr string) (net.Conn, error) {
+			_, host := splitScheme(addr)
+			return c.dialTCP(host, r)
+		}), nil
+	case scheme == "tls":
+		return TransportFunc(func(addr string) (net.Conn, error) {
+			_, host := splitScheme(addr)
+			return c.dialTLS(host, r)
+		}), nil
+	case scheme == "ws", scheme == "wss":
+		return TransportFunc(func(addr string) (net.Conn, error) {
+			return c.dialWebSocket(addr, r)
+		}), nil
+	case scheme == "srv", scheme == "srv+tls":
+		return TransportFunc(func(addr string) (net.Conn, error) {
+			return c.dialSRV(addr, r)
+		}), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownTransport, scheme)
+}
@@ -0,0 +1,59 @@
+package client
+
+// UnhandledPush is a push received for a route without handler
+type UnhandledPush struct {
+	Route string
+	Data  []byte
+}
+
+// OnUnhandled sets the catch-all handler called with the pushes of routes
+// without handler, instead of logging them. Pushes buffered by
+// SetPushReplay don't reach it. It is called from the read loop, nil
+// removes it.
+func (c *Connector) OnUnhandled(handler func(route string, data []byte)) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.unhandled = handler
+}
+
+// UnhandledPushes returns a channel receiving the pushes of routes without
+// handler, buffered to size pushes. A push is dropped with a warning when
+// the channel is full, it is never closed. Pushes buffered by
+// SetPushReplay don't reach it. size <= 0 removes it and returns nil.
+func (c *Connector) UnhandledPushes(size int) <-chan UnhandledPush {
+	c.Lock()
+	defer c.Unlock()
+
+	if size <= 0 {
+		c.unhandledCh = nil
+		return nil
+	}
+	c.unhandledCh = make(chan UnhandledPush, size)
+	return c.unhandledCh
+}
+
+// spillPush delivers a push without handler to the catch-all handler and
+// channel, it reports false if none is set.
+func (c *Connector) spillPush(route string, data []byte) bool {
+	c.RLock()
+	handler, ch := c.unhandled, c.unhandledCh
+	c.RUnlock()
+
+	if handler == nil && ch == nil {
+		return false
+	}
+	if handler != nil {
+		handler(route, data)
+	}
+	if ch != nil {
+		buf := cloneBytes(data)
+
+		select {
+		case ch <- UnhandledPush{Route: route, Data: buf}:
+		default:
+			c.logWarn("unhandled push dropped, channel full", Field{"route", route}, Field{"bytes", len(data)})
+		}
+	}
+	return true
+}
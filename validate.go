@@ -0,0 +1,142 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/revzim/go-pomelo-client/codec"
+	"github.com/revzim/go-pomelo-client/serialize"
+	"github.com/revzim/go-pomelo-client/serialize/cbor"
+	"github.com/revzim/go-pomelo-client/serialize/protobuf"
+	"github.com/revzim/go-pomelo-client/serialize/raw"
+)
+
+// ConfigError lists every configuration problem found by Validate
+type ConfigError struct {
+	Problems []string
+}
+
+// Error --
+func (e *ConfigError) Error() string {
+	return "invalid connector configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the configuration before Run: handshake set, route
+// serializers consistent with the protobuf types and JSON paths, TLS
+// certificates usable, limits and sizes in range. It returns a
+// *ConfigError listing every problem, nil when there is none.
+func (c *Connector) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.handshakeData == nil {
+		add("no handshake, see SetHandshake or InitReqHandshake")
+	}
+	if c.serializerFor("") == nil {
+		add("no serializer")
+	}
+
+	if c.heartbeatMin > 0 && c.heartbeatMax > 0 && c.heartbeatMin > c.heartbeatMax {
+		add("heartbeat bounds inverted: min %v > max %v", c.heartbeatMin, c.heartbeatMax)
+	}
+	if c.heartbeatEcho && c.heartbeatMode != HeartbeatInitiate {
+		add("heartbeat echo requires HeartbeatInitiate")
+	}
+	if c.heartbeatGrace > 0 && c.heartbeatMode != HeartbeatRespond {
+		add("heartbeat grace requires HeartbeatRespond")
+	}
+
+	if c.maxPacketSize > codec.MaxFrameSize {
+		add("max packet size %d exceeds the frame limit %d", c.maxPacketSize, codec.MaxFrameSize)
+	}
+	if c.breaker != nil && c.breaker.cooldown <= 0 {
+		add("circuit breaker without cooldown")
+	}
+	if c.requestTimeout < 0 {
+		add("negative request timeout %v", c.requestTimeout)
+	}
+	if c.window != nil && c.window.route == "" {
+		add("receive window without credit route")
+	}
+
+	if c.tlsConfig != nil {
+		for i, cert := range c.tlsConfig.Certificates {
+			if len(cert.Certificate) == 0 || cert.PrivateKey == nil {
+				add("TLS certificate %d has no certificate chain or private key", i)
+			}
+		}
+	}
+
+	problems = append(problems, c.validateRoutes()...)
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ConfigError{Problems: problems}
+}
+
+// validateRoutes returns the problems of the per route settings
+func (c *Connector) validateRoutes() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	c.muResponses.RLock()
+	global := 0
+	if c.admission != nil {
+		global = cap(c.admission)
+	}
+	for route, n := range c.routeLimits {
+		if global > 0 && n > global {
+			add("route %s: in-flight limit %d exceeds the connector limit %d", route, n, global)
+		}
+	}
+	c.muResponses.RUnlock()
+
+	c.RLock()
+	defer c.RUnlock()
+
+	for route := range c.protoTypes {
+		switch s := c.routeSerializers[route].(type) {
+		case *raw.Serializer, *cbor.Serializer:
+			add("route %s: protobuf type registered but serializer is %T, never bridged", route, s)
+		}
+	}
+
+	jsonPaths := map[string]string{}
+	for route := range c.sessionRoutes {
+		jsonPaths[route] = "session fields"
+	}
+	if c.sequencer != nil {
+		c.sequencer.mu.Lock()
+		for route := range c.sequencer.fields {
+			jsonPaths[route] = "push sequence"
+		}
+		c.sequencer.mu.Unlock()
+	}
+	for route, what := range jsonPaths {
+		s, ok := c.routeSerializers[route]
+		if !ok {
+			s = c.serializer
+		}
+		if !isJSON(s) {
+			add("route %s: %s read from JSON but payload is not JSON", route, what)
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// isJSON reports whether s may produce JSON, unknown serializers are
+// given the benefit of the doubt
+func isJSON(s serialize.Serializer) bool {
+	switch s.(type) {
+	case *raw.Serializer, *protobuf.Serializer, *cbor.Serializer:
+		return false
+	}
+	return s != nil
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// handlerWatchdog watches the execution time of the push handlers
+type handlerWatchdog struct {
+	mu       sync.Mutex
+	timeouts map[string]time.Duration // by route, "" is the default
+	hook     func(route string, elapsed time.Duration)
+	isolate  bool
+	isolated map[string]*isolatedRoute
+}
+
+// isolatedRoute runs the handlers of a route on its own goroutine, in
+// arrival order
+type isolatedRoute struct {
+	mu      sync.Mutex
+	queue   []func()
+	running bool
+}
+
+// SetHandlerTimeout sets the max execution time of the push handler of
+// route, the empty route sets the default of every route. d <= 0 removes
+// it. A handler exceeding it triggers the OnHandlerTimeout hook.
+func (c *Connector) SetHandlerTimeout(route string, d time.Duration) {
+	w := c.handlerWatchdog()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if d <= 0 {
+		delete(w.timeouts, route)
+		return
+	}
+	w.timeouts[route] = d
+}
+
+// OnHandlerTimeout sets the hook called, on a timer goroutine, when a push
+// handler is still running after its timeout, a nil hook logs a warning.
+// With isolate the pushes of the route are then dispatched on a goroutine
+// of their own, still in order, so the handler no longer delays the other
+// routes.
+func (c *Connector) OnHandlerTimeout(hook func(route string, elapsed time.Duration), isolate bool) {
+	w := c.handlerWatchdog()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.hook = hook
+	w.isolate = isolate
+}
+
+func (c *Connector) handlerWatchdog() *handlerWatchdog {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.watchdog == nil {
+		c.watchdog = &handlerWatchdog{
+			timeouts: map[string]time.Duration{},
+			isolated: map[string]*isolatedRoute{},
+		}
+	}
+	return c.watchdog
+}
+
+// runHandler runs the push handler of route under the watchdog
+func (c *Connector) runHandler(route string, cb Callback, data []byte) {
+	c.RLock()
+	w := c.watchdog
+	c.RUnlock()
+	if w == nil {
+		cb(data)
+		return
+	}
+
+	w.mu.Lock()
+	d, ok := w.timeouts[route]
+	if !ok {
+		d = w.timeouts[""]
+	}
+	iso := w.isolated[route]
+	w.mu.Unlock()
+
+	if iso == nil {
+		c.watchHandler(w, route, d, cb, data)
+		return
+	}
+	buf := cloneBytes(data)
+	iso.push(func() { c.watchHandler(w, route, d, cb, buf) })
+}
+
+func (c *Connector) watchHandler(w *handlerWatchdog, route string, d time.Duration, cb Callback, data []byte) {
+	if d <= 0 {
+		cb(data)
+		return
+	}
+
+	start := c.clock.Now()
+	timer := c.clock.AfterFunc(d, func() {
+		elapsed := c.clock.Since(start)
+
+		w.mu.Lock()
+		hook := w.hook
+		if w.isolate && w.isolated[route] == nil {
+			w.isolated[route] = &isolatedRoute{}
+		}
+		w.mu.Unlock()
+
+		if hook != nil {
+			hook(route, elapsed)
+			return
+		}
+		c.logWarn("push handler timeout", Field{"route", route}, Field{"elapsed", elapsed})
+	})
+	defer timer.Stop()
+
+	cb(data)
+}
+
+func (r *isolatedRoute) push(fn func()) {
+	r.mu.Lock()
+	r.queue = append(r.queue, fn)
+	if r.running {
+		r.mu.Unlock()
+		return
+	}
+	r.running = true
+	r.mu.Unlock()
+
+	go func() {
+		for {
+			r.mu.Lock()
+			if len(r.queue) == 0 {
+				r.running = false
+				r.mu.Unlock()
+				return
+			}
+			fn := r.queue[0]
+			r.queue = r.queue[1:]
+			r.mu.Unlock()
+
+			fn()
+		}
+	}()
+}
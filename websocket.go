@@ -0,0 +1,291 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// WebSocket close status codes (RFC 6455 7.4.1)
+const (
+	WebSocketCloseNormal        = 1000
+	WebSocketCloseGoingAway     = 1001
+	WebSocketCloseProtocolError = 1002
+	WebSocketCloseNoStatus      = 1005 // close frame without status code
+)
+
+// wsCloseTimeout bounds the write of the close frame to a stalled peer
+const wsCloseTimeout = time.Second
+
+// WebSocketCloseError is the read error of a connection closed by the
+// server with a close frame, the disconnect reason is
+// DisconnectServerClose.
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket closed by server: %d", e.Code)
+	}
+	return fmt.Sprintf("websocket closed by server: %d %s", e.Code, e.Reason)
+}
+
+// SetWebSocketOrigin sets the Origin header sent when dialing a websocket,
+// by default it is derived from the address (ws://host -> http://host).
+func (c *Connector) SetWebSocketOrigin(origin string) {
+	c.wsOrigin = origin
+}
+
+// SetWebSocketProtocols sets the subprotocols offered when dialing a
+// websocket (Sec-WebSocket-Protocol).
+func (c *Connector) SetWebSocketProtocols(protocols ...string) {
+	c.wsProtocols = protocols
+}
+
+// dialWebSocket connects addr, the phase durations are recorded in r.
+// Closing the connection sends a close frame with the status of the
+// disconnect reason, see wsCloseStatus.
+func (c *Connector) dialWebSocket(addr string, r *ConnectReport) (net.Conn, error) {
+	origin := c.wsOrigin
+	if origin == "" {
+		origin = defaultOrigin(addr)
+	}
+
+	config, err := websocket.NewConfig(addr, origin)
+	if err != nil {
+		return nil, err
+	}
+	config.Protocol = c.wsProtocols
+	config.TlsConfig = c.clientTLSConfig()
+
+	host := config.Location.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		port := "80"
+		if config.Location.Scheme == "wss" {
+			port = "443"
+		}
+		host = net.JoinHostPort(host, port)
+	}
+	var raw net.Conn
+	if config.Location.Scheme == "wss" {
+		raw, err = c.dialTLS(host, r)
+	} else {
+		raw, err = c.dialTCP(host, r)
+	}
+	if err != nil {
+		return nil, &websocket.DialError{Config: config, Err: err}
+	}
+
+	wire := &wsWire{Conn: raw}
+	ws, err := websocket.NewClient(config, wire)
+	if err != nil {
+		raw.Close()
+		return nil, &websocket.DialError{Config: config, Err: err}
+	}
+	return &wsConn{Conn: ws, wire: wire, status: c.wsCloseStatus}, nil
+}
+
+// wsCloseStatus returns the close frame status of the disconnect reason
+func (c *Connector) wsCloseStatus() (int, string) {
+	c.muConn.RLock()
+	reason := c.disconnectReason
+	c.muConn.RUnlock()
+
+	switch reason {
+	case "", DisconnectClosed, DisconnectKick, DisconnectServerClose:
+		return WebSocketCloseNormal, string(reason)
+	case DisconnectProtocolError:
+		return WebSocketCloseProtocolError, string(reason)
+	}
+	return WebSocketCloseGoingAway, string(reason)
+}
+
+// wsConn is a websocket connection closed with a close frame carrying a
+// status and a reason, the websocket package only sends a bare status
+type wsConn struct {
+	*websocket.Conn
+	wire   *wsWire
+	status func() (int, string)
+
+	mu     sync.Mutex // serializes the frame writes
+	closed int32      // atomic, set once by Close
+}
+
+func (w *wsConn) Read(b []byte) (int, error) {
+	n, err := w.Conn.Read(b)
+	if err != nil && w.wire.scan.closed != nil {
+		return n, w.wire.scan.closed
+	}
+	return n, err
+}
+
+func (w *wsConn) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if atomic.LoadInt32(&w.closed) == 1 {
+		return 0, net.ErrClosed
+	}
+	return w.Conn.Write(b)
+}
+
+// Close sends the close frame best-effort. The write deadline is set
+// before waiting for mu, a data write blocked on a stalled peer fails by
+// then and can't hold the close.
+func (w *wsConn) Close() error {
+	if !atomic.CompareAndSwapInt32(&w.closed, 0, 1) {
+		return net.ErrClosed
+	}
+	w.wire.SetWriteDeadline(time.Now().Add(wsCloseTimeout))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	code, reason := w.status()
+	// a control frame payload is at most 125 bytes
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	// Write sends a frame of PayloadType, guarded by mu
+	w.Conn.PayloadType = websocket.CloseFrame
+	w.Conn.Write(payload)
+	return w.wire.Close()
+}
+
+// wsWire is the connection under the websocket, it watches the frames
+// read for the close frame of the server
+type wsWire struct {
+	net.Conn
+	scan wsScanner
+}
+
+func (w *wsWire) Read(b []byte) (int, error) {
+	n, err := w.Conn.Read(b)
+	w.scan.scan(b[:n])
+	return n, err
+}
+
+// wsScanner follows the frames of the server past the handshake
+// response and keeps the status of the close frame. It runs on the
+// reading goroutine.
+type wsScanner struct {
+	crlf    int // "\r\n\r\n" bytes matched at the end of the response
+	framing bool
+	head    []byte // header bytes of the current frame
+	left    uint64 // payload bytes left in the current frame
+	closing bool   // the current frame is a close frame
+	payload []byte
+	closed  *WebSocketCloseError
+}
+
+func (s *wsScanner) scan(p []byte) {
+	for len(p) > 0 {
+		switch {
+		case !s.framing:
+			b := p[0]
+			p = p[1:]
+			switch {
+			case b == "\r\n\r\n"[s.crlf]:
+				s.crlf++
+			case b == '\r':
+				s.crlf = 1
+			default:
+				s.crlf = 0
+			}
+			s.framing = s.crlf == 4
+
+		case s.left > 0:
+			n := uint64(len(p))
+			if n > s.left {
+				n = s.left
+			}
+			if s.closing && len(s.payload) < 125 {
+				s.payload = append(s.payload, p[:n]...)
+			}
+			s.left -= n
+			p = p[n:]
+			if s.left == 0 {
+				s.endFrame()
+			}
+
+		default:
+			s.head = append(s.head, p[0])
+			p = p[1:]
+			if len(s.head) < wsHeaderLen(s.head) {
+				continue
+			}
+			s.closing = s.head[0]&0x0f == websocket.CloseFrame
+			switch n := s.head[1] & 0x7f; n {
+			case 126:
+				s.left = uint64(binary.BigEndian.Uint16(s.head[2:]))
+			case 127:
+				s.left = binary.BigEndian.Uint64(s.head[2:])
+			default:
+				s.left = uint64(n)
+			}
+			s.head = s.head[:0]
+			if s.left == 0 {
+				s.endFrame()
+			}
+		}
+	}
+}
+
+func (s *wsScanner) endFrame() {
+	if !s.closing {
+		return
+	}
+	s.closing = false
+	e := &WebSocketCloseError{Code: WebSocketCloseNoStatus}
+	if len(s.payload) >= 2 {
+		e.Code = int(binary.BigEndian.Uint16(s.payload))
+		e.Reason = string(s.payload[2:])
+	}
+	s.payload = nil
+	if s.closed == nil {
+		s.closed = e
+	}
+}
+
+// wsHeaderLen returns the length of the frame header starting with head
+func wsHeaderLen(head []byte) int {
+	if len(head) < 2 {
+		return 2
+	}
+	n := 2
+	switch head[1] & 0x7f {
+	case 126:
+		n += 2
+	case 127:
+		n += 8
+	}
+	if head[1]&0x80 != 0 {
+		n += 4 // masking key
+	}
+	return n
+}
+
+// defaultOrigin maps the websocket address to its http origin
+func defaultOrigin(addr string) string {
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" {
+		return addr
+	}
+	scheme := "http"
+	if u.Scheme == "wss" {
+		scheme = "https"
+	}
+	return scheme + "://" + u.Host
+}
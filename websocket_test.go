@@ -0,0 +1,65 @@
+package client
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocketCloseStalledPeer(t *testing.T) {
+	// the peer never reads, the writes block once the buffers are full
+	stop := make(chan struct{})
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		<-stop
+	}))
+	defer srv.Close()
+	defer close(stop)
+
+	c := NewConnector()
+	conn, err := c.dialWebSocket("ws"+strings.TrimPrefix(srv.URL, "http")+"/", &ConnectReport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var writes int64
+	writeErr := make(chan error, 1)
+	go func() {
+		chunk := make([]byte, 64<<10)
+		for {
+			if _, err := conn.Write(chunk); err != nil {
+				writeErr <- err
+				return
+			}
+			atomic.AddInt64(&writes, 1)
+		}
+	}()
+	// wait until a write is stuck
+	for last := int64(-1); ; {
+		time.Sleep(100 * time.Millisecond)
+		n := atomic.LoadInt64(&writes)
+		if n == last {
+			break
+		}
+		last = n
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		conn.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(wsCloseTimeout + testTimeout):
+		t.Fatal("close blocked by a stalled write")
+	}
+	select {
+	case <-writeErr:
+	case <-time.After(testTimeout):
+		t.Fatal("stalled write not failed")
+	}
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/revzim/go-pomelo-client/packet"
+)
+
+// discardServer accepts one connection and drops everything it reads
+func discardServer(b *testing.B) net.Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	go func() {
+		conn, err := l.Accept()
+		l.Close()
+		if err != nil {
+			return
+		}
+		io.Copy(ioutil.Discard, conn)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	return conn
+}
+
+// BenchmarkWriteSmallFrames compares the vectored write loop with one
+// write per frame on a burst-heavy small message workload.
+func BenchmarkWriteSmallFrames(b *testing.B) {
+	frame, _ := NewConnector().encoder.Encode(packet.Data, make([]byte, 32))
+
+	b.Run("write", func(b *testing.B) {
+		conn := discardServer(b)
+		defer conn.Close()
+
+		ch := make(chan []byte, 64)
+		done := make(chan struct{})
+		go func() {
+			for data := range ch {
+				conn.Write(data)
+			}
+			close(done)
+		}()
+
+		b.SetBytes(int64(len(frame)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ch <- frame
+		}
+		close(ch)
+		<-done
+	})
+
+	b.Run("writev", func(b *testing.B) {
+		conn := discardServer(b)
+		defer conn.Close()
+
+		c := NewConnector()
+		ch := make(chan outbound, 64)
+		die := make(chan byte)
+		go c.write(conn, ch, die, 0)
+
+		b.SetBytes(int64(len(frame)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ch <- outbound{data: frame}
+		}
+		// the last frame is acknowledged once every frame before it is written
+		last := make(chan error, 1)
+		ch <- outbound{data: frame, done: last}
+		<-last
+		close(die)
+	})
+}
@@ -0,0 +1,46 @@
+// Package zapadapter wires the connector Logger interface to go.uber.org/zap
+package zapadapter
+
+import (
+	"go.uber.org/zap"
+
+	client "github.com/revzim/go-pomelo-client"
+)
+
+// Logger adapts a *zap.Logger to client.Logger
+type Logger struct {
+	l *zap.Logger
+}
+
+// New returns a client.Logger writing to l
+func New(l *zap.Logger) *Logger {
+	return &Logger{l: l}
+}
+
+// Debug --
+func (z *Logger) Debug(msg string, fields ...client.Field) {
+	z.l.Debug(msg, convert(fields)...)
+}
+
+// Info --
+func (z *Logger) Info(msg string, fields ...client.Field) {
+	z.l.Info(msg, convert(fields)...)
+}
+
+// Warn --
+func (z *Logger) Warn(msg string, fields ...client.Field) {
+	z.l.Warn(msg, convert(fields)...)
+}
+
+// Error --
+func (z *Logger) Error(msg string, fields ...client.Field) {
+	z.l.Error(msg, convert(fields)...)
+}
+
+func convert(fields []client.Field) []zap.Field {
+	zf := make([]zap.Field, len(fields))
+	for i, f := range fields {
+		zf[i] = zap.Any(f.Key, f.Value)
+	}
+	return zf
+}